@@ -2,6 +2,8 @@ package ast
 
 import (
 	"bytes"
+	"math/big"
+	"sort"
 	"strings"
 
 	"github.com/yassinebenaid/nishimia/token"
@@ -11,6 +13,8 @@ type (
 	Node interface {
 		TokenLiteral() string
 		String() string
+		Pos() token.Position // position of the first character of the node
+		End() token.Position // position immediately after the node
 	}
 
 	Expression interface {
@@ -27,6 +31,7 @@ type (
 // this turns out that every program in nishimia is just a sequence of statements
 type Program struct {
 	Statements []Statement
+	Comments   []*Comment // all the comments in the source, only collected when the lexer scans comments
 }
 
 func (p *Program) TokenLiteral() string {
@@ -37,6 +42,22 @@ func (p *Program) TokenLiteral() string {
 	return ""
 }
 
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+
+	return token.Position{}
+}
+
+func (p *Program) End() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[len(p.Statements)-1].End()
+	}
+
+	return token.Position{}
+}
+
 func (p *Program) String() string {
 	var s bytes.Buffer
 	for _, stat := range p.Statements {
@@ -45,18 +66,76 @@ func (p *Program) String() string {
 	return s.String()
 }
 
+// This node represents a single line comment (// ...) or block comment (/* ... */)
+type Comment struct {
+	Token token.Token
+}
+
+func (c *Comment) TokenLiteral() string { return c.Token.Literal }
+func (c *Comment) String() string       { return c.Token.Literal }
+func (c *Comment) Pos() token.Position  { return c.Token.Pos }
+func (c *Comment) End() token.Position  { return c.Token.End }
+
+// This node represents a sequence of comments with no other tokens and no empty lines between them,
+// it is typically the documentation of the statement that follows it.
+type CommentGroup struct {
+	List []*Comment
+}
+
+func (g *CommentGroup) TokenLiteral() string { return g.List[0].TokenLiteral() }
+func (g *CommentGroup) Pos() token.Position  { return g.List[0].Pos() }
+func (g *CommentGroup) End() token.Position  { return g.List[len(g.List)-1].End() }
+func (g *CommentGroup) String() string {
+	var lines []string
+
+	for _, c := range g.List {
+		lines = append(lines, c.String())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Text returns the text of the comments without the comment markers
+func (g *CommentGroup) Text() string {
+	var lines []string
+
+	for _, c := range g.List {
+		text := c.Token.Literal
+
+		if strings.HasPrefix(text, "//") {
+			text = text[2:]
+		} else {
+			text = text[2 : len(text)-2]
+		}
+
+		lines = append(lines, strings.TrimSpace(text))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
 // This node represents a variable binding statement, typically
 // any line that looks like :
 //
 //	var name = value
+//	const name = value // the binding can't be reassigned
 type VarStatement struct {
 	Token token.Token
+	Doc   *CommentGroup // the comments right before the statement, may be nil
 	Name  *Identifier
 	Value Expression
 }
 
 func (v *VarStatement) statementNode()       {}
 func (v *VarStatement) TokenLiteral() string { return v.Token.Literal }
+func (v *VarStatement) Pos() token.Position  { return v.Token.Pos }
+func (v *VarStatement) End() token.Position {
+	if v.Value != nil {
+		return v.Value.End()
+	}
+
+	return v.Name.End()
+}
 
 func (v *VarStatement) String() string {
 	var s bytes.Buffer
@@ -74,6 +153,19 @@ func (v *VarStatement) String() string {
 	return s.String()
 }
 
+// This node represents a statement that failed to parse, it spans all the tokens
+// skipped by the parser while recovering from the error.
+type BadStatement struct {
+	Token token.Token // the first token of the statement
+	Last  token.Token // the last skipped token
+}
+
+func (b *BadStatement) statementNode()       {}
+func (b *BadStatement) TokenLiteral() string { return b.Token.Literal }
+func (b *BadStatement) String() string       { return "<bad statement>" }
+func (b *BadStatement) Pos() token.Position  { return b.Token.Pos }
+func (b *BadStatement) End() token.Position  { return b.Last.End }
+
 // This node represents any identifier in the language, this includes variables, functions, constants etc.
 type Identifier struct {
 	Token token.Token
@@ -83,17 +175,28 @@ type Identifier struct {
 func (i *Identifier) expressionNode()      {}
 func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
 func (i *Identifier) String() string       { return i.Value }
+func (i *Identifier) Pos() token.Position  { return i.Token.Pos }
+func (i *Identifier) End() token.Position  { return i.Token.End }
 
 // This node represents the return statement, typically any statement that looks like :
 //
 //	return expression
 type ReturnStatement struct {
 	Token  token.Token
+	Doc    *CommentGroup // the comments right before the statement, may be nil
 	Return Expression
 }
 
 func (r *ReturnStatement) statementNode()       {}
 func (r *ReturnStatement) TokenLiteral() string { return r.Token.Literal }
+func (r *ReturnStatement) Pos() token.Position  { return r.Token.Pos }
+func (r *ReturnStatement) End() token.Position {
+	if r.Return != nil {
+		return r.Return.End()
+	}
+
+	return r.Token.End
+}
 
 func (r *ReturnStatement) String() string {
 	var s bytes.Buffer
@@ -119,11 +222,20 @@ func (r *ReturnStatement) String() string {
 //	functionName(arg1,arg2)
 type ExpressionStatement struct {
 	Token      token.Token
+	Doc        *CommentGroup // the comments right before the statement, may be nil
 	Expression Expression
 }
 
 func (e *ExpressionStatement) statementNode()       {}
 func (e *ExpressionStatement) TokenLiteral() string { return e.Token.Literal }
+func (e *ExpressionStatement) Pos() token.Position  { return e.Token.Pos }
+func (e *ExpressionStatement) End() token.Position {
+	if e.Expression != nil {
+		return e.Expression.End()
+	}
+
+	return e.Token.End
+}
 
 func (e *ExpressionStatement) String() string {
 	if e.Expression != nil {
@@ -142,6 +254,32 @@ type IntegerLiteral struct {
 func (i *IntegerLiteral) expressionNode()      {}
 func (i *IntegerLiteral) TokenLiteral() string { return i.Token.Literal }
 func (i *IntegerLiteral) String() string       { return i.Token.Literal }
+func (i *IntegerLiteral) Pos() token.Position  { return i.Token.Pos }
+func (i *IntegerLiteral) End() token.Position  { return i.Token.End }
+
+// This node represents the integer literals that don't fit in 64 bits
+type BigIntegerLiteral struct {
+	Token token.Token
+	Value *big.Int
+}
+
+func (i *BigIntegerLiteral) expressionNode()      {}
+func (i *BigIntegerLiteral) TokenLiteral() string { return i.Token.Literal }
+func (i *BigIntegerLiteral) String() string       { return i.Token.Literal }
+func (i *BigIntegerLiteral) Pos() token.Position  { return i.Token.Pos }
+func (i *BigIntegerLiteral) End() token.Position  { return i.Token.End }
+
+// This node represents all the float literals in the language like 3.14, .5 or 1e-9
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (f *FloatLiteral) expressionNode()      {}
+func (f *FloatLiteral) TokenLiteral() string { return f.Token.Literal }
+func (f *FloatLiteral) String() string       { return f.Token.Literal }
+func (f *FloatLiteral) Pos() token.Position  { return f.Token.Pos }
+func (f *FloatLiteral) End() token.Position  { return f.Token.End }
 
 // This node represents all the prefix expressions like :
 //
@@ -158,6 +296,14 @@ type PrefixExpression struct {
 
 func (p *PrefixExpression) expressionNode()      {}
 func (p *PrefixExpression) TokenLiteral() string { return p.Token.Literal }
+func (p *PrefixExpression) Pos() token.Position  { return p.Token.Pos }
+func (p *PrefixExpression) End() token.Position {
+	if p.Right != nil {
+		return p.Right.End()
+	}
+
+	return p.Token.End
+}
 func (p *PrefixExpression) String() string {
 	var out bytes.Buffer
 
@@ -181,6 +327,14 @@ type InfixExpression struct {
 
 func (p *InfixExpression) expressionNode()      {}
 func (p *InfixExpression) TokenLiteral() string { return p.Token.Literal }
+func (p *InfixExpression) Pos() token.Position  { return p.Left.Pos() }
+func (p *InfixExpression) End() token.Position {
+	if p.Right != nil {
+		return p.Right.End()
+	}
+
+	return p.Token.End
+}
 func (p *InfixExpression) String() string {
 	var out bytes.Buffer
 
@@ -206,6 +360,8 @@ type BooleanLiteral struct {
 func (i *BooleanLiteral) expressionNode()      {}
 func (i *BooleanLiteral) TokenLiteral() string { return i.Token.Literal }
 func (i *BooleanLiteral) String() string       { return i.Token.Literal }
+func (i *BooleanLiteral) Pos() token.Position  { return i.Token.Pos }
+func (i *BooleanLiteral) End() token.Position  { return i.Token.End }
 
 type StringLiteral struct {
 	Token token.Token
@@ -215,8 +371,48 @@ type StringLiteral struct {
 func (sl *StringLiteral) expressionNode()      {}
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
 func (sl *StringLiteral) String() string       { return sl.Token.Literal }
+func (sl *StringLiteral) Pos() token.Position  { return sl.Token.Pos }
+func (sl *StringLiteral) End() token.Position  { return sl.Token.End }
+
+// This node represents the assignment to a variable, an array item or a hash key like :
+//
+//	name = value
+//	array[0] += value // operator is +=
+//	hash["key"] = value
+//	user.name = value
+type AssignExpression struct {
+	Token    token.Token // the assignment operator token
+	Target   Expression  // either an *Identifier, an *IndexExpression or a *MemberExpression
+	Operator string
+	Value    Expression
+}
+
+func (a *AssignExpression) expressionNode()      {}
+func (a *AssignExpression) TokenLiteral() string { return a.Token.Literal }
+func (a *AssignExpression) Pos() token.Position  { return a.Target.Pos() }
+func (a *AssignExpression) End() token.Position {
+	if a.Value != nil {
+		return a.Value.End()
+	}
 
-// This node represents the if-else expression
+	return a.Token.End
+}
+func (a *AssignExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(a.Target.String())
+	out.WriteString(" ")
+	out.WriteString(a.Operator)
+	out.WriteString(" ")
+	out.WriteString(a.Value.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+
+// This node represents the if-else expression, an else-if chain is represented
+// by an Alternative block holding the nested if-else expression.
 type IfElseExpression struct {
 	Token       token.Token
 	Condition   Expression
@@ -226,6 +422,18 @@ type IfElseExpression struct {
 
 func (i *IfElseExpression) expressionNode()      {}
 func (i *IfElseExpression) TokenLiteral() string { return i.Token.Literal }
+func (i *IfElseExpression) Pos() token.Position  { return i.Token.Pos }
+func (i *IfElseExpression) End() token.Position {
+	if i.Alternative != nil {
+		return i.Alternative.End()
+	}
+
+	if i.Consequence != nil {
+		return i.Consequence.End()
+	}
+
+	return i.Token.End
+}
 func (i *IfElseExpression) String() string {
 	var out bytes.Buffer
 
@@ -242,15 +450,277 @@ func (i *IfElseExpression) String() string {
 	return out.String()
 }
 
+// This node represents the match expression, typically any expression that looks like :
+//
+//	match value {
+//		case 0 { "zero" }
+//		case [x, _] if x > 0 { "array of two items starting by a positive number" }
+//		case {"name": name} { name }
+//		default { "anything else" }
+//	}
+type MatchExpression struct {
+	Token   token.Token
+	Subject Expression
+	Arms    []*MatchArm
+	Rbrace  token.Token // the closing brace
+}
+
+func (m *MatchExpression) expressionNode()      {}
+func (m *MatchExpression) TokenLiteral() string { return m.Token.Literal }
+func (m *MatchExpression) Pos() token.Position  { return m.Token.Pos }
+func (m *MatchExpression) End() token.Position  { return m.Rbrace.End }
+func (m *MatchExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("match ")
+	out.WriteString(m.Subject.String())
+	out.WriteString(" {")
+
+	for i, arm := range m.Arms {
+		if i > 0 {
+			out.WriteString(" ")
+		}
+
+		out.WriteString(arm.String())
+	}
+
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// This node represents an arm of a match expression, the pattern is nil for the default arm.
+//
+// patterns are made of literals, identifiers that bind the matched value (_ matches without binding),
+// and arrays and hashes of patterns.
+type MatchArm struct {
+	Token   token.Token // either the case or the default token
+	Pattern Expression
+	Guard   Expression // the condition after the if keyword, may be nil
+	Body    *BlockStatement
+}
+
+func (m *MatchArm) TokenLiteral() string { return m.Token.Literal }
+func (m *MatchArm) Pos() token.Position  { return m.Token.Pos }
+func (m *MatchArm) End() token.Position {
+	if m.Body != nil {
+		return m.Body.End()
+	}
+
+	return m.Token.End
+}
+
+func (m *MatchArm) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(m.TokenLiteral())
+
+	if m.Pattern != nil {
+		out.WriteString(" " + m.Pattern.String())
+	}
+
+	if m.Guard != nil {
+		out.WriteString(" if " + m.Guard.String())
+	}
+
+	out.WriteString(" ")
+	out.WriteString(m.Body.String())
+
+	return out.String()
+}
+
+// This node represents the while loop, typically any statement that looks like :
+//
+//	while condition { ... }
+type WhileStatement struct {
+	Token     token.Token
+	Doc       *CommentGroup // the comments right before the statement or its label, may be nil
+	Label     *Identifier   // the label of the loop, nil if the loop is not labeled
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (w *WhileStatement) statementNode()       {}
+func (w *WhileStatement) TokenLiteral() string { return w.Token.Literal }
+func (w *WhileStatement) Pos() token.Position  { return loopPos(w.Label, w.Token) }
+func (w *WhileStatement) End() token.Position {
+	if w.Body != nil {
+		return w.Body.End()
+	}
+
+	return w.Token.End
+}
+
+func (w *WhileStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(loopLabel(w.Label))
+	out.WriteString("while ")
+	out.WriteString(w.Condition.String())
+	out.WriteString(" ")
+	out.WriteString(w.Body.String())
+
+	return out.String()
+}
+
+// This node represents the C-style for loop, typically any statement that looks like :
+//
+//	for var i = 0; i < 10; i += 1 { ... }
+//
+// the init statement, the condition and the post expression are all optional.
+type ForStatement struct {
+	Token     token.Token
+	Doc       *CommentGroup // the comments right before the statement or its label, may be nil
+	Label     *Identifier   // the label of the loop, nil if the loop is not labeled
+	Init      Statement     // evaluated once before the loop, may be nil
+	Condition Expression    // checked before every iteration, may be nil
+	Post      Expression    // evaluated after every iteration, may be nil
+	Body      *BlockStatement
+}
+
+func (f *ForStatement) statementNode()       {}
+func (f *ForStatement) TokenLiteral() string { return f.Token.Literal }
+func (f *ForStatement) Pos() token.Position  { return loopPos(f.Label, f.Token) }
+func (f *ForStatement) End() token.Position {
+	if f.Body != nil {
+		return f.Body.End()
+	}
+
+	return f.Token.End
+}
+
+func (f *ForStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(loopLabel(f.Label))
+	out.WriteString("for ")
+
+	if f.Init != nil {
+		out.WriteString(strings.TrimSuffix(f.Init.String(), ";"))
+	}
+
+	out.WriteString("; ")
+
+	if f.Condition != nil {
+		out.WriteString(f.Condition.String())
+	}
+
+	out.WriteString(";")
+
+	if f.Post != nil {
+		out.WriteString(" " + f.Post.String())
+	}
+
+	out.WriteString(" ")
+	out.WriteString(f.Body.String())
+
+	return out.String()
+}
+
+// This node represents the loop over the items of a collection, typically any statement that looks like :
+//
+//	for item in array { ... }
+//	for key, value in hash { ... }
+//
+// in the one variable form only Key is set, it receives the items of arrays,
+// the characters of strings and the keys of hashes.
+type ForInStatement struct {
+	Token    token.Token
+	Doc      *CommentGroup // the comments right before the statement or its label, may be nil
+	Label    *Identifier   // the label of the loop, nil if the loop is not labeled
+	Key      *Identifier
+	Value    *Identifier // nil in the one variable form
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (f *ForInStatement) statementNode()       {}
+func (f *ForInStatement) TokenLiteral() string { return f.Token.Literal }
+func (f *ForInStatement) Pos() token.Position  { return loopPos(f.Label, f.Token) }
+func (f *ForInStatement) End() token.Position {
+	if f.Body != nil {
+		return f.Body.End()
+	}
+
+	return f.Token.End
+}
+
+func (f *ForInStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(loopLabel(f.Label))
+	out.WriteString("for ")
+	out.WriteString(f.Key.String())
+
+	if f.Value != nil {
+		out.WriteString(", " + f.Value.String())
+	}
+
+	out.WriteString(" in ")
+	out.WriteString(f.Iterable.String())
+	out.WriteString(" ")
+	out.WriteString(f.Body.String())
+
+	return out.String()
+}
+
+// This node represents the break and continue statements, the label is optional :
+//
+//	break;
+//	continue outer;
+type BranchStatement struct {
+	Token token.Token   // either the break or the continue token
+	Doc   *CommentGroup // the comments right before the statement, may be nil
+	Label *Identifier   // the label of the targeted loop, nil for the innermost loop
+}
+
+func (b *BranchStatement) statementNode()       {}
+func (b *BranchStatement) TokenLiteral() string { return b.Token.Literal }
+func (b *BranchStatement) Pos() token.Position  { return b.Token.Pos }
+func (b *BranchStatement) End() token.Position {
+	if b.Label != nil {
+		return b.Label.End()
+	}
+
+	return b.Token.End
+}
+
+func (b *BranchStatement) String() string {
+	if b.Label != nil {
+		return b.TokenLiteral() + " " + b.Label.String() + ";"
+	}
+
+	return b.TokenLiteral() + ";"
+}
+
+func loopPos(label *Identifier, tok token.Token) token.Position {
+	if label != nil {
+		return label.Pos()
+	}
+
+	return tok.Pos
+}
+
+func loopLabel(label *Identifier) string {
+	if label != nil {
+		return label.String() + ": "
+	}
+
+	return ""
+}
+
 // This node represents the blocks of statements, typically any block between braces like
 // inside if-else statements, or function definitions etc.
 type BlockStatement struct {
 	Token      token.Token
 	Statements []Statement
+	Rbrace     token.Token // the closing brace
 }
 
 func (b *BlockStatement) expressionNode()      {}
 func (b *BlockStatement) TokenLiteral() string { return b.Token.Literal }
+func (b *BlockStatement) Pos() token.Position  { return b.Token.Pos }
+func (b *BlockStatement) End() token.Position  { return b.Rbrace.End }
 func (b *BlockStatement) String() string {
 	var out bytes.Buffer
 
@@ -276,6 +746,14 @@ type FunctionLiteral struct {
 
 func (fn *FunctionLiteral) expressionNode()      {}
 func (fn *FunctionLiteral) TokenLiteral() string { return fn.Token.Literal }
+func (fn *FunctionLiteral) Pos() token.Position  { return fn.Token.Pos }
+func (fn *FunctionLiteral) End() token.Position {
+	if fn.Body != nil {
+		return fn.Body.End()
+	}
+
+	return fn.Token.End
+}
 func (fn *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
@@ -301,10 +779,13 @@ type CallExpression struct {
 	Token     token.Token
 	Function  Expression
 	Arguments []Expression
+	Rparent   token.Token // the closing parenthesis
 }
 
 func (c *CallExpression) expressionNode()      {}
 func (c *CallExpression) TokenLiteral() string { return c.Token.Literal }
+func (c *CallExpression) Pos() token.Position  { return c.Function.Pos() }
+func (c *CallExpression) End() token.Position  { return c.Rparent.End }
 func (c *CallExpression) String() string {
 	var out bytes.Buffer
 
@@ -323,12 +804,15 @@ func (c *CallExpression) String() string {
 
 // This node represents the array ,.
 type ArrayLiteral struct {
-	Token token.Token
-	Items []Expression
+	Token    token.Token
+	Items    []Expression
+	Rbracket token.Token // the closing bracket
 }
 
 func (a *ArrayLiteral) expressionNode()      {}
 func (a *ArrayLiteral) TokenLiteral() string { return a.Token.Literal }
+func (a *ArrayLiteral) Pos() token.Position  { return a.Token.Pos }
+func (a *ArrayLiteral) End() token.Position  { return a.Rbracket.End }
 func (a *ArrayLiteral) String() string {
 	var out bytes.Buffer
 
@@ -346,13 +830,16 @@ func (a *ArrayLiteral) String() string {
 
 // This node represents the array index like array[index] ,.
 type IndexExpression struct {
-	Token token.Token
-	Left  Expression
-	Index Expression
+	Token    token.Token
+	Left     Expression
+	Index    Expression
+	Rbracket token.Token // the closing bracket
 }
 
 func (a *IndexExpression) expressionNode()      {}
 func (a *IndexExpression) TokenLiteral() string { return a.Token.Literal }
+func (a *IndexExpression) Pos() token.Position  { return a.Left.Pos() }
+func (a *IndexExpression) End() token.Position  { return a.Rbracket.End }
 func (a *IndexExpression) String() string {
 	var out bytes.Buffer
 
@@ -364,26 +851,44 @@ func (a *IndexExpression) String() string {
 	return out.String()
 }
 
+// This node represents the access to an attribute like object.name
+type MemberExpression struct {
+	Token    token.Token // the dot
+	Object   Expression
+	Property *Identifier
+}
+
+func (m *MemberExpression) expressionNode()      {}
+func (m *MemberExpression) TokenLiteral() string { return m.Token.Literal }
+func (m *MemberExpression) Pos() token.Position  { return m.Object.Pos() }
+func (m *MemberExpression) End() token.Position  { return m.Property.End() }
+func (m *MemberExpression) String() string {
+	return m.Object.String() + "." + m.Property.String()
+}
+
 // This node represents the array ,.
 type HashLiteral struct {
-	Token token.Token
-	Items map[Expression]Expression
+	Token  token.Token
+	Items  map[Expression]Expression
+	Rbrace token.Token // the closing brace
 }
 
 func (h *HashLiteral) expressionNode()      {}
 func (h *HashLiteral) TokenLiteral() string { return h.Token.Literal }
+func (h *HashLiteral) Pos() token.Position  { return h.Token.Pos }
+func (h *HashLiteral) End() token.Position  { return h.Rbrace.End }
 func (h *HashLiteral) String() string {
 	var out bytes.Buffer
 
 	out.WriteString("{")
 
 	var count int
-	for k, v := range h.Items {
+	for _, k := range h.Keys() {
 		count++
 
 		out.WriteString(k.String())
 		out.WriteString(": ")
-		out.WriteString(v.String())
+		out.WriteString(h.Items[k].String())
 
 		if count < len(h.Items) {
 			out.WriteString(" ,")
@@ -394,3 +899,18 @@ func (h *HashLiteral) String() string {
 
 	return out.String()
 }
+
+// Keys returns the keys of the hash in the order they appear in the source
+func (h *HashLiteral) Keys() []Expression {
+	keys := make([]Expression, 0, len(h.Items))
+
+	for k := range h.Items {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Pos().Offset < keys[j].Pos().Offset
+	})
+
+	return keys
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+
+	"github.com/yassinebenaid/nishimia/ast"
+	"github.com/yassinebenaid/nishimia/compiler"
+	"github.com/yassinebenaid/nishimia/diagnostics"
+	"github.com/yassinebenaid/nishimia/eval"
+	"github.com/yassinebenaid/nishimia/lexer"
+	"github.com/yassinebenaid/nishimia/object"
+	"github.com/yassinebenaid/nishimia/parser"
+	"github.com/yassinebenaid/nishimia/repl"
+	"github.com/yassinebenaid/nishimia/vm"
+)
+
+var diagnosticsFormat = flag.String("diagnostics", "auto", "how errors are rendered: auto, plain, color or json")
+var engine = flag.String("engine", "eval", "the engine running the programs: eval (tree walking evaluator) or vm (bytecode virtual machine)")
+var timeout = flag.Duration("timeout", 0, "the maximum duration of running a file, like 500ms or 2s, no limit if zero")
+
+func main() {
+	flag.Parse()
+
+	if *engine != "eval" && *engine != "vm" {
+		fmt.Println("unknown engine", *engine)
+		os.Exit(1)
+	}
+
+	if flag.NArg() < 1 {
+		Interactive()
+		os.Exit(0)
+	}
+
+	if flag.NArg() == 1 {
+		os.Exit(RunFile(flag.Arg(0)))
+	}
+
+	fmt.Println("undefined options", os.Args)
+}
+
+func Interactive() {
+	user, err := user.Current()
+
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("Welcome %s , this is nishimia lang ready to get you excited ! \n", user.Username)
+
+	if *engine == "vm" {
+		repl.StartVM(os.Stdin, os.Stdout)
+	} else {
+		repl.Start(os.Stdin, os.Stdout)
+	}
+
+	fmt.Print("\nGood by !\n")
+}
+
+// RunFile evaluates the given file and returns the exit code of the process
+func RunFile(fn string) int {
+	file, err := os.ReadFile(fn)
+	if err != nil {
+		fmt.Println("Error: \n\t", err)
+		return 1
+	}
+
+	src := string(file)
+	mode := diagnosticsMode(os.Stderr)
+
+	lex := lexer.NewFile(fn, src)
+	par := parser.New(lex)
+	program := par.ParseProgram()
+
+	if errs := par.Errors(); len(errs) > 0 {
+		diagnostics.Render(os.Stderr, mode, src, diagnostics.FromParserErrors(errs)...)
+		return 1
+	}
+
+	ctx := context.Background()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	result, err := execute(ctx, program)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+
+	if err, ok := result.(*object.Error); ok {
+		diagnostics.Render(os.Stderr, mode, src, diagnostics.FromRuntimeError(err))
+		return 1
+	}
+
+	if result != nil && result.Type() != object.NULL_OBJ {
+		fmt.Println(result.Inspect())
+	}
+
+	return 0
+}
+
+// execute runs the program using the selected engine until the context is done,
+// the error reports programs the compiler can't handle.
+func execute(ctx context.Context, program *ast.Program) (object.Object, error) {
+	if *engine == "vm" {
+		bytecode, err := compiler.Compile(program)
+		if err != nil {
+			return nil, err
+		}
+
+		return vm.RunContext(ctx, bytecode, nil), nil
+	}
+
+	return eval.EvalContext(ctx, program, object.NewEnvirement()), nil
+}
+
+func diagnosticsMode(w io.Writer) diagnostics.Mode {
+	switch *diagnosticsFormat {
+	case "plain":
+		return diagnostics.Plain
+	case "color":
+		return diagnostics.Color
+	case "json":
+		return diagnostics.JSON
+	default:
+		return diagnostics.ModeFor(w)
+	}
+}
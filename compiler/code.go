@@ -0,0 +1,280 @@
+package compiler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// Instructions is a sequence of encoded instructions, each instruction is an opcode
+// of one byte followed by its operands encoded in big endian.
+type Instructions []byte
+
+type Opcode byte
+
+const (
+	OpConstant Opcode = iota // push the constant at the given index
+	OpNull                   // push null
+	OpTrue                   // push true
+	OpFalse                  // push false
+	OpPop                    // discard the value on top of the stack
+
+	// the binary operators, they pop the right operand then the left one and push the result
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpMod
+	OpEqual
+	OpNotEqual
+	OpGreater
+	OpGreaterEqual
+	OpLess
+	OpLessEqual
+	OpAnd
+	OpOr
+
+	// the unary operators, they replace the value on top of the stack by the result
+	OpMinus
+	OpPlus
+	OpBang
+
+	OpJump         // jump to the given address
+	OpJumpNotTrue  // pop a boolean, jump to the given address if it is false, the condition kind is used to report non-boolean values
+	OpShortCircuit // if the left operand on top of the stack decides the result of the given logical operator, jump to the address keeping it
+
+	OpGetVar // push the value of the given binding
+	OpDefine // pop a value and define the given binding, the flag is set for constants
+	OpAssign // pop a value and assign it to the given binding, combined with the current value by the given operator for compound assignments, push the assigned value
+	OpStore  // pop a value and store it in the given binding without any check
+
+	OpArray       // pop the given number of items and push an array of them
+	OpHash        // push an empty hash
+	OpHashPut     // pop a value and a key and add them to the hash on top of the stack
+	OpCheckIndex  // fail if the value on top of the stack doesn't support the index operator
+	OpIndex       // pop an index and a collection, push the item at that index
+	OpAssignIndex // pop an index, a collection and a value, then store the value at that index, push the stored value
+	OpGetAttr     // pop a host object and push its attribute named by the given constant
+	OpGetMethod   // pop a host object and push a builtin calling its method named by the given constant
+	OpAssignAttr  // pop a host object and a value, then assign the attribute named by the given constant like OpAssignIndex
+
+	OpClosure     // push a closure of the compiled function at the given constant index, capturing the current scope
+	OpCall        // call the function below the given number of arguments
+	OpTailCall    // call the function below the given number of arguments in place of the current function
+	OpReturnValue // return the value on top of the stack from the current function
+	OpReturn      // return null from the current function
+
+	OpEnterScope // enter a new scope with the given number of slots
+	OpLeaveScope // go back to the enclosing scope
+
+	OpLoop     // record the current scope and stack height for the break and continue statements of the loop starting here
+	OpLoopEnd  // forget the record of the innermost loop
+	OpBreak    // unwind to the record of the loop at the given depth, forget it and jump to the given address
+	OpContinue // unwind to the record of the loop at the given depth and jump to the given address
+
+	OpIter     // pop a collection and push an iterator over it, the flag is set for the one variable form
+	OpIterNext // jump to the given address if the iterator on top of the stack is exhausted, otherwise push the next key and value
+
+	OpMatch    // match the value on top of the stack against the given pattern, binding its variables, jump to the given address if it doesn't match
+	OpNoMatch  // fail because no arm matches the value on top of the stack
+	OpMatchEnd // pop the result of the matching arm and the matched value, then push the result back
+)
+
+// Condition kinds of OpJumpNotTrue, they only change the error reported for non-boolean values
+const (
+	IfCondition byte = iota
+	LoopCondition
+	MatchGuard
+)
+
+// Definition describes an opcode, its name and the width in bytes of each operand
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant: {"OpConstant", []int{2}},
+	OpNull:     {"OpNull", []int{}},
+	OpTrue:     {"OpTrue", []int{}},
+	OpFalse:    {"OpFalse", []int{}},
+	OpPop:      {"OpPop", []int{}},
+
+	OpAdd:          {"OpAdd", []int{}},
+	OpSub:          {"OpSub", []int{}},
+	OpMul:          {"OpMul", []int{}},
+	OpDiv:          {"OpDiv", []int{}},
+	OpMod:          {"OpMod", []int{}},
+	OpEqual:        {"OpEqual", []int{}},
+	OpNotEqual:     {"OpNotEqual", []int{}},
+	OpGreater:      {"OpGreater", []int{}},
+	OpGreaterEqual: {"OpGreaterEqual", []int{}},
+	OpLess:         {"OpLess", []int{}},
+	OpLessEqual:    {"OpLessEqual", []int{}},
+	OpAnd:          {"OpAnd", []int{}},
+	OpOr:           {"OpOr", []int{}},
+
+	OpMinus: {"OpMinus", []int{}},
+	OpPlus:  {"OpPlus", []int{}},
+	OpBang:  {"OpBang", []int{}},
+
+	OpJump:         {"OpJump", []int{4}},
+	OpJumpNotTrue:  {"OpJumpNotTrue", []int{1, 4}},
+	OpShortCircuit: {"OpShortCircuit", []int{1, 4}},
+
+	OpGetVar: {"OpGetVar", []int{2}},
+	OpDefine: {"OpDefine", []int{2, 1}},
+	OpAssign: {"OpAssign", []int{2, 1}},
+	OpStore:  {"OpStore", []int{2}},
+
+	OpArray:       {"OpArray", []int{2}},
+	OpHash:        {"OpHash", []int{}},
+	OpHashPut:     {"OpHashPut", []int{}},
+	OpCheckIndex:  {"OpCheckIndex", []int{}},
+	OpIndex:       {"OpIndex", []int{}},
+	OpAssignIndex: {"OpAssignIndex", []int{1}},
+	OpGetAttr:     {"OpGetAttr", []int{2}},
+	OpGetMethod:   {"OpGetMethod", []int{2}},
+	OpAssignAttr:  {"OpAssignAttr", []int{2, 1}},
+
+	OpClosure:     {"OpClosure", []int{2}},
+	OpCall:        {"OpCall", []int{2}},
+	OpTailCall:    {"OpTailCall", []int{2}},
+	OpReturnValue: {"OpReturnValue", []int{}},
+	OpReturn:      {"OpReturn", []int{}},
+
+	OpEnterScope: {"OpEnterScope", []int{2}},
+	OpLeaveScope: {"OpLeaveScope", []int{}},
+
+	OpLoop:     {"OpLoop", []int{}},
+	OpLoopEnd:  {"OpLoopEnd", []int{}},
+	OpBreak:    {"OpBreak", []int{2, 4}},
+	OpContinue: {"OpContinue", []int{2, 4}},
+
+	OpIter:     {"OpIter", []int{1}},
+	OpIterNext: {"OpIterNext", []int{4}},
+
+	OpMatch:    {"OpMatch", []int{2, 4}},
+	OpNoMatch:  {"OpNoMatch", []int{}},
+	OpMatchEnd: {"OpMatchEnd", []int{}},
+}
+
+// Operators maps the opcodes of the binary operators to the operators of the language
+var Operators = map[Opcode]string{
+	OpAdd:          "+",
+	OpSub:          "-",
+	OpMul:          "*",
+	OpDiv:          "/",
+	OpMod:          "%",
+	OpEqual:        "==",
+	OpNotEqual:     "!=",
+	OpGreater:      ">",
+	OpGreaterEqual: ">=",
+	OpLess:         "<",
+	OpLessEqual:    "<=",
+	OpAnd:          "&&",
+	OpOr:           "||",
+}
+
+// Lookup returns the definition of the opcode
+func Lookup(op Opcode) (*Definition, error) {
+	def, ok := definitions[op]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+
+	return def, nil
+}
+
+// Make encodes an instruction, it panics if an operand doesn't fit in its width.
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	length := 1
+	for _, w := range def.OperandWidths {
+		length += w
+	}
+
+	instruction := make([]byte, length)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		width := def.OperandWidths[i]
+
+		if o < 0 || o >= 1<<(8*width) {
+			panic(fmt.Sprintf("operand %d of %s out of range: %d", i, def.Name, o))
+		}
+
+		switch width {
+		case 1:
+			instruction[offset] = byte(o)
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 4:
+			binary.BigEndian.PutUint32(instruction[offset:], uint32(o))
+		}
+
+		offset += width
+	}
+
+	return instruction
+}
+
+// ReadOperands decodes the operands of an instruction, it returns them with the number of bytes read.
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 1:
+			operands[i] = int(ins[offset])
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 4:
+			operands[i] = int(ReadUint32(ins[offset:]))
+		}
+
+		offset += width
+	}
+
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+func ReadUint32(ins Instructions) uint32 {
+	return binary.BigEndian.Uint32(ins)
+}
+
+// String disassembles the instructions, one instruction per line prefixed by its address.
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+
+	for i := 0; i < len(ins); {
+		def, err := Lookup(Opcode(ins[i]))
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i++
+			continue
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+
+		fmt.Fprintf(&out, "%04d %s", i, def.Name)
+		for _, o := range operands {
+			fmt.Fprintf(&out, " %d", o)
+		}
+		out.WriteString("\n")
+
+		i += 1 + read
+	}
+
+	return out.String()
+}
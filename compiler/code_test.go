@@ -0,0 +1,90 @@
+package compiler
+
+import "testing"
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		op       Opcode
+		operands []int
+		expected []byte
+	}{
+		{OpConstant, []int{65534}, []byte{byte(OpConstant), 255, 254}},
+		{OpAdd, []int{}, []byte{byte(OpAdd)}},
+		{OpJump, []int{65536}, []byte{byte(OpJump), 0, 1, 0, 0}},
+		{OpDefine, []int{2, 1}, []byte{byte(OpDefine), 0, 2, 1}},
+	}
+
+	for _, tt := range tests {
+		instruction := Make(tt.op, tt.operands...)
+
+		if string(instruction) != string(tt.expected) {
+			t.Errorf("wrong instruction for %v. expected=%v, got=%v", tt.op, tt.expected, instruction)
+		}
+	}
+}
+
+func TestMakeOutOfRange(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a panic for an operand out of range")
+		}
+	}()
+
+	Make(OpConstant, 65536)
+}
+
+func TestReadOperands(t *testing.T) {
+	tests := []struct {
+		op       Opcode
+		operands []int
+		read     int
+	}{
+		{OpConstant, []int{65535}, 2},
+		{OpJumpNotTrue, []int{2, 100000}, 5},
+		{OpBreak, []int{1, 42}, 6},
+	}
+
+	for _, tt := range tests {
+		def, err := Lookup(tt.op)
+		if err != nil {
+			t.Fatalf("definition not found: %s", err)
+		}
+
+		operands, read := ReadOperands(def, Make(tt.op, tt.operands...)[1:])
+
+		if read != tt.read {
+			t.Errorf("wrong number of bytes read for %s. expected=%d, got=%d", def.Name, tt.read, read)
+		}
+
+		for i, want := range tt.operands {
+			if operands[i] != want {
+				t.Errorf("wrong operand %d for %s. expected=%d, got=%d", i, def.Name, want, operands[i])
+			}
+		}
+	}
+}
+
+func TestInstructionsString(t *testing.T) {
+	var instructions Instructions
+
+	for _, ins := range [][]byte{
+		Make(OpConstant, 1),
+		Make(OpGetVar, 2),
+		Make(OpAdd),
+		Make(OpJumpNotTrue, int(LoopCondition), 20),
+		Make(OpReturnValue),
+	} {
+		instructions = append(instructions, ins...)
+	}
+
+	expected := `0000 OpConstant 1
+0003 OpGetVar 2
+0006 OpAdd
+0007 OpJumpNotTrue 1 20
+0013 OpReturnValue
+`
+
+	if instructions.String() != expected {
+		t.Errorf("wrong disassembly.\nexpected=%q\ngot=%q", expected, instructions.String())
+	}
+}
@@ -0,0 +1,944 @@
+package compiler
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/yassinebenaid/nishimia/ast"
+	"github.com/yassinebenaid/nishimia/eval"
+	"github.com/yassinebenaid/nishimia/object"
+	"github.com/yassinebenaid/nishimia/token"
+)
+
+// Bytecode is the result of a compilation, it is executed by the vm package.
+type Bytecode struct {
+	Instructions Instructions
+	SourceMap    SourceMap
+	Constants    []object.Object
+	Bindings     []*Binding // the variables referenced by the instructions
+	Patterns     []*Pattern // the patterns of the match expressions
+	NumGlobals   int        // the number of slots of the global scope
+}
+
+// CompiledFunction is a function literal compiled to bytecode, closures of it are created at runtime.
+type CompiledFunction struct {
+	Instructions Instructions
+	SourceMap    SourceMap
+	NumSlots     int   // the number of slots of the scope of the calls, the calls have no scope if it is 0
+	Params       []int // the slot of each parameter
+	Literal      *ast.FunctionLiteral
+}
+
+func (*CompiledFunction) Type() object.ObjectType { return object.FUNCTION_OBJ }
+func (fn *CompiledFunction) Inspect() string {
+	return (&object.Function{Params: fn.Literal.Params, Body: fn.Literal.Body}).Inspect()
+}
+
+// Span locates the node an instruction comes from
+type Span struct {
+	Offset int // the address of the instruction
+	Pos    token.Position
+	End    token.Position
+}
+
+// SourceMap maps the instructions that may fail to the nodes they come from, sorted by address.
+type SourceMap []Span
+
+// Lookup returns the span of the instruction at the given address
+func (m SourceMap) Lookup(offset int) (Span, bool) {
+	i := sort.Search(len(m), func(i int) bool { return m[i].Offset >= offset })
+
+	if i < len(m) && m[i].Offset == offset {
+		return m[i], true
+	}
+
+	return Span{}, false
+}
+
+type PatternKind byte
+
+const (
+	LiteralPattern  PatternKind = iota // matches values equal to Value
+	BindPattern                        // matches anything and stores it in Slot
+	WildcardPattern                    // matches anything
+	ArrayPattern                       // matches arrays whose items match Items
+	HashPattern                        // matches hashes having the Keys, with values matching Items
+)
+
+// Pattern is a compiled pattern of a match expression, the bound variables are stored
+// in the slots of the scope of the arm.
+type Pattern struct {
+	Kind  PatternKind
+	Value object.Object
+	Slot  int
+	Items []*Pattern
+	Keys  []object.Object
+}
+
+// Compiler compiles programs to bytecode, the constants, the bindings and the global
+// scope are kept between the compilations so the programs compiled by the same compiler
+// can share their global variables when they run with the same vm.Globals.
+type Compiler struct {
+	constants []object.Object
+	bindings  []*Binding
+	patterns  []*Pattern
+
+	bindingIndex  map[string]int // the index of the bindings, by their string representation
+	constantIndex map[any]int    // the index of the literal constants, by value
+
+	globals *SymbolTable
+	symbols *SymbolTable // the table of the current scope
+	scope   *compilationScope
+}
+
+// compilationScope holds the instructions of the function being compiled
+type compilationScope struct {
+	instructions Instructions
+	sourceMap    SourceMap
+	loops        []*loop // the enclosing loops, the innermost last
+	function     bool    // whether it is a function, the calls it returns are tail calls
+}
+
+// loop collects the break and continue instructions targeting a loop, they are patched once the loop is compiled
+type loop struct {
+	label     string
+	body      bool // whether the body is being compiled, the other parts of the loop belong to the enclosing loops
+	breaks    []int
+	continues []int
+}
+
+func New() *Compiler {
+	globals := NewSymbolTable()
+
+	return &Compiler{
+		bindingIndex:  make(map[string]int),
+		constantIndex: make(map[any]int),
+		globals:       globals,
+		symbols:       globals,
+		scope:         &compilationScope{},
+	}
+}
+
+// Compile compiles a program using a new compiler
+func Compile(program *ast.Program) (*Bytecode, error) {
+	c := New()
+
+	if err := c.Compile(program); err != nil {
+		return nil, err
+	}
+
+	return c.Bytecode(), nil
+}
+
+// Compile compiles the program, it replaces the instructions of the previously compiled program.
+func (c *Compiler) Compile(program *ast.Program) (err error) {
+	c.scope = &compilationScope{}
+	c.symbols = c.globals
+
+	// Make panics when an operand doesn't fit in its width, which only happens with huge programs
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to compile the program: %v", r)
+		}
+	}()
+
+	for _, name := range declarations(program) {
+		c.globals.Define(name)
+	}
+
+	for i, stmt := range program.Statements {
+		if err := c.compile(stmt); err != nil {
+			return err
+		}
+
+		if i < len(program.Statements)-1 {
+			c.emit(OpPop)
+		}
+	}
+
+	if len(program.Statements) > 0 {
+		c.emit(OpReturnValue)
+	}
+
+	return nil
+}
+
+// Bytecode returns the bytecode of the last compiled program
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.scope.instructions,
+		SourceMap:    c.scope.sourceMap,
+		Constants:    c.constants,
+		Bindings:     c.bindings,
+		Patterns:     c.patterns,
+		NumGlobals:   c.globals.Len(),
+	}
+}
+
+// compile compiles a node, every statement and expression leaves exactly one value on the stack,
+// except for the return, break and continue statements that never complete.
+func (c *Compiler) compile(node ast.Node) error {
+	switch v := node.(type) {
+	case *ast.ExpressionStatement:
+		return c.compile(v.Expression)
+	case *ast.VarStatement:
+		if err := c.compile(v.Value); err != nil {
+			return err
+		}
+
+		isConst := 0
+		if v.Token.Type == token.CONST {
+			isConst = 1
+		}
+
+		binding := c.addBinding(&Binding{Name: v.Name.Value, Refs: []Ref{c.symbols.Local(v.Name.Value)}})
+
+		c.emitAt(v, OpDefine, binding, isConst)
+		c.emit(OpNull)
+	case *ast.ReturnStatement:
+		if call, ok := v.Return.(*ast.CallExpression); ok && c.scope.function {
+			if err := c.compileCall(call, OpTailCall); err != nil {
+				return err
+			}
+		} else if err := c.compile(v.Return); err != nil {
+			return err
+		}
+
+		c.emit(OpReturnValue)
+	case *ast.BlockStatement:
+		return c.compileBlock(v)
+	case *ast.WhileStatement:
+		return c.compileWhileStatement(v)
+	case *ast.ForStatement:
+		return c.compileForStatement(v)
+	case *ast.ForInStatement:
+		return c.compileForInStatement(v)
+	case *ast.BranchStatement:
+		return c.compileBranchStatement(v)
+	case *ast.IntegerLiteral:
+		c.emit(OpConstant, c.addLiteral(v.Value, &object.Integer{Value: v.Value}))
+	case *ast.BigIntegerLiteral:
+		c.emit(OpConstant, c.addConstant(&object.BigInt{Value: new(big.Int).Set(v.Value)}))
+	case *ast.FloatLiteral:
+		c.emit(OpConstant, c.addLiteral(v.Value, &object.Float{Value: v.Value}))
+	case *ast.StringLiteral:
+		c.emit(OpConstant, c.addLiteral(v.Value, &object.String{Value: v.Value}))
+	case *ast.BooleanLiteral:
+		if v.Value {
+			c.emit(OpTrue)
+		} else {
+			c.emit(OpFalse)
+		}
+	case *ast.Identifier:
+		c.emitAt(v, OpGetVar, c.addBinding(c.symbols.Resolve(v.Value)))
+	case *ast.PrefixExpression:
+		if err := c.compile(v.Right); err != nil {
+			return err
+		}
+
+		switch v.Operator {
+		case "-":
+			c.emitAt(v, OpMinus)
+		case "+":
+			c.emitAt(v, OpPlus)
+		case "!":
+			c.emitAt(v, OpBang)
+		default:
+			return fmt.Errorf("%s: unknown operator %s", v.Pos(), v.Operator)
+		}
+	case *ast.InfixExpression:
+		return c.compileInfixExpression(v)
+	case *ast.AssignExpression:
+		return c.compileAssignExpression(v)
+	case *ast.IfElseExpression:
+		return c.compileIfElseExpression(v)
+	case *ast.MatchExpression:
+		return c.compileMatchExpression(v)
+	case *ast.FunctionLiteral:
+		return c.compileFunctionLiteral(v)
+	case *ast.CallExpression:
+		return c.compileCall(v, OpCall)
+	case *ast.ArrayLiteral:
+		for _, item := range v.Items {
+			if err := c.compile(item); err != nil {
+				return err
+			}
+		}
+
+		c.emit(OpArray, len(v.Items))
+	case *ast.HashLiteral:
+		c.emit(OpHash)
+
+		for _, key := range v.Keys() {
+			if err := c.compile(key); err != nil {
+				return err
+			}
+
+			if err := c.compile(v.Items[key]); err != nil {
+				return err
+			}
+
+			c.emitAt(v, OpHashPut)
+		}
+	case *ast.IndexExpression:
+		if err := c.compile(v.Left); err != nil {
+			return err
+		}
+
+		c.emitAt(v, OpCheckIndex)
+
+		if err := c.compile(v.Index); err != nil {
+			return err
+		}
+
+		c.emitAt(v, OpIndex)
+	case *ast.MemberExpression:
+		if err := c.compile(v.Object); err != nil {
+			return err
+		}
+
+		c.emitAt(v, OpGetAttr, c.addName(v.Property))
+	default:
+		return fmt.Errorf("%s: cannot compile %T", node.Pos(), node)
+	}
+
+	return nil
+}
+
+// compileBlock compiles the statements of a block in the current scope, the block evaluates to its last statement.
+func (c *Compiler) compileBlock(block *ast.BlockStatement) error {
+	if len(block.Statements) == 0 {
+		c.emit(OpNull)
+		return nil
+	}
+
+	for i, stmt := range block.Statements {
+		if err := c.compile(stmt); err != nil {
+			return err
+		}
+
+		if i < len(block.Statements)-1 {
+			c.emit(OpPop)
+		}
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileInfixExpression(node *ast.InfixExpression) error {
+	var op Opcode
+
+	for opcode, operator := range Operators {
+		if operator == node.Operator {
+			op = opcode
+		}
+	}
+
+	if op == 0 {
+		return fmt.Errorf("%s: unknown operator %s", node.Pos(), node.Operator)
+	}
+
+	if err := c.compile(node.Left); err != nil {
+		return err
+	}
+
+	// the right operand of the logical operators is only evaluated when the left one doesn't decide the result
+	jump := -1
+	if op == OpAnd || op == OpOr {
+		jump = c.emitAt(node, OpShortCircuit, int(op), 0)
+	}
+
+	if err := c.compile(node.Right); err != nil {
+		return err
+	}
+
+	c.emitAt(node, op)
+
+	if jump >= 0 {
+		c.patchJump(jump)
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileAssignExpression(node *ast.AssignExpression) error {
+	var operator Opcode
+
+	if node.Operator != "=" {
+		for opcode, op := range Operators {
+			if op+"=" == node.Operator {
+				operator = opcode
+			}
+		}
+
+		if operator == 0 {
+			return fmt.Errorf("%s: unknown operator %s", node.Pos(), node.Operator)
+		}
+	}
+
+	if err := c.compile(node.Value); err != nil {
+		return err
+	}
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		c.emitAt(node, OpAssign, c.addBinding(c.symbols.Resolve(target.Value)), int(operator))
+	case *ast.IndexExpression:
+		if err := c.compile(target.Left); err != nil {
+			return err
+		}
+
+		if err := c.compile(target.Index); err != nil {
+			return err
+		}
+
+		c.emitAt(node, OpAssignIndex, int(operator))
+	case *ast.MemberExpression:
+		if err := c.compile(target.Object); err != nil {
+			return err
+		}
+
+		c.emitAt(node, OpAssignAttr, c.addName(target.Property), int(operator))
+	default:
+		return fmt.Errorf("%s: cannot assign to %s", node.Pos(), node.Target.String())
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileIfElseExpression(node *ast.IfElseExpression) error {
+	if err := c.compile(node.Condition); err != nil {
+		return err
+	}
+
+	jumpNotTrue := c.emitAt(node, OpJumpNotTrue, int(IfCondition), 0)
+
+	if err := c.compileBlock(node.Consequence); err != nil {
+		return err
+	}
+
+	jump := c.emit(OpJump, 0)
+	c.patchJump(jumpNotTrue)
+
+	if node.Alternative != nil {
+		if err := c.compileBlock(node.Alternative); err != nil {
+			return err
+		}
+	} else {
+		c.emit(OpNull)
+	}
+
+	c.patchJump(jump)
+
+	return nil
+}
+
+// compileMatchExpression compiles the arms in order, each arm enters its own scope, binds the pattern
+// and checks the guard, it jumps to the next arm on failure. The default arm comes last whatever its position.
+func (c *Compiler) compileMatchExpression(node *ast.MatchExpression) error {
+	if err := c.compile(node.Subject); err != nil {
+		return err
+	}
+
+	var fallback *ast.MatchArm
+	var ends []int
+
+	for _, arm := range node.Arms {
+		if arm.Pattern == nil {
+			fallback = arm
+			continue
+		}
+
+		names := append(patternNames(arm.Pattern), declarations(arm.Guard, arm.Body)...)
+		c.enterScope(names...)
+
+		pattern, err := c.compilePattern(arm.Pattern)
+		if err != nil {
+			return err
+		}
+
+		failures := []int{c.emit(OpMatch, c.addPattern(pattern), 0)}
+
+		if arm.Guard != nil {
+			if err := c.compile(arm.Guard); err != nil {
+				return err
+			}
+
+			failures = append(failures, c.emitAt(node, OpJumpNotTrue, int(MatchGuard), 0))
+		}
+
+		if err := c.compileBlock(arm.Body); err != nil {
+			return err
+		}
+
+		c.leaveScope()
+		ends = append(ends, c.emit(OpJump, 0))
+
+		for _, failure := range failures {
+			c.patchJump(failure)
+		}
+
+		// the scope is left by both the matching and the failing paths
+		c.symbols = c.symbols.Outer
+		if len(names) > 0 {
+			c.emit(OpLeaveScope)
+		}
+	}
+
+	if fallback != nil {
+		c.enterScope(declarations(fallback.Body)...)
+
+		if err := c.compileBlock(fallback.Body); err != nil {
+			return err
+		}
+
+		c.leaveScope()
+		c.symbols = c.symbols.Outer
+	} else {
+		c.emitAt(node, OpNoMatch)
+	}
+
+	for _, end := range ends {
+		c.patchJump(end)
+	}
+
+	c.emit(OpMatchEnd)
+
+	return nil
+}
+
+func (c *Compiler) compilePattern(pattern ast.Expression) (*Pattern, error) {
+	switch v := pattern.(type) {
+	case *ast.Identifier:
+		if v.Value == "_" {
+			return &Pattern{Kind: WildcardPattern}, nil
+		}
+
+		return &Pattern{Kind: BindPattern, Slot: c.symbols.Local(v.Value).Index}, nil
+	case *ast.ArrayLiteral:
+		p := &Pattern{Kind: ArrayPattern}
+
+		for _, item := range v.Items {
+			itemPattern, err := c.compilePattern(item)
+			if err != nil {
+				return nil, err
+			}
+
+			p.Items = append(p.Items, itemPattern)
+		}
+
+		return p, nil
+	case *ast.HashLiteral:
+		p := &Pattern{Kind: HashPattern}
+
+		for _, key := range v.Keys() {
+			keyValue, err := literalValue(key)
+			if err != nil {
+				return nil, err
+			}
+
+			valuePattern, err := c.compilePattern(v.Items[key])
+			if err != nil {
+				return nil, err
+			}
+
+			p.Keys = append(p.Keys, keyValue)
+			p.Items = append(p.Items, valuePattern)
+		}
+
+		return p, nil
+	default:
+		value, err := literalValue(pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Pattern{Kind: LiteralPattern, Value: value}, nil
+	}
+}
+
+// literalValue returns the value of a literal pattern
+func literalValue(exp ast.Expression) (object.Object, error) {
+	switch v := exp.(type) {
+	case *ast.IntegerLiteral:
+		return &object.Integer{Value: v.Value}, nil
+	case *ast.BigIntegerLiteral:
+		return &object.BigInt{Value: v.Value}, nil
+	case *ast.FloatLiteral:
+		return &object.Float{Value: v.Value}, nil
+	case *ast.StringLiteral:
+		return &object.String{Value: v.Value}, nil
+	case *ast.BooleanLiteral:
+		if v.Value {
+			return eval.TRUE, nil
+		}
+
+		return eval.FALSE, nil
+	case *ast.PrefixExpression:
+		right, err := literalValue(v.Right)
+		if err != nil {
+			return nil, err
+		}
+
+		return eval.PrefixOperation(v.Operator, right), nil
+	default:
+		return nil, fmt.Errorf("%s: invalid pattern %s", exp.Pos(), exp.String())
+	}
+}
+
+// compileFunctionLiteral compiles the function in its own compilation scope, its parameters
+// and its variables are the slots of the scope created by each call.
+// compileCall compiles the call using OpCall, or OpTailCall for the calls returned by functions
+func (c *Compiler) compileCall(node *ast.CallExpression, op Opcode) error {
+	// the methods of the host objects are bound to their object instead of being read as attributes
+	if member, ok := node.Function.(*ast.MemberExpression); ok {
+		if err := c.compile(member.Object); err != nil {
+			return err
+		}
+
+		c.emitAt(node, OpGetMethod, c.addName(member.Property))
+	} else if err := c.compile(node.Function); err != nil {
+		return err
+	}
+
+	for _, arg := range node.Arguments {
+		if err := c.compile(arg); err != nil {
+			return err
+		}
+	}
+
+	c.emitAt(node, op, len(node.Arguments))
+
+	return nil
+}
+
+func (c *Compiler) compileFunctionLiteral(node *ast.FunctionLiteral) error {
+	var params []string
+	for _, param := range node.Params {
+		params = append(params, param.Value)
+	}
+
+	outer := c.scope
+	c.scope = &compilationScope{function: true}
+	c.symbols = NewEnclosedSymbolTable(c.symbols, append(params, declarations(node.Body)...)...)
+
+	for _, stmt := range node.Body.Statements {
+		if err := c.compile(stmt); err != nil {
+			return err
+		}
+
+		c.emit(OpPop)
+	}
+
+	c.emit(OpReturn)
+
+	fn := &CompiledFunction{
+		Instructions: c.scope.instructions,
+		SourceMap:    c.scope.sourceMap,
+		NumSlots:     c.symbols.Len(),
+		Literal:      node,
+	}
+
+	for _, param := range params {
+		fn.Params = append(fn.Params, c.symbols.Local(param).Index)
+	}
+
+	c.scope = outer
+	c.symbols = c.symbols.Outer
+
+	c.emit(OpClosure, c.addConstant(fn))
+
+	return nil
+}
+
+func (c *Compiler) compileWhileStatement(node *ast.WhileStatement) error {
+	l := c.enterLoop(node.Label)
+
+	c.emit(OpLoop)
+	start := len(c.scope.instructions)
+
+	if err := c.compile(node.Condition); err != nil {
+		return err
+	}
+
+	exit := c.emitAt(node, OpJumpNotTrue, int(LoopCondition), 0)
+
+	if err := c.compileLoopBody(node.Body); err != nil {
+		return err
+	}
+
+	// the back-edges of the loops fail when the run is cancelled
+	c.emitAt(node, OpJump, start)
+	c.patchJump(exit)
+	c.emit(OpLoopEnd)
+
+	c.leaveLoop(l, start)
+	c.emit(OpNull)
+
+	return nil
+}
+
+// compileForStatement compiles the C-style loop, the init statement, the condition and the post expression
+// are compiled in the scope of the loop, and the body in the scope of each iteration.
+func (c *Compiler) compileForStatement(node *ast.ForStatement) error {
+	c.enterScope(declarations(node.Init, node.Condition, node.Post)...)
+
+	if node.Init != nil {
+		if err := c.compile(node.Init); err != nil {
+			return err
+		}
+
+		c.emit(OpPop)
+	}
+
+	l := c.enterLoop(node.Label)
+
+	c.emit(OpLoop)
+	start := len(c.scope.instructions)
+
+	exit := -1
+	if node.Condition != nil {
+		if err := c.compile(node.Condition); err != nil {
+			return err
+		}
+
+		exit = c.emitAt(node, OpJumpNotTrue, int(LoopCondition), 0)
+	}
+
+	if err := c.compileLoopBody(node.Body); err != nil {
+		return err
+	}
+
+	post := len(c.scope.instructions)
+
+	if node.Post != nil {
+		if err := c.compile(node.Post); err != nil {
+			return err
+		}
+
+		c.emit(OpPop)
+	}
+
+	c.emitAt(node, OpJump, start)
+
+	if exit >= 0 {
+		c.patchJump(exit)
+	}
+
+	c.emit(OpLoopEnd)
+	c.leaveLoop(l, post)
+
+	c.leaveScope()
+	c.symbols = c.symbols.Outer
+	c.emit(OpNull)
+
+	return nil
+}
+
+// compileForInStatement compiles the loop over a collection, the iterator stays on the stack during the loop
+// and pushes the value then the key of each iteration, they are stored in the scope of the iteration.
+func (c *Compiler) compileForInStatement(node *ast.ForInStatement) error {
+	if err := c.compile(node.Iterable); err != nil {
+		return err
+	}
+
+	oneVariable := 0
+	if node.Value == nil {
+		oneVariable = 1
+	}
+
+	c.emitAt(node, OpIter, oneVariable)
+
+	l := c.enterLoop(node.Label)
+
+	c.emit(OpLoop)
+	start := len(c.scope.instructions)
+	exit := c.emit(OpIterNext, 0)
+
+	if err := c.compileLoopBody(node.Body, node.Key, node.Value); err != nil {
+		return err
+	}
+
+	c.emitAt(node, OpJump, start)
+	c.patchJump(exit)
+	c.emit(OpLoopEnd)
+
+	c.leaveLoop(l, start)
+	c.emit(OpPop)
+	c.emit(OpNull)
+
+	return nil
+}
+
+// compileLoopBody compiles the body of a loop in the scope of the iteration, the value of the body is discarded.
+// the given variables are declared in that scope and set to the values on top of the stack, the first one
+// to the top value, a nil variable discards its value.
+func (c *Compiler) compileLoopBody(body *ast.BlockStatement, vars ...*ast.Identifier) error {
+	l := c.scope.loops[len(c.scope.loops)-1]
+	l.body = true
+	defer func() { l.body = false }()
+
+	var names []string
+	for _, v := range vars {
+		if v != nil {
+			names = append(names, v.Value)
+		}
+	}
+
+	c.enterScope(append(names, declarations(body)...)...)
+
+	for _, v := range vars {
+		if v == nil {
+			c.emit(OpPop)
+			continue
+		}
+
+		c.emit(OpStore, c.addBinding(&Binding{Name: v.Value, Refs: []Ref{c.symbols.Local(v.Value)}}))
+	}
+
+	if err := c.compileBlock(body); err != nil {
+		return err
+	}
+
+	c.emit(OpPop)
+	c.leaveScope()
+	c.symbols = c.symbols.Outer
+
+	return nil
+}
+
+func (c *Compiler) compileBranchStatement(node *ast.BranchStatement) error {
+	loops := c.scope.loops
+
+	for depth := 0; depth < len(loops); depth++ {
+		l := loops[len(loops)-1-depth]
+
+		if !l.body || (node.Label != nil && node.Label.Value != l.label) {
+			continue
+		}
+
+		if node.Token.Type == token.BREAK {
+			l.breaks = append(l.breaks, c.emit(OpBreak, depth, 0))
+		} else {
+			l.continues = append(l.continues, c.emitAt(node, OpContinue, depth, 0))
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("%s: %s is not in a loop", node.Pos(), node.Token.Literal)
+}
+
+func (c *Compiler) enterLoop(label *ast.Identifier) *loop {
+	l := &loop{}
+	if label != nil {
+		l.label = label.Value
+	}
+
+	c.scope.loops = append(c.scope.loops, l)
+
+	return l
+}
+
+// leaveLoop patches the break instructions to jump to the current address, and the continue instructions to the given address
+func (c *Compiler) leaveLoop(l *loop, next int) {
+	c.scope.loops = c.scope.loops[:len(c.scope.loops)-1]
+
+	for _, pos := range l.breaks {
+		c.changeOperand(pos, 1, len(c.scope.instructions))
+	}
+
+	for _, pos := range l.continues {
+		c.changeOperand(pos, 1, next)
+	}
+}
+
+// enterScope makes the scope declaring the names the current one, the scope only exists at runtime if it declares names.
+// leaveScope emits the matching instruction, the caller restores the symbol table once the scope is left by every path.
+func (c *Compiler) enterScope(names ...string) {
+	c.symbols = NewEnclosedSymbolTable(c.symbols, names...)
+
+	if c.symbols.materialized() {
+		c.emit(OpEnterScope, c.symbols.Len())
+	}
+}
+
+func (c *Compiler) leaveScope() {
+	if c.symbols.materialized() {
+		c.emit(OpLeaveScope)
+	}
+}
+
+func (c *Compiler) emit(op Opcode, operands ...int) int {
+	pos := len(c.scope.instructions)
+	c.scope.instructions = append(c.scope.instructions, Make(op, operands...)...)
+
+	return pos
+}
+
+// emitAt emits an instruction that may fail at runtime, the error is located at the node.
+func (c *Compiler) emitAt(node ast.Node, op Opcode, operands ...int) int {
+	pos := c.emit(op, operands...)
+	c.scope.sourceMap = append(c.scope.sourceMap, Span{Offset: pos, Pos: node.Pos(), End: node.End()})
+
+	return pos
+}
+
+// patchJump makes the jump instruction at the given address jump to the current address,
+// the target is always the last operand.
+func (c *Compiler) patchJump(pos int) {
+	def, _ := Lookup(Opcode(c.scope.instructions[pos]))
+	c.changeOperand(pos, len(def.OperandWidths)-1, len(c.scope.instructions))
+}
+
+func (c *Compiler) changeOperand(pos int, operand int, value int) {
+	op := Opcode(c.scope.instructions[pos])
+	def, _ := Lookup(op)
+
+	operands, _ := ReadOperands(def, c.scope.instructions[pos+1:])
+	operands[operand] = value
+
+	copy(c.scope.instructions[pos:], Make(op, operands...))
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+// addLiteral adds the constant of a literal, the literals of the same value share the same constant.
+func (c *Compiler) addLiteral(value any, obj object.Object) int {
+	key := [2]any{fmt.Sprintf("%T", value), value}
+
+	if index, ok := c.constantIndex[key]; ok {
+		return index
+	}
+
+	c.constantIndex[key] = c.addConstant(obj)
+
+	return c.constantIndex[key]
+}
+
+// addName adds the name of an attribute or a method as a string constant
+func (c *Compiler) addName(name *ast.Identifier) int {
+	return c.addLiteral(name.Value, &object.String{Value: name.Value})
+}
+
+func (c *Compiler) addBinding(b *Binding) int {
+	key := b.String()
+
+	if index, ok := c.bindingIndex[key]; ok {
+		return index
+	}
+
+	c.bindings = append(c.bindings, b)
+	c.bindingIndex[key] = len(c.bindings) - 1
+
+	return len(c.bindings) - 1
+}
+
+func (c *Compiler) addPattern(p *Pattern) int {
+	c.patterns = append(c.patterns, p)
+	return len(c.patterns) - 1
+}
@@ -0,0 +1,240 @@
+package compiler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/yassinebenaid/nishimia/ast"
+	"github.com/yassinebenaid/nishimia/lexer"
+	"github.com/yassinebenaid/nishimia/parser"
+)
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"1 + 2", []string{
+			"0000 OpConstant 0",
+			"0003 OpConstant 1",
+			"0006 OpAdd",
+			"0007 OpReturnValue",
+		}},
+		{"1; 1", []string{
+			"0000 OpConstant 0",
+			"0003 OpPop",
+			"0004 OpConstant 0",
+			"0007 OpReturnValue",
+		}},
+		{"var x = -1; x", []string{
+			"0000 OpConstant 0",
+			"0003 OpMinus",
+			"0004 OpDefine 0 0",
+			"0008 OpNull",
+			"0009 OpPop",
+			"0010 OpGetVar 0",
+			"0013 OpReturnValue",
+		}},
+		{"const x = 1; x += 2", []string{
+			"0000 OpConstant 0",
+			"0003 OpDefine 0 1",
+			"0007 OpNull",
+			"0008 OpPop",
+			"0009 OpConstant 1",
+			"0012 OpAssign 0 5",
+			"0016 OpReturnValue",
+		}},
+		{"true && false", []string{
+			"0000 OpTrue",
+			"0001 OpShortCircuit 16 9",
+			"0007 OpFalse",
+			"0008 OpAnd",
+			"0009 OpReturnValue",
+		}},
+		{"if true { 1 }", []string{
+			"0000 OpTrue",
+			"0001 OpJumpNotTrue 0 15",
+			"0007 OpConstant 0",
+			"0010 OpJump 16",
+			"0015 OpNull",
+			"0016 OpReturnValue",
+		}},
+		{`{"a": [1]}["a"]`, []string{
+			"0000 OpHash",
+			"0001 OpConstant 0",
+			"0004 OpConstant 1",
+			"0007 OpArray 1",
+			"0010 OpHashPut",
+			"0011 OpCheckIndex",
+			"0012 OpConstant 0",
+			"0015 OpIndex",
+			"0016 OpReturnValue",
+		}},
+		{"x.a += 2; x.b(x.a)", []string{
+			"0000 OpConstant 0",
+			"0003 OpGetVar 0",
+			"0006 OpAssignAttr 1 5",
+			"0010 OpPop",
+			"0011 OpGetVar 0",
+			"0014 OpGetMethod 2",
+			"0017 OpGetVar 0",
+			"0020 OpGetAttr 1",
+			"0023 OpCall 1",
+			"0026 OpReturnValue",
+		}},
+		{"while true { break; }", []string{
+			"0000 OpLoop",
+			"0001 OpTrue",
+			"0002 OpJumpNotTrue 1 21",
+			"0008 OpBreak 0 22",
+			"0015 OpPop",
+			"0016 OpJump 1",
+			"0021 OpLoopEnd",
+			"0022 OpNull",
+			"0023 OpReturnValue",
+		}},
+		{"for x in [] { continue; }", []string{
+			"0000 OpArray 0",
+			"0003 OpIter 1",
+			"0005 OpLoop",
+			"0006 OpIterNext 32",
+			"0011 OpEnterScope 1",
+			"0014 OpStore 0",
+			"0017 OpPop",
+			"0018 OpContinue 0 6",
+			"0025 OpPop",
+			"0026 OpLeaveScope",
+			"0027 OpJump 6",
+			"0032 OpLoopEnd",
+			"0033 OpPop",
+			"0034 OpNull",
+			"0035 OpReturnValue",
+		}},
+	}
+
+	for _, tt := range tests {
+		bytecode, err := Compile(parse(t, tt.input))
+		if err != nil {
+			t.Fatalf("failed to compile %q: %s", tt.input, err)
+		}
+
+		expected := strings.Join(tt.expected, "\n") + "\n"
+
+		if bytecode.Instructions.String() != expected {
+			t.Errorf("wrong instructions for %q.\nexpected=\n%s\ngot=\n%s", tt.input, expected, bytecode.Instructions)
+		}
+	}
+}
+
+func TestCompileFunctions(t *testing.T) {
+	bytecode, err := Compile(parse(t, "var add = func(x, y) { var z = x + y; return z; }; add(1, 2)"))
+	if err != nil {
+		t.Fatalf("failed to compile: %s", err)
+	}
+
+	fn, ok := bytecode.Constants[0].(*CompiledFunction)
+	if !ok {
+		t.Fatalf("constant is not a compiled function. got=%T", bytecode.Constants[0])
+	}
+
+	if fn.NumSlots != 3 {
+		t.Errorf("wrong number of slots. expected=3, got=%d", fn.NumSlots)
+	}
+
+	if len(fn.Params) != 2 || fn.Params[0] != 0 || fn.Params[1] != 1 {
+		t.Errorf("wrong parameter slots. got=%v", fn.Params)
+	}
+
+	if fn.Inspect() != "func(x, y){var z = (x + y);return z;}" {
+		t.Errorf("wrong representation. got=%s", fn.Inspect())
+	}
+
+	expected := `0000 OpGetVar 0
+0003 OpGetVar 1
+0006 OpAdd
+0007 OpDefine 2 0
+0011 OpNull
+0012 OpPop
+0013 OpGetVar 3
+0016 OpReturnValue
+0017 OpPop
+0018 OpReturn
+`
+
+	if fn.Instructions.String() != expected {
+		t.Errorf("wrong instructions.\nexpected=\n%s\ngot=\n%s", expected, fn.Instructions)
+	}
+}
+
+func TestCompileTailCalls(t *testing.T) {
+	bytecode, err := Compile(parse(t, "var f = func(n) { return f(n); }; return f(1);"))
+	if err != nil {
+		t.Fatalf("failed to compile: %s", err)
+	}
+
+	fn := bytecode.Constants[0].(*CompiledFunction)
+
+	if !strings.Contains(fn.Instructions.String(), "OpTailCall 1\n") {
+		t.Errorf("expected the call returned by the function to be a tail call, got=\n%s", fn.Instructions)
+	}
+
+	// the program is not a function, it has no caller to replace
+	if strings.Contains(bytecode.Instructions.String(), "OpTailCall") {
+		t.Errorf("expected the call returned by the program not to be a tail call, got=\n%s", bytecode.Instructions)
+	}
+}
+
+func TestBindings(t *testing.T) {
+	tests := []struct {
+		input    string
+		name     string
+		expected string
+	}{
+		{"var x = 1; x", "x", "x[global:0]"},
+		{"var f = func(x) { return x; };", "x", "x[0:0 global:1]"},
+		{"var f = func() { return y; };", "y", "y[global:1]"},
+		{"var x = 1; var f = func() { return func() { return x; }; };", "x", "x[global:0]"},
+		{"var f = func(x) { return func() { return x; }; };", "x", "x[0:0 global:1]"},
+		{"var f = func(x) { return func(y) { return x; }; };", "x", "x[1:0 global:1]"},
+		{"var f = func(x) { while true { return func() { return x; }; } };", "x", "x[0:0 global:1]"},
+		{"var f = func(x) { for y in [] { return func() { return x; }; } };", "x", "x[1:0 global:1]"},
+		{"var f = func(x) { var y = x; return match y { case [x] { x } }; };", "x", "x[0:0 1:0 global:1]"},
+	}
+
+	for _, tt := range tests {
+		bytecode, err := Compile(parse(t, tt.input))
+		if err != nil {
+			t.Fatalf("failed to compile %q: %s", tt.input, err)
+		}
+
+		var bindings []string
+		for _, b := range bytecode.Bindings {
+			if b.Name == tt.name {
+				bindings = append(bindings, b.String())
+			}
+		}
+
+		if bindings[len(bindings)-1] != tt.expected {
+			t.Errorf("wrong binding for %q. expected=%s, got=%v", tt.input, tt.expected, bindings)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	program := &ast.Program{Statements: []ast.Statement{&ast.BadStatement{}}}
+
+	if _, err := Compile(program); err == nil {
+		t.Errorf("expected an error for a bad statement")
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+
+	if len(p.Errors()) > 0 {
+		t.Fatalf("failed to parse %q: %v", input, p.Errors())
+	}
+
+	return program
+}
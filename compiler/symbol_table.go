@@ -0,0 +1,214 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yassinebenaid/nishimia/ast"
+)
+
+// Ref locates the slot of a variable at runtime
+type Ref struct {
+	Global bool // the slot belongs to the global scope
+	Depth  int  // the number of scopes to go up from the current one, unused for globals
+	Index  int  // the index of the slot in its scope
+}
+
+// Binding describes the variable an identifier refers to. Refs lists the slots of the scopes
+// declaring the name, from the innermost to the outermost, the variable is the first slot that
+// is set at runtime. This mirrors the environments of the evaluator where a name only shadows
+// the outer ones once it is defined.
+type Binding struct {
+	Name string
+	Refs []Ref
+}
+
+func (b *Binding) String() string {
+	var refs []string
+
+	for _, ref := range b.Refs {
+		if ref.Global {
+			refs = append(refs, fmt.Sprintf("global:%d", ref.Index))
+		} else {
+			refs = append(refs, fmt.Sprintf("%d:%d", ref.Depth, ref.Index))
+		}
+	}
+
+	return b.Name + "[" + strings.Join(refs, " ") + "]"
+}
+
+// SymbolTable holds the names declared in a scope and their slots, a scope is created for
+// the program, each function call, each loop iteration and each match arm, just like the
+// environments of the evaluator. The names are declared before the scope is compiled so the
+// functions can refer to the variables defined after them.
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store  map[string]int
+	global bool
+}
+
+// NewSymbolTable returns the table of the global scope
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{store: make(map[string]int), global: true}
+}
+
+// NewEnclosedSymbolTable returns the table of a scope declaring the given names
+func NewEnclosedSymbolTable(outer *SymbolTable, names ...string) *SymbolTable {
+	s := &SymbolTable{Outer: outer, store: make(map[string]int)}
+
+	for _, name := range names {
+		s.Define(name)
+	}
+
+	return s
+}
+
+// Define declares the name in the scope and returns its slot, declaring a name twice returns the same slot.
+func (s *SymbolTable) Define(name string) int {
+	if index, ok := s.store[name]; ok {
+		return index
+	}
+
+	s.store[name] = len(s.store)
+
+	return s.store[name]
+}
+
+// Len returns the number of slots of the scope
+func (s *SymbolTable) Len() int {
+	return len(s.store)
+}
+
+// Local returns the reference to the slot of a name declared in this scope
+func (s *SymbolTable) Local(name string) Ref {
+	return Ref{Global: s.global, Index: s.store[name]}
+}
+
+// Resolve returns the binding of the name seen from this scope. The global scope is always
+// part of the binding, the name is declared in it when no scope declares it, so it resolves
+// to the variables defined later by other programs sharing the same global scope.
+func (s *SymbolTable) Resolve(name string) *Binding {
+	b := &Binding{Name: name}
+	depth := 0
+
+	for table := s; table != nil; table = table.Outer {
+		if table.global {
+			b.Refs = append(b.Refs, Ref{Global: true, Index: table.Define(name)})
+			break
+		}
+
+		if index, ok := table.store[name]; ok {
+			b.Refs = append(b.Refs, Ref{Depth: depth, Index: index})
+		}
+
+		if table.materialized() {
+			depth++
+		}
+	}
+
+	return b
+}
+
+// materialized reports whether the scope exists at runtime, scopes that declare no names are skipped.
+func (s *SymbolTable) materialized() bool {
+	return s.global || len(s.store) > 0
+}
+
+// declarations returns the names declared by the var statements that belong to the scope of the given nodes,
+// the function bodies, the loop bodies and the match arms have their own scopes so they are not visited.
+func declarations(nodes ...ast.Node) []string {
+	var names []string
+
+	var visit func(node ast.Node)
+	visit = func(node ast.Node) {
+		switch v := node.(type) {
+		case *ast.Program:
+			for _, stmt := range v.Statements {
+				visit(stmt)
+			}
+		case *ast.VarStatement:
+			visit(v.Value)
+			names = append(names, v.Name.Value)
+		case *ast.ExpressionStatement:
+			visit(v.Expression)
+		case *ast.ReturnStatement:
+			visit(v.Return)
+		case *ast.BlockStatement:
+			for _, stmt := range v.Statements {
+				visit(stmt)
+			}
+		case *ast.IfElseExpression:
+			visit(v.Condition)
+			visit(v.Consequence)
+
+			if v.Alternative != nil {
+				visit(v.Alternative)
+			}
+		case *ast.PrefixExpression:
+			visit(v.Right)
+		case *ast.InfixExpression:
+			visit(v.Left)
+			visit(v.Right)
+		case *ast.AssignExpression:
+			visit(v.Value)
+			visit(v.Target)
+		case *ast.CallExpression:
+			visit(v.Function)
+
+			for _, arg := range v.Arguments {
+				visit(arg)
+			}
+		case *ast.ArrayLiteral:
+			for _, item := range v.Items {
+				visit(item)
+			}
+		case *ast.HashLiteral:
+			for _, key := range v.Keys() {
+				visit(key)
+				visit(v.Items[key])
+			}
+		case *ast.IndexExpression:
+			visit(v.Left)
+			visit(v.Index)
+		case *ast.MemberExpression:
+			visit(v.Object)
+		case *ast.MatchExpression:
+			visit(v.Subject)
+		case *ast.WhileStatement:
+			visit(v.Condition)
+		case *ast.ForInStatement:
+			visit(v.Iterable)
+		}
+	}
+
+	for _, node := range nodes {
+		if node != nil {
+			visit(node)
+		}
+	}
+
+	return names
+}
+
+// patternNames returns the names bound by a pattern of a match expression
+func patternNames(pattern ast.Expression) []string {
+	var names []string
+
+	switch v := pattern.(type) {
+	case *ast.Identifier:
+		if v.Value != "_" {
+			names = append(names, v.Value)
+		}
+	case *ast.ArrayLiteral:
+		for _, item := range v.Items {
+			names = append(names, patternNames(item)...)
+		}
+	case *ast.HashLiteral:
+		for _, key := range v.Keys() {
+			names = append(names, patternNames(v.Items[key])...)
+		}
+	}
+
+	return names
+}
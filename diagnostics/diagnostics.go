@@ -0,0 +1,242 @@
+package diagnostics
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yassinebenaid/nishimia/object"
+	"github.com/yassinebenaid/nishimia/parser"
+	"github.com/yassinebenaid/nishimia/token"
+)
+
+// Kind labels the stage at which a diagnostic was produced
+type Kind string
+
+const (
+	SyntaxError  Kind = "syntax error"
+	RuntimeError Kind = "runtime error"
+)
+
+// Mode controls how the diagnostics are rendered
+type Mode int
+
+const (
+	Plain Mode = iota // plain text
+	Color             // text decorated with ANSI colours
+	JSON              // a JSON array, one object per diagnostic
+)
+
+// Diagnostic is a single problem found in the source code, it spans from Pos to End.
+type Diagnostic struct {
+	Kind    Kind
+	Message string
+	Pos     token.Position
+	End     token.Position
+	Notes   []string
+}
+
+// FromParserErrors converts the errors reported by the parser into diagnostics
+func FromParserErrors(errs []*parser.Error) []*Diagnostic {
+	var diags []*Diagnostic
+
+	for _, err := range errs {
+		diags = append(diags, &Diagnostic{
+			Kind:    SyntaxError,
+			Message: err.Msg,
+			Pos:     err.Pos,
+			End:     err.End,
+		})
+	}
+
+	return diags
+}
+
+// FromRuntimeError converts an error produced while evaluating into a diagnostic
+func FromRuntimeError(err *object.Error) *Diagnostic {
+	d := &Diagnostic{
+		Kind:    RuntimeError,
+		Message: err.Message,
+		Pos:     err.Pos,
+		End:     err.End,
+	}
+
+	if len(err.Stack) > 0 {
+		d.Notes = append(d.Notes, "call stack:\n    "+strings.ReplaceAll(err.StackTrace(), "\n", "\n    "))
+	}
+
+	if err.GoStack != "" {
+		d.Notes = append(d.Notes, "this is a bug in the interpreter, Go stack trace:\n"+err.GoStack)
+	}
+
+	return d
+}
+
+// ModeFor returns Color if w is a terminal, Plain otherwise.
+func ModeFor(w io.Writer) Mode {
+	f, ok := w.(*os.File)
+	if !ok {
+		return Plain
+	}
+
+	info, err := f.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return Plain
+	}
+
+	return Color
+}
+
+// Render writes the diagnostics to w, src is the source code they refer to
+// and is used to show the offending lines.
+func Render(w io.Writer, mode Mode, src string, diags ...*Diagnostic) error {
+	if mode == JSON {
+		return renderJSON(w, src, diags)
+	}
+
+	var out bytes.Buffer
+
+	for _, d := range diags {
+		renderText(&out, mode == Color, src, d)
+	}
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+const (
+	bold  = "1"
+	red   = "1;31"
+	blue  = "1;34"
+	reset = "\x1b[0m"
+)
+
+func renderText(out *bytes.Buffer, color bool, src string, d *Diagnostic) {
+	paint := func(style string, s string) string {
+		if !color {
+			return s
+		}
+		return "\x1b[" + style + "m" + s + reset
+	}
+
+	out.WriteString(paint(red, string(d.Kind)))
+	out.WriteString(paint(bold, ": "+d.Message))
+	out.WriteString("\n")
+
+	line, ok := sourceLine(src, d.Pos)
+	gutter := strings.Repeat(" ", len(strconv.Itoa(d.Pos.Line)))
+
+	if d.Pos.IsValid() {
+		out.WriteString(gutter + paint(blue, "--> ") + d.Pos.String() + "\n")
+	}
+
+	if ok {
+		out.WriteString(paint(blue, gutter+" |") + "\n")
+		out.WriteString(paint(blue, strconv.Itoa(d.Pos.Line)+" |") + " " + line + "\n")
+		out.WriteString(paint(blue, gutter+" |") + " " + indent(line, d.Pos.Column))
+		out.WriteString(paint(red, strings.Repeat("^", underline(line, d.Pos, d.End))) + "\n")
+	}
+
+	for _, note := range d.Notes {
+		out.WriteString(paint(blue, gutter+" = ") + paint(bold, "note") + ": " + note + "\n")
+	}
+
+	out.WriteString("\n")
+}
+
+// sourceLine returns the line of src that pos points to, without the line terminator.
+func sourceLine(src string, pos token.Position) (string, bool) {
+	if !pos.IsValid() {
+		return "", false
+	}
+
+	lines := strings.Split(src, "\n")
+	if pos.Line > len(lines) {
+		return "", false
+	}
+
+	// a position past the end of its line comes from another source, like a line the repl no longer keeps
+	line := strings.TrimSuffix(lines[pos.Line-1], "\r")
+	if pos.Column > utf8.RuneCountInString(line)+1 {
+		return "", false
+	}
+
+	return line, true
+}
+
+// indent returns the padding that aligns a caret under the given column,
+// tabs are kept so the caret lines up whatever the tab width is.
+func indent(line string, column int) string {
+	var pad strings.Builder
+
+	for i, r := range []rune(line) {
+		if i >= column-1 {
+			break
+		}
+
+		if r == '\t' {
+			pad.WriteRune('\t')
+		} else {
+			pad.WriteRune(' ')
+		}
+	}
+
+	return pad.String()
+}
+
+// underline returns the number of carets to put under the span,
+// spans that continue on the next lines are underlined until the end of the first line.
+func underline(line string, pos, end token.Position) int {
+	n := 1
+
+	if end.IsValid() && end.Line == pos.Line && end.Column > pos.Column {
+		n = end.Column - pos.Column
+	} else if end.Line > pos.Line {
+		n = utf8.RuneCountInString(line) - pos.Column + 1
+	}
+
+	return max(n, 1)
+}
+
+type jsonDiagnostic struct {
+	Kind      Kind     `json:"kind"`
+	Message   string   `json:"message"`
+	File      string   `json:"file,omitempty"`
+	Line      int      `json:"line"`
+	Column    int      `json:"column"`
+	EndLine   int      `json:"end_line"`
+	EndColumn int      `json:"end_column"`
+	Source    string   `json:"source,omitempty"`
+	Notes     []string `json:"notes,omitempty"`
+}
+
+func renderJSON(w io.Writer, src string, diags []*Diagnostic) error {
+	items := make([]jsonDiagnostic, 0, len(diags))
+
+	for _, d := range diags {
+		line, _ := sourceLine(src, d.Pos)
+
+		items = append(items, jsonDiagnostic{
+			Kind:      d.Kind,
+			Message:   d.Message,
+			File:      d.Pos.Filename,
+			Line:      d.Pos.Line,
+			Column:    d.Pos.Column,
+			EndLine:   d.End.Line,
+			EndColumn: d.End.Column,
+			Source:    line,
+			Notes:     d.Notes,
+		})
+	}
+
+	if err := json.NewEncoder(w).Encode(items); err != nil {
+		return fmt.Errorf("failed to encode diagnostics, %w", err)
+	}
+
+	return nil
+}
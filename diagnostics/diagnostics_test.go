@@ -0,0 +1,133 @@
+package diagnostics
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/yassinebenaid/nishimia/eval"
+	"github.com/yassinebenaid/nishimia/lexer"
+	"github.com/yassinebenaid/nishimia/object"
+	"github.com/yassinebenaid/nishimia/parser"
+)
+
+func TestRenderParserErrors(t *testing.T) {
+	src := "var x = 1;\nvar = 5;"
+
+	par := parser.New(lexer.NewFile("main.ns", src))
+	par.ParseProgram()
+
+	var out bytes.Buffer
+	if err := Render(&out, Plain, src, FromParserErrors(par.Errors())[0]); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `syntax error: unexpected token  "=" , expected "IDENT"
+ --> main.ns:2:5
+  |
+2 | var = 5;
+  |     ^
+
+`
+
+	if out.String() != expected {
+		t.Fatalf("wrong output, expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestRenderRuntimeError(t *testing.T) {
+	src := "var f = func() {\n\treturn \"a\" - \"b\";\n};\nf();"
+
+	program := parser.New(lexer.NewFile("main.ns", src)).ParseProgram()
+	result := eval.Eval(program, object.NewEnvirement())
+
+	err, ok := result.(*object.Error)
+	if !ok {
+		t.Fatalf("expected evaluation to yield an error, got=%T", result)
+	}
+
+	d := FromRuntimeError(err)
+	d.Notes = append(d.Notes, "strings only support +")
+
+	var out bytes.Buffer
+	Render(&out, Plain, src, d)
+
+	expected := "runtime error: invalid operation: a - b\n" +
+		" --> main.ns:2:9\n" +
+		"  |\n" +
+		"2 | \treturn \"a\" - \"b\";\n" +
+		"  | \t       ^^^^^^^^^\n" +
+		"  = note: call stack:\n" +
+		"    at f() main.ns:4:1\n" +
+		"  = note: strings only support +\n\n"
+
+	if out.String() != expected {
+		t.Fatalf("wrong output, expected:\n%q\ngot:\n%q", expected, out.String())
+	}
+}
+
+func TestRenderUnknownSource(t *testing.T) {
+	src := "var f = func() { return 1 / 0; }; f()"
+	result := eval.Eval(parser.New(lexer.New(src)).ParseProgram(), object.NewEnvirement())
+
+	var out bytes.Buffer
+	Render(&out, Plain, "", FromRuntimeError(result.(*object.Error)))
+
+	if strings.Contains(out.String(), "^") {
+		t.Fatalf("expected no snippet without the source, got %q", out.String())
+	}
+}
+
+func TestRenderColor(t *testing.T) {
+	src := "5 + true"
+	result := eval.Eval(parser.New(lexer.New(src)).ParseProgram(), object.NewEnvirement())
+
+	var out bytes.Buffer
+	Render(&out, Color, src, FromRuntimeError(result.(*object.Error)))
+
+	if !strings.Contains(out.String(), "\x1b[1;31mruntime error\x1b[0m") {
+		t.Fatalf("expected the kind to be coloured, got %q", out.String())
+	}
+
+	if !strings.Contains(out.String(), "\x1b[1;31m^^^^^^^^\x1b[0m") {
+		t.Fatalf("expected the span to be underlined, got %q", out.String())
+	}
+}
+
+func TestRenderJSON(t *testing.T) {
+	src := "var = 5;"
+
+	par := parser.New(lexer.NewFile("main.ns", src))
+	par.ParseProgram()
+
+	var out bytes.Buffer
+	if err := Render(&out, JSON, src, FromParserErrors(par.Errors())...); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var items []map[string]any
+	if err := json.Unmarshal(out.Bytes(), &items); err != nil {
+		t.Fatalf("output is not valid json: %v", err)
+	}
+
+	if len(items) == 0 {
+		t.Fatalf("expected at least one diagnostic")
+	}
+
+	expected := map[string]any{
+		"kind":       "syntax error",
+		"file":       "main.ns",
+		"line":       float64(1),
+		"column":     float64(5),
+		"end_line":   float64(1),
+		"end_column": float64(6),
+		"source":     "var = 5;",
+	}
+
+	for k, v := range expected {
+		if items[0][k] != v {
+			t.Errorf("expected %s to be %v, got=%v", k, v, items[0][k])
+		}
+	}
+}
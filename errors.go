@@ -0,0 +1,38 @@
+package nishimia
+
+import (
+	"strings"
+
+	"github.com/yassinebenaid/nishimia/object"
+	"github.com/yassinebenaid/nishimia/parser"
+)
+
+// SyntaxError reports the errors found while parsing a program
+type SyntaxError struct {
+	Errors []*parser.Error
+}
+
+func (e *SyntaxError) Error() string {
+	var messages []string
+
+	for _, err := range e.Errors {
+		messages = append(messages, err.Error())
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+// RuntimeError reports the error a program failed with, the error object holds its position
+// and the call stack leading to it. It wraps the Go error that caused it if there is one,
+// so the cancelled runs can be told apart using errors.Is(err, context.Canceled).
+type RuntimeError struct {
+	Value *object.Error
+}
+
+func (e *RuntimeError) Error() string {
+	return e.Value.Error()
+}
+
+func (e *RuntimeError) Unwrap() error {
+	return e.Value.Cause
+}
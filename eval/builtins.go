@@ -1,23 +1,282 @@
 package eval
 
-import "github.com/yassinebenaid/nishimia/object"
-
-var builtins = map[string]*object.Builtin{
-	"len": {
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("invalid arguments count in function call, expected 1 argumets, got %d ",
-					len(args),
-				)
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/yassinebenaid/nishimia/object"
+)
+
+// Variadic is the arity of the builtins taking any number of arguments
+const Variadic = -1
+
+// BuiltinInfo describes a builtin function of a registry
+type BuiltinInfo struct {
+	Name  string
+	Arity int // the number of arguments, or Variadic
+	Doc   string
+}
+
+type builtin struct {
+	info    BuiltinInfo
+	fn      object.RuntimeFunction // the function as registered, without the arity check
+	runtime bool                   // whether the function uses the interpreter running it
+	object  *object.Builtin
+}
+
+// Builtins is a registry of the builtin functions the programs can call, the identifiers the programs
+// don't define resolve to its builtins. Every evaluator can have its own registry, so the embedders
+// can add, remove and override builtins without affecting each other. It is safe for concurrent use.
+type Builtins struct {
+	mu       sync.RWMutex
+	builtins map[string]*builtin
+}
+
+// NewBuiltins returns an empty registry
+func NewBuiltins() *Builtins {
+	return &Builtins{builtins: make(map[string]*builtin)}
+}
+
+// DefaultBuiltins returns a new registry holding the builtins of the language
+func DefaultBuiltins() *Builtins {
+	b := NewBuiltins()
+	registerDefaults(b)
+	return b
+}
+
+// defaultBuiltins are used by the evaluators whose options have no registry, it is never modified
+var defaultBuiltins = DefaultBuiltins()
+
+func (o Options) builtins() *Builtins {
+	if o.Builtins != nil {
+		return o.Builtins
+	}
+
+	return defaultBuiltins
+}
+
+// Register adds the builtin to the registry, replacing the builtin with the same name if there is one.
+// The calls with a number of arguments other than the arity fail before reaching the function.
+func (b *Builtins) Register(name string, arity int, doc string, fn object.BuiltinFunction) {
+	b.register(BuiltinInfo{Name: name, Arity: arity, Doc: doc}, func(_ object.Runtime, args ...object.Object) object.Object {
+		return fn(args...)
+	}, false)
+}
+
+// RegisterRuntime adds a builtin using the interpreter running it, to call back the functions it receives like
+// map does, or to account for the values it creates. The runtime is the evaluator or the vm calling the builtin.
+// The builtin can still be called from Go as a plain function, its runtime then calls the functions with Apply
+// and doesn't limit the allocations.
+func (b *Builtins) RegisterRuntime(name string, arity int, doc string, fn object.RuntimeFunction) {
+	b.register(BuiltinInfo{Name: name, Arity: arity, Doc: doc}, fn, true)
+}
+
+func (b *Builtins) register(info BuiltinInfo, fn object.RuntimeFunction, runtime bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.builtins[info.Name] = newBuiltin(info, fn, runtime)
+}
+
+func newBuiltin(info BuiltinInfo, fn object.RuntimeFunction, runtime bool) *builtin {
+	checked := fn
+	if info.Arity != Variadic {
+		checked = func(rt object.Runtime, args ...object.Object) object.Object {
+			if len(args) != info.Arity {
+				return ArgumentsCountError(info.Arity, len(args))
 			}
 
-			if str, ok := args[0].(*object.String); ok {
-				return &object.Integer{
-					Value: int64(len(str.Value)),
-				}
+			return fn(rt, args...)
+		}
+	}
+
+	obj := &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		return checked(goRuntime{}, args...)
+	}}
+
+	if runtime {
+		obj.Runtime = checked
+	}
+
+	return &builtin{info: info, fn: fn, runtime: runtime, object: obj}
+}
+
+// goRuntime is the runtime of the builtins called from Go as plain functions
+type goRuntime struct{}
+
+func (goRuntime) Call(fn object.Object, args ...object.Object) object.Object {
+	result, err := Apply(context.Background(), fn, args...)
+	if err != nil {
+		return err.(*object.Error)
+	}
+
+	return result
+}
+
+func (goRuntime) Allocate(items int, bytes int64) *object.Error {
+	return nil
+}
+
+// Wrap replaces the function of the builtin by the one returned by the wrapper, which receives the current
+// function. It allows adding behaviour around a builtin, like logging its calls or validating its arguments.
+// The builtins using the interpreter are wrapped at every call, since their function depends on the runtime.
+func (b *Builtins) Wrap(name string, wrapper func(fn object.BuiltinFunction) object.BuiltinFunction) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	current, ok := b.builtins[name]
+	if !ok {
+		return fmt.Errorf("undefined builtin %s", name)
+	}
+
+	fn := current.fn
+	if !current.runtime {
+		wrapped := wrapper(func(args ...object.Object) object.Object { return fn(nil, args...) })
+
+		b.builtins[name] = newBuiltin(current.info, func(_ object.Runtime, args ...object.Object) object.Object {
+			return wrapped(args...)
+		}, false)
+
+		return nil
+	}
+
+	b.builtins[name] = newBuiltin(current.info, func(rt object.Runtime, args ...object.Object) object.Object {
+		return wrapper(func(args ...object.Object) object.Object { return fn(rt, args...) })(args...)
+	}, true)
+
+	return nil
+}
+
+// Remove removes the builtins with the given names, the names that are not registered are ignored
+func (b *Builtins) Remove(names ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, name := range names {
+		delete(b.builtins, name)
+	}
+}
+
+// Lookup returns the builtin with the given name
+func (b *Builtins) Lookup(name string) (*object.Builtin, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if builtin, ok := b.builtins[name]; ok {
+		return builtin.object, true
+	}
+
+	return nil, false
+}
+
+// Info returns the description of the builtin with the given name
+func (b *Builtins) Info(name string) (BuiltinInfo, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if builtin, ok := b.builtins[name]; ok {
+		return builtin.info, true
+	}
+
+	return BuiltinInfo{}, false
+}
+
+// Names returns the sorted names of the builtins
+func (b *Builtins) Names() []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	names := make([]string, 0, len(b.builtins))
+	for name := range b.builtins {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// Clone returns a copy of the registry, the changes of either don't affect the other
+func (b *Builtins) Clone() *Builtins {
+	return b.Restrict(b.Names()...)
+}
+
+// Restrict returns a new registry holding only the builtins with the given names,
+// like the builtins a tenant is allowed to call. The names that are not registered are ignored.
+func (b *Builtins) Restrict(names ...string) *Builtins {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	restricted := NewBuiltins()
+	for _, name := range names {
+		if builtin, ok := b.builtins[name]; ok {
+			restricted.builtins[name] = builtin
+		}
+	}
+
+	return restricted
+}
+
+func registerDefaults(b *Builtins) {
+	b.Register("len", 1, "len(x) returns the number of characters of the string x, or the number of items of the array or the hash x", func(args ...object.Object) object.Object {
+		switch arg := args[0].(type) {
+		case *object.String:
+			return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+		case *object.Array:
+			return &object.Integer{Value: int64(len(arg.Items))}
+		case *object.Hash:
+			return &object.Integer{Value: int64(len(arg.Items))}
+		default:
+			return newError("argument to `len` not supported, got %s", arg.Type())
+		}
+	})
+
+	b.Register("int", 1, "int(x) converts the float or the string x to an integer", func(args ...object.Object) object.Object {
+		switch arg := args[0].(type) {
+		case *object.Integer, *object.BigInt:
+			return arg
+		case *object.Float:
+			if math.IsNaN(arg.Value) || math.IsInf(arg.Value, 0) {
+				return newError("cannot convert %s to INTEGER", arg.Inspect())
+			}
+
+			i, _ := big.NewFloat(arg.Value).Int(nil)
+			return bigIntObject(i)
+		case *object.String:
+			i, ok := new(big.Int).SetString(arg.Value, 10)
+			if !ok {
+				return newError("cannot convert %q to INTEGER", arg.Value)
 			}
 
-			return newError("argument to `len` not supported, got INTEGER")
-		},
-	},
+			return bigIntObject(i)
+		default:
+			return newError("argument to `int` not supported, got %s", arg.Type())
+		}
+	})
+
+	b.Register("float", 1, "float(x) converts the integer or the string x to a float", func(args ...object.Object) object.Object {
+		switch arg := args[0].(type) {
+		case *object.Integer, *object.BigInt:
+			return &object.Float{Value: toFloat(arg)}
+		case *object.Float:
+			return arg
+		case *object.String:
+			f, err := strconv.ParseFloat(arg.Value, 64)
+			if err != nil {
+				return newError("cannot convert %q to FLOAT", arg.Value)
+			}
+
+			return &object.Float{Value: f}
+		default:
+			return newError("argument to `float` not supported, got %s", arg.Type())
+		}
+	})
+
+	registerStringBuiltins(b)
+	registerCollectionBuiltins(b)
 }
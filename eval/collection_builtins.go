@@ -0,0 +1,677 @@
+package eval
+
+import (
+	"sort"
+
+	"github.com/yassinebenaid/nishimia/object"
+)
+
+// maxArrayLength is the length of the longest array the builtins create, like maxStringLength for the strings
+const maxArrayLength = 1 << 24
+
+// registerCollectionBuiltins adds the builtins working on arrays and hashes. They return new collections and leave
+// their arguments as they are, the variants suffixed with _mut modify the collection they are given instead and
+// return it, so a builtin and its variant always mean the same change.
+func registerCollectionBuiltins(b *Builtins) {
+	b.RegisterRuntime("push", 2, "push(arr, item) returns a copy of arr with item added at its end", func(rt object.Runtime, args ...object.Object) object.Object {
+		return withArray("push", args, func(arr *object.Array) object.Object {
+			if err := allocateArray(rt, len(arr.Items)+1); err != nil {
+				return err
+			}
+
+			return &object.Array{Items: append(copyItems(arr.Items), args[1])}
+		})
+	})
+
+	b.RegisterRuntime("push_mut", 2, "push_mut(arr, item) adds item at the end of arr, it returns arr", func(rt object.Runtime, args ...object.Object) object.Object {
+		return withArray("push_mut", args, func(arr *object.Array) object.Object {
+			if err := rt.Allocate(1, itemSize); err != nil {
+				return err
+			}
+
+			arr.Items = append(arr.Items, args[1])
+			return arr
+		})
+	})
+
+	b.RegisterRuntime("pop", 1, "pop(arr) returns a copy of arr without its last item", func(rt object.Runtime, args ...object.Object) object.Object {
+		return withArray("pop", args, func(arr *object.Array) object.Object {
+			if len(arr.Items) == 0 {
+				return newArray(rt, nil)
+			}
+
+			return newArray(rt, arr.Items[:len(arr.Items)-1])
+		})
+	})
+
+	b.Register("pop_mut", 1, "pop_mut(arr) removes the last item of arr, it returns arr. The item can be read with last before", func(args ...object.Object) object.Object {
+		return withArray("pop_mut", args, func(arr *object.Array) object.Object {
+			if len(arr.Items) > 0 {
+				arr.Items = arr.Items[:len(arr.Items)-1]
+			}
+
+			return arr
+		})
+	})
+
+	b.Register("first", 1, "first(arr) returns the first item of arr, or null if arr is empty", func(args ...object.Object) object.Object {
+		return withArray("first", args, func(arr *object.Array) object.Object {
+			if len(arr.Items) == 0 {
+				return NULL
+			}
+
+			return arr.Items[0]
+		})
+	})
+
+	b.Register("last", 1, "last(arr) returns the last item of arr, or null if arr is empty", func(args ...object.Object) object.Object {
+		return withArray("last", args, func(arr *object.Array) object.Object {
+			if len(arr.Items) == 0 {
+				return NULL
+			}
+
+			return arr.Items[len(arr.Items)-1]
+		})
+	})
+
+	b.RegisterRuntime("rest", 1, "rest(arr) returns the items of arr but the first one", func(rt object.Runtime, args ...object.Object) object.Object {
+		return withArray("rest", args, func(arr *object.Array) object.Object {
+			if len(arr.Items) == 0 {
+				return newArray(rt, nil)
+			}
+
+			return newArray(rt, arr.Items[1:])
+		})
+	})
+
+	b.RegisterRuntime("slice", Variadic, "slice(arr, start, end) returns the items of arr from start to end excluded, end defaults to the length of arr", func(rt object.Runtime, args ...object.Object) object.Object {
+		if len(args) != 2 && len(args) != 3 {
+			return newError("invalid arguments count in function call, expected 2 or 3 argumets, got %d ", len(args))
+		}
+
+		return withArray("slice", args, func(arr *object.Array) object.Object {
+			start, ok := args[1].(*object.Integer)
+			if !ok {
+				return argumentError("slice", 2, object.INTEGER_OBJ, args[1])
+			}
+
+			end := int64(len(arr.Items))
+			if len(args) == 3 {
+				i, ok := args[2].(*object.Integer)
+				if !ok {
+					return argumentError("slice", 3, object.INTEGER_OBJ, args[2])
+				}
+
+				end = i.Value
+			}
+
+			if start.Value < 0 || start.Value > end || end > int64(len(arr.Items)) {
+				return newError("slice bounds out of range [%d:%d] with length %d", start.Value, end, len(arr.Items))
+			}
+
+			return newArray(rt, arr.Items[start.Value:end])
+		})
+	})
+
+	b.RegisterRuntime("concat", Variadic, "concat(arrays...) returns the items of all the arrays in a single one", func(rt object.Runtime, args ...object.Object) object.Object {
+		length := 0
+
+		for i, arg := range args {
+			arr, ok := arg.(*object.Array)
+			if !ok {
+				return argumentError("concat", i+1, object.ARRAY_OBJ, arg)
+			}
+
+			if length += len(arr.Items); length > maxArrayLength {
+				return newError("array too long in `concat`")
+			}
+		}
+
+		if err := allocateArray(rt, length); err != nil {
+			return err
+		}
+
+		items := make([]object.Object, 0, length)
+		for _, arg := range args {
+			items = append(items, arg.(*object.Array).Items...)
+		}
+
+		return &object.Array{Items: items}
+	})
+
+	b.RegisterRuntime("map", 2, "map(arr, fn) returns the results of fn called with each item of arr", func(rt object.Runtime, args ...object.Object) object.Object {
+		return withArray("map", args, func(arr *object.Array) object.Object {
+			if err := allocateArray(rt, len(arr.Items)); err != nil {
+				return err
+			}
+
+			items := make([]object.Object, len(arr.Items))
+
+			for i, item := range arr.Items {
+				items[i] = rt.Call(args[1], item)
+				if isError(items[i]) {
+					return items[i]
+				}
+			}
+
+			return &object.Array{Items: items}
+		})
+	})
+
+	b.RegisterRuntime("filter", 2, "filter(arr, fn) returns the items of arr for which fn returns true", func(rt object.Runtime, args ...object.Object) object.Object {
+		return withArray("filter", args, func(arr *object.Array) object.Object {
+			items := []object.Object{}
+
+			for _, item := range arr.Items {
+				ok, err := predicate("filter", rt, args[1], item)
+				if err != nil {
+					return err
+				}
+
+				if ok {
+					items = append(items, item)
+				}
+			}
+
+			// the items kept are at most the items of arr, they are accounted for once they are known
+			return newArray(rt, items)
+		})
+	})
+
+	b.RegisterRuntime("reduce", 3, "reduce(arr, fn, initial) calls fn with the accumulated value and each item of arr, starting from initial, it returns the last result", func(rt object.Runtime, args ...object.Object) object.Object {
+		return withArray("reduce", args, func(arr *object.Array) object.Object {
+			acc := args[2]
+
+			for _, item := range arr.Items {
+				acc = rt.Call(args[1], acc, item)
+				if isError(acc) {
+					return acc
+				}
+			}
+
+			return acc
+		})
+	})
+
+	b.RegisterRuntime("find", 2, "find(arr, fn) returns the first item of arr for which fn returns true, or null", func(rt object.Runtime, args ...object.Object) object.Object {
+		return withArray("find", args, func(arr *object.Array) object.Object {
+			for _, item := range arr.Items {
+				ok, err := predicate("find", rt, args[1], item)
+				if err != nil {
+					return err
+				}
+
+				if ok {
+					return item
+				}
+			}
+
+			return NULL
+		})
+	})
+
+	b.RegisterRuntime("any", 2, "any(arr, fn) reports whether fn returns true for an item of arr", func(rt object.Runtime, args ...object.Object) object.Object {
+		return withArray("any", args, func(arr *object.Array) object.Object {
+			for _, item := range arr.Items {
+				ok, err := predicate("any", rt, args[1], item)
+				if err != nil {
+					return err
+				}
+
+				if ok {
+					return TRUE
+				}
+			}
+
+			return FALSE
+		})
+	})
+
+	b.RegisterRuntime("all", 2, "all(arr, fn) reports whether fn returns true for all the items of arr", func(rt object.Runtime, args ...object.Object) object.Object {
+		return withArray("all", args, func(arr *object.Array) object.Object {
+			for _, item := range arr.Items {
+				ok, err := predicate("all", rt, args[1], item)
+				if err != nil {
+					return err
+				}
+
+				if !ok {
+					return FALSE
+				}
+			}
+
+			return TRUE
+		})
+	})
+
+	b.RegisterRuntime("sort", Variadic, "sort(arr, less) returns the items of arr sorted by the function less(a, b) reporting whether a goes before b, they are sorted with < if less is omitted", func(rt object.Runtime, args ...object.Object) object.Object {
+		return sortItems("sort", rt, args, func(arr *object.Array, items []object.Object) object.Object {
+			return &object.Array{Items: items}
+		})
+	})
+
+	b.RegisterRuntime("sort_mut", Variadic, "sort_mut(arr, less) sorts the items of arr like sort, it returns arr", func(rt object.Runtime, args ...object.Object) object.Object {
+		return sortItems("sort_mut", rt, args, func(arr *object.Array, items []object.Object) object.Object {
+			arr.Items = items
+			return arr
+		})
+	})
+
+	// reverse is registered with the string builtins, it reverses the arrays as well
+	b.Register("reverse_mut", 1, "reverse_mut(arr) reverses the order of the items of arr, it returns arr", func(args ...object.Object) object.Object {
+		return withArray("reverse_mut", args, func(arr *object.Array) object.Object {
+			reverseItems(arr.Items)
+			return arr
+		})
+	})
+
+	b.RegisterRuntime("zip", 2, "zip(a, b) returns the pairs of the items of a and b at the same index, it stops at the end of the shortest one", func(rt object.Runtime, args ...object.Object) object.Object {
+		a, ok := args[0].(*object.Array)
+		if !ok {
+			return argumentError("zip", 1, object.ARRAY_OBJ, args[0])
+		}
+
+		b, ok := args[1].(*object.Array)
+		if !ok {
+			return argumentError("zip", 2, object.ARRAY_OBJ, args[1])
+		}
+
+		n := min(len(a.Items), len(b.Items))
+		if err := allocatePairs(rt, n); err != nil {
+			return err
+		}
+
+		items := make([]object.Object, n)
+		for i := range items {
+			items[i] = &object.Array{Items: []object.Object{a.Items[i], b.Items[i]}}
+		}
+
+		return &object.Array{Items: items}
+	})
+
+	b.RegisterRuntime("flatten", 1, "flatten(arr) replaces the arrays within arr by their items, the arrays within them are kept", func(rt object.Runtime, args ...object.Object) object.Object {
+		return withArray("flatten", args, func(arr *object.Array) object.Object {
+			length := 0
+
+			for _, item := range arr.Items {
+				if inner, ok := item.(*object.Array); ok {
+					length += len(inner.Items)
+				} else {
+					length++
+				}
+
+				if length > maxArrayLength {
+					return newError("array too long in `flatten`")
+				}
+			}
+
+			if err := allocateArray(rt, length); err != nil {
+				return err
+			}
+
+			items := make([]object.Object, 0, length)
+			for _, item := range arr.Items {
+				if inner, ok := item.(*object.Array); ok {
+					items = append(items, inner.Items...)
+				} else {
+					items = append(items, item)
+				}
+			}
+
+			return &object.Array{Items: items}
+		})
+	})
+
+	b.RegisterRuntime("unique", 1, "unique(arr) returns the items of arr without their duplicates, in the order they first appear", func(rt object.Runtime, args ...object.Object) object.Object {
+		return withArray("unique", args, func(arr *object.Array) object.Object {
+			items := []object.Object{}
+			seen := make(map[object.HashKey]bool, len(arr.Items))
+
+			for _, item := range arr.Items {
+				key, err := hashKey(item)
+				if err != nil {
+					return err
+				}
+
+				if !seen[key] {
+					seen[key] = true
+					items = append(items, item)
+				}
+			}
+
+			return newArray(rt, items)
+		})
+	})
+
+	b.RegisterRuntime("range", Variadic, "range(start, end, step) returns the integers from start to end excluded, going by step. start defaults to 0 and step to 1", func(rt object.Runtime, args ...object.Object) object.Object {
+		if len(args) < 1 || len(args) > 3 {
+			return newError("invalid arguments count in function call, expected 1 to 3 argumets, got %d ", len(args))
+		}
+
+		bounds := []int64{0, 0, 1}
+		for i, arg := range args {
+			n, ok := arg.(*object.Integer)
+			if !ok {
+				return argumentError("range", i+1, object.INTEGER_OBJ, arg)
+			}
+
+			bounds[i] = n.Value
+		}
+
+		if len(args) == 1 {
+			bounds[0], bounds[1] = 0, bounds[0]
+		}
+
+		start, end, step := bounds[0], bounds[1], bounds[2]
+		if step == 0 {
+			return newError("zero step in `range`")
+		}
+
+		// the differences are computed unsigned, they don't fit in an int64 for the widest ranges
+		var n uint64
+		if step > 0 && start < end {
+			n = (uint64(end)-uint64(start)-1)/uint64(step) + 1
+		} else if step < 0 && start > end {
+			n = (uint64(start)-uint64(end)-1)/uint64(-step) + 1
+		}
+
+		if n > maxArrayLength {
+			return newError("array too long in `range`")
+		}
+
+		if err := allocateArray(rt, int(n)); err != nil {
+			return err
+		}
+
+		items := make([]object.Object, n)
+		for i := range items {
+			items[i] = &object.Integer{Value: start + int64(i)*step}
+		}
+
+		return &object.Array{Items: items}
+	})
+
+	b.RegisterRuntime("keys", 1, "keys(hash) returns the keys of hash", func(rt object.Runtime, args ...object.Object) object.Object {
+		return withHash("keys", args, func(hash *object.Hash) object.Object {
+			if err := allocateArray(rt, len(hash.Items)); err != nil {
+				return err
+			}
+
+			pairs := hash.Pairs()
+
+			items := make([]object.Object, len(pairs))
+			for i, pair := range pairs {
+				items[i] = pair.Key
+			}
+
+			return &object.Array{Items: items}
+		})
+	})
+
+	b.RegisterRuntime("values", 1, "values(hash) returns the values of hash, in the order of their keys", func(rt object.Runtime, args ...object.Object) object.Object {
+		return withHash("values", args, func(hash *object.Hash) object.Object {
+			if err := allocateArray(rt, len(hash.Items)); err != nil {
+				return err
+			}
+
+			pairs := hash.Pairs()
+
+			items := make([]object.Object, len(pairs))
+			for i, pair := range pairs {
+				items[i] = pair.Value
+			}
+
+			return &object.Array{Items: items}
+		})
+	})
+
+	b.RegisterRuntime("entries", 1, "entries(hash) returns the [key, value] pairs of hash", func(rt object.Runtime, args ...object.Object) object.Object {
+		return withHash("entries", args, func(hash *object.Hash) object.Object {
+			if err := allocatePairs(rt, len(hash.Items)); err != nil {
+				return err
+			}
+
+			pairs := hash.Pairs()
+
+			items := make([]object.Object, len(pairs))
+			for i, pair := range pairs {
+				items[i] = &object.Array{Items: []object.Object{pair.Key, pair.Value}}
+			}
+
+			return &object.Array{Items: items}
+		})
+	})
+
+	b.Register("has", 2, "has(hash, key) reports whether hash has the key", func(args ...object.Object) object.Object {
+		return withHash("has", args, func(hash *object.Hash) object.Object {
+			key, err := hashKey(args[1])
+			if err != nil {
+				return err
+			}
+
+			_, ok := hash.Items[key]
+			return nativeBooleanObject(ok)
+		})
+	})
+
+	b.RegisterRuntime("delete", 2, "delete(hash, key) returns a copy of hash without the key", func(rt object.Runtime, args ...object.Object) object.Object {
+		return withHash("delete", args, func(hash *object.Hash) object.Object {
+			key, err := hashKey(args[1])
+			if err != nil {
+				return err
+			}
+
+			if err := allocateHash(rt, len(hash.Items)); err != nil {
+				return err
+			}
+
+			copied := copyHash(hash)
+			delete(copied.Items, key)
+
+			return copied
+		})
+	})
+
+	b.Register("delete_mut", 2, "delete_mut(hash, key) removes the key from hash, it returns hash", func(args ...object.Object) object.Object {
+		return withHash("delete_mut", args, func(hash *object.Hash) object.Object {
+			key, err := hashKey(args[1])
+			if err != nil {
+				return err
+			}
+
+			delete(hash.Items, key)
+			return hash
+		})
+	})
+
+	b.RegisterRuntime("merge", 2, "merge(a, b) returns a hash with the pairs of a and b, the values of b win for the keys in both", func(rt object.Runtime, args ...object.Object) object.Object {
+		return withHashes("merge", args, func(a, b *object.Hash) object.Object {
+			// the pairs of b replacing pairs of a are accounted for twice, it doesn't matter much
+			if err := allocateHash(rt, len(a.Items)+len(b.Items)); err != nil {
+				return err
+			}
+
+			merged := copyHash(a)
+			for key, pair := range b.Items {
+				merged.Items[key] = pair
+			}
+
+			return merged
+		})
+	})
+
+	b.RegisterRuntime("merge_mut", 2, "merge_mut(a, b) adds the pairs of b to a, it returns a", func(rt object.Runtime, args ...object.Object) object.Object {
+		return withHashes("merge_mut", args, func(a, b *object.Hash) object.Object {
+			added := 0
+			for key := range b.Items {
+				if _, ok := a.Items[key]; !ok {
+					added++
+				}
+			}
+
+			if err := rt.Allocate(added, pairSize*int64(added)); err != nil {
+				return err
+			}
+
+			for key, pair := range b.Items {
+				a.Items[key] = pair
+			}
+
+			return a
+		})
+	})
+}
+
+// withArray calls fn with the first argument, which must be an array
+func withArray(name string, args []object.Object, fn func(arr *object.Array) object.Object) object.Object {
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return argumentError(name, 1, object.ARRAY_OBJ, args[0])
+	}
+
+	return fn(arr)
+}
+
+// withHash calls fn with the first argument, which must be a hash
+func withHash(name string, args []object.Object, fn func(hash *object.Hash) object.Object) object.Object {
+	hash, ok := args[0].(*object.Hash)
+	if !ok {
+		return argumentError(name, 1, object.HASH_OBJ, args[0])
+	}
+
+	return fn(hash)
+}
+
+func withHashes(name string, args []object.Object, fn func(a, b *object.Hash) object.Object) object.Object {
+	return withHash(name, args, func(a *object.Hash) object.Object {
+		b, ok := args[1].(*object.Hash)
+		if !ok {
+			return argumentError(name, 2, object.HASH_OBJ, args[1])
+		}
+
+		return fn(a, b)
+	})
+}
+
+// predicate calls the function given to the builtin with the item, the function must return a boolean
+func predicate(name string, rt object.Runtime, fn object.Object, item object.Object) (bool, object.Object) {
+	result := rt.Call(fn, item)
+	if isError(result) {
+		return false, result
+	}
+
+	b, ok := result.(*object.Boolean)
+	if !ok {
+		return false, newError("the function given to `%s` must return BOOLEAN, got %s", name, result.Type())
+	}
+
+	return b.Value, nil
+}
+
+// sortItems sorts a copy of the items of the array given to the builtin, the sort is stable.
+// The first error of the comparisons stops the sort and is returned.
+func sortItems(name string, rt object.Runtime, args []object.Object, done func(arr *object.Array, items []object.Object) object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("invalid arguments count in function call, expected 1 or 2 argumets, got %d ", len(args))
+	}
+
+	return withArray(name, args, func(arr *object.Array) object.Object {
+		less := func(a, b object.Object) object.Object {
+			return evalInfixExpression("<", a, b)
+		}
+
+		if len(args) == 2 {
+			less = func(a, b object.Object) object.Object {
+				return rt.Call(args[1], a, b)
+			}
+		}
+
+		if err := allocateArray(rt, len(arr.Items)); err != nil {
+			return err
+		}
+
+		items := copyItems(arr.Items)
+
+		var err object.Object
+		sort.SliceStable(items, func(i, j int) bool {
+			if err != nil {
+				return false
+			}
+
+			result := less(items[i], items[j])
+			if isError(result) {
+				err = result
+				return false
+			}
+
+			b, ok := result.(*object.Boolean)
+			if !ok {
+				err = newError("the function given to `%s` must return BOOLEAN, got %s", name, result.Type())
+				return false
+			}
+
+			return b.Value
+		})
+
+		if err != nil {
+			return err
+		}
+
+		return done(arr, items)
+	})
+}
+
+// allocateArray accounts for an array of n items the builtin is about to create, the items already exist
+func allocateArray(rt object.Runtime, n int) *object.Error {
+	return rt.Allocate(n, arraySize+itemSize*int64(n))
+}
+
+// allocatePairs accounts for an array of n arrays of two items, like the array returned by zip
+func allocatePairs(rt object.Runtime, n int) *object.Error {
+	return rt.Allocate(4*n, arraySize+(itemSize+arraySize+2*itemSize)*int64(n))
+}
+
+// allocateHash accounts for a hash of n pairs the builtin is about to create
+func allocateHash(rt object.Runtime, n int) *object.Error {
+	return rt.Allocate(n, hashSize+pairSize*int64(n))
+}
+
+// newArray returns an array with a copy of the items, once it is accounted for
+func newArray(rt object.Runtime, items []object.Object) object.Object {
+	if err := allocateArray(rt, len(items)); err != nil {
+		return err
+	}
+
+	return &object.Array{Items: copyItems(items)}
+}
+
+func hashKey(key object.Object) (object.HashKey, *object.Error) {
+	hashable, ok := key.(object.Hashable)
+	if !ok {
+		return object.HashKey{}, newError("unusable as hash key: %s", key.Type())
+	}
+
+	return hashable.HashKey(), nil
+}
+
+func copyItems(items []object.Object) []object.Object {
+	copied := make([]object.Object, len(items), len(items)+1)
+	copy(copied, items)
+
+	return copied
+}
+
+func copyHash(hash *object.Hash) *object.Hash {
+	copied := &object.Hash{Items: make(map[object.HashKey]object.HashPair, len(hash.Items))}
+	for key, pair := range hash.Items {
+		copied.Items[key] = pair
+	}
+
+	return copied
+}
+
+func reverseItems(items []object.Object) {
+	for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+		items[i], items[j] = items[j], items[i]
+	}
+}
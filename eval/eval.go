@@ -2,9 +2,14 @@ package eval
 
 import (
 	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
 
 	"github.com/yassinebenaid/nishimia/ast"
 	"github.com/yassinebenaid/nishimia/object"
+	"github.com/yassinebenaid/nishimia/token"
 )
 
 var (
@@ -13,43 +18,96 @@ var (
 	FALSE = &object.Boolean{Value: false}
 )
 
-func Eval(node ast.Node, env *object.Environment) object.Object {
+func (s *state) eval(node ast.Node, env *object.Environment) object.Object {
+	var result object.Object
+
+	if err := s.consume(s.costs.Node); err != nil {
+		result = err
+	} else {
+		result = s.evalNode(node, env)
+	}
+
+	// errors are located at the innermost node that produced them,
+	// the outer nodes just pass them through.
+	if err, ok := result.(*object.Error); ok && !err.Pos.IsValid() {
+		err.Pos = node.Pos()
+		err.End = node.End()
+	}
+
+	return result
+}
+
+func (s *state) evalNode(node ast.Node, env *object.Environment) object.Object {
 	switch v := node.(type) {
 	case *ast.Program:
-		return evalProgram(v.Statements, env)
+		return s.evalProgram(v.Statements, env)
 	case *ast.ExpressionStatement:
-		return Eval(v.Expression, env)
+		return s.eval(v.Expression, env)
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: v.Value}
+	case *ast.BigIntegerLiteral:
+		return &object.BigInt{Value: v.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: v.Value}
 	case *ast.StringLiteral:
-		return &object.String{Value: v.Value}
+		return s.measure(&object.String{Value: v.Value})
 	case *ast.BooleanLiteral:
 		return nativeBooleanObject(v.Value)
 	case *ast.IfElseExpression:
-		return evalConditionalExpression(v, env)
+		return s.evalConditionalExpression(v, env)
+	case *ast.MatchExpression:
+		return s.evalMatchExpression(v, env)
+	case *ast.WhileStatement:
+		return s.evalWhileStatement(v, env)
+	case *ast.ForStatement:
+		return s.evalForStatement(v, env)
+	case *ast.ForInStatement:
+		return s.evalForInStatement(v, env)
+	case *ast.BranchStatement:
+		var label string
+		if v.Label != nil {
+			label = v.Label.Value
+		}
+
+		if v.Token.Type == token.BREAK {
+			return &object.Break{Label: label}
+		}
+
+		return &object.Continue{Label: label}
 	case *ast.VarStatement:
-		return evalVariableInitializationExpression(v, env)
+		return s.evalVariableInitializationExpression(v, env)
+	case *ast.AssignExpression:
+		return s.evalAssignExpression(v, env)
 	case *ast.BlockStatement:
-		return evalBlockStatements(v, env)
+		return s.evalBlockStatements(v, env)
 	case *ast.FunctionLiteral:
+		if err := s.allocate(0, functionSize); err != nil {
+			return err
+		}
+
 		return &object.Function{Params: v.Params, Body: v.Body, Env: env}
 	case *ast.CallExpression:
-		return evalCallExpression(v, env)
+		return s.evalCallExpression(v, env)
 	case *ast.ArrayLiteral:
-		arr := &object.Array{Items: make([]object.Object, 0, len(v.Items))}
+		items := s.evalExpressions(v.Items, env)
+		if len(items) == 1 && isAbrupt(items[0]) {
+			return items[0]
+		}
 
-		for _, i := range v.Items {
-			arr.Items = append(arr.Items, Eval(i, env))
+		if err := s.allocate(len(items), arraySize+itemSize*int64(len(items))); err != nil {
+			return err
 		}
 
-		return arr
+		return &object.Array{Items: items}
 	case *ast.HashLiteral:
-		return evalHash(v, env)
+		return s.evalHash(v, env)
 	case *ast.IndexExpression:
-		return evalIndexExression(v, env)
+		return s.evalIndexExression(v, env)
+	case *ast.MemberExpression:
+		return s.evalMemberExpression(v, env)
 	case *ast.PrefixExpression:
-		val := Eval(v.Right, env)
-		if isError(val) {
+		val := s.eval(v.Right, env)
+		if isAbrupt(val) {
 			return val
 		}
 
@@ -59,28 +117,37 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return val
 		}
 
-		if val, ok := builtins[v.Value]; ok {
+		if val, ok := s.options.builtins().Lookup(v.Value); ok {
 			return val
 		}
 
-		return newError("undefined identifier : %s", v.Value)
+		return UndefinedIdentifierError(v.Value)
 
 	case *ast.InfixExpression:
-		l := Eval(v.Left, env)
-		if isError(l) {
+		if v.Operator == "&&" || v.Operator == "||" {
+			return s.evalLogicalExpression(v, env)
+		}
+
+		l := s.eval(v.Left, env)
+		if isAbrupt(l) {
 			return l
 		}
 
-		r := Eval(v.Right, env)
-		if isError(r) {
+		r := s.eval(v.Right, env)
+		if isAbrupt(r) {
 			return r
 		}
 
-		return evalInfixExpression(v.Operator, l, r)
+		return s.infix(v.Operator, l, r)
 
 	case *ast.ReturnStatement:
-		val := Eval(v.Return, env)
-		if isError(val) {
+		// the calls returned by functions are tail calls
+		if call, ok := v.Return.(*ast.CallExpression); ok && len(s.calls) > 0 {
+			return s.evalTailCall(call, env)
+		}
+
+		val := s.eval(v.Return, env)
+		if isAbrupt(val) {
 			return val
 		}
 
@@ -90,11 +157,11 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	return newError("unknown expression : %s", node.String())
 }
 
-func evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
+func (s *state) evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, stmt := range stmts {
-		result = Eval(stmt, env)
+		result = s.eval(stmt, env)
 
 		switch result := result.(type) {
 		case *object.ReturnValue:
@@ -107,22 +174,57 @@ func evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
 	return result
 }
 
-func evalBlockStatements(block *ast.BlockStatement, env *object.Environment) object.Object {
-	var result object.Object
+func (s *state) evalBlockStatements(block *ast.BlockStatement, env *object.Environment) object.Object {
+	var result object.Object = NULL
 
 	for _, stmt := range block.Statements {
-		result = Eval(stmt, env)
+		result = s.eval(stmt, env)
 
-		if result != nil {
-			if result.Type() == object.RETURN_VALUE_OBJ || result.Type() == object.ERROR_OBJ {
-				return result
-			}
+		if isAbrupt(result) {
+			return result
 		}
 	}
 
 	return result
 }
 
+// evalLogicalExpression evaluates && and || , the right operand is only evaluated
+// when the left one doesn't determine the result.
+func (s *state) evalLogicalExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
+	left := s.eval(node.Left, env)
+	if isAbrupt(left) {
+		return left
+	}
+
+	if node.Operator == "||" && s.options.TruthyOr {
+		if isTruthy(left) {
+			return left
+		}
+
+		return s.eval(node.Right, env)
+	}
+
+	// the type of the left operand is checked first, the right one must not run when the expression can't succeed
+	if left.Type() != object.BOOLEAN_OBJ {
+		return LogicalOperandError(node.Operator, left)
+	}
+
+	if left == FALSE && node.Operator == "&&" {
+		return FALSE
+	}
+
+	if left == TRUE && node.Operator == "||" {
+		return TRUE
+	}
+
+	right := s.eval(node.Right, env)
+	if isAbrupt(right) {
+		return right
+	}
+
+	return s.infix(node.Operator, left, right)
+}
+
 func evalPrefixExpression(operator string, right object.Object) object.Object {
 	switch operator {
 	case "!":
@@ -146,6 +248,16 @@ func evalInfixExpression(operator string, left object.Object, right object.Objec
 		return evalIntegerInfixExpression(operator, left, right)
 	}
 
+	// an integer that doesn't fit in 64 bits turns the whole operation into a big integer one
+	if isInteger(left) && isInteger(right) {
+		return evalBigIntInfixExpression(operator, toBigInt(left), toBigInt(right))
+	}
+
+	// an integer mixed with a float is promoted to float
+	if isNumeric(left) && isNumeric(right) {
+		return evalFloatInfixExpression(operator, toFloat(left), toFloat(right))
+	}
+
 	if left.Type() == object.BOOLEAN_OBJ && right.Type() == object.BOOLEAN_OBJ {
 		return evalBooleanInfixExpression(operator, left, right)
 	}
@@ -183,6 +295,18 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
 
+	if f, ok := right.(*object.Float); ok {
+		return &object.Float{Value: -f.Value}
+	}
+
+	if b, ok := right.(*object.BigInt); ok {
+		return bigIntObject(new(big.Int).Neg(b.Value))
+	}
+
+	if i, ok := right.(*object.Integer); ok && i.Value == math.MinInt64 {
+		return bigIntObject(new(big.Int).Neg(big.NewInt(i.Value)))
+	}
+
 	if right.Type() != object.INTEGER_OBJ {
 		return newError(
 			"invalid operation: -%s (operator \"-\" not defined on %s)",
@@ -198,6 +322,10 @@ func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
 
 func evalPlusPrefixOperatorExpression(right object.Object) object.Object {
 
+	if right.Type() == object.FLOAT_OBJ || right.Type() == object.BIGINT_OBJ {
+		return right
+	}
+
 	if right.Type() != object.INTEGER_OBJ {
 		return newError(
 			"invalid operation: +%s (operator \"+\" not defined on %s)",
@@ -217,13 +345,41 @@ func evalIntegerInfixExpression(operator string, left object.Object, right objec
 
 	switch operator {
 	case "+":
-		return &object.Integer{Value: leftValue + rightValue}
+		if sum := leftValue + rightValue; (sum > leftValue) == (rightValue > 0) {
+			return &object.Integer{Value: sum}
+		}
 	case "-":
-		return &object.Integer{Value: leftValue - rightValue}
+		if diff := leftValue - rightValue; (diff < leftValue) == (rightValue > 0) {
+			return &object.Integer{Value: diff}
+		}
 	case "*":
-		return &object.Integer{Value: leftValue * rightValue}
+		product := leftValue * rightValue
+
+		if leftValue == 0 || rightValue == 0 {
+			return &object.Integer{Value: 0}
+		}
+
+		if product/rightValue == leftValue && !(leftValue == -1 && rightValue == math.MinInt64) && !(rightValue == -1 && leftValue == math.MinInt64) {
+			return &object.Integer{Value: product}
+		}
 	case "/":
-		return &object.Integer{Value: leftValue / rightValue}
+		if rightValue == 0 {
+			return newError("invalid operation: %d / 0 (division by zero)", leftValue)
+		}
+
+		if leftValue != math.MinInt64 || rightValue != -1 {
+			return &object.Integer{Value: leftValue / rightValue}
+		}
+	case "%":
+		if rightValue == 0 {
+			return newError("invalid operation: %d %% 0 (division by zero)", leftValue)
+		}
+
+		if rightValue == -1 {
+			return &object.Integer{Value: 0}
+		}
+
+		return &object.Integer{Value: leftValue % rightValue}
 	case "<":
 		return nativeBooleanObject(leftValue < rightValue)
 	case ">":
@@ -252,6 +408,109 @@ func evalIntegerInfixExpression(operator string, left object.Object, right objec
 			rightValue,
 		)
 	}
+
+	// the arithmetic operation overflowed, it is done again with big integers
+	return evalBigIntInfixExpression(operator, big.NewInt(leftValue), big.NewInt(rightValue))
+}
+
+func evalBigIntInfixExpression(operator string, leftValue *big.Int, rightValue *big.Int) object.Object {
+	switch operator {
+	case "+":
+		return bigIntObject(new(big.Int).Add(leftValue, rightValue))
+	case "-":
+		return bigIntObject(new(big.Int).Sub(leftValue, rightValue))
+	case "*":
+		return bigIntObject(new(big.Int).Mul(leftValue, rightValue))
+	case "/":
+		if rightValue.Sign() == 0 {
+			return newError("invalid operation: %s / 0 (division by zero)", leftValue)
+		}
+
+		return bigIntObject(new(big.Int).Quo(leftValue, rightValue))
+	case "%":
+		if rightValue.Sign() == 0 {
+			return newError("invalid operation: %s %% 0 (division by zero)", leftValue)
+		}
+
+		return bigIntObject(new(big.Int).Rem(leftValue, rightValue))
+	case "<":
+		return nativeBooleanObject(leftValue.Cmp(rightValue) < 0)
+	case ">":
+		return nativeBooleanObject(leftValue.Cmp(rightValue) > 0)
+	case ">=":
+		return nativeBooleanObject(leftValue.Cmp(rightValue) >= 0)
+	case "<=":
+		return nativeBooleanObject(leftValue.Cmp(rightValue) <= 0)
+	case "!=":
+		return nativeBooleanObject(leftValue.Cmp(rightValue) != 0)
+	case "==":
+		return nativeBooleanObject(leftValue.Cmp(rightValue) == 0)
+	case "&&", "||":
+		return newError(
+			"invalid operation: %s %s %s , operator %s can only be used with BOOLEAN. got INTEGER",
+			leftValue,
+			operator,
+			rightValue,
+			operator,
+		)
+	default:
+		return newError(
+			"invalid operation: %s %s %s",
+			leftValue,
+			operator,
+			rightValue,
+		)
+	}
+}
+
+func evalFloatInfixExpression(operator string, leftValue float64, rightValue float64) object.Object {
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftValue + rightValue}
+	case "-":
+		return &object.Float{Value: leftValue - rightValue}
+	case "*":
+		return &object.Float{Value: leftValue * rightValue}
+	case "/":
+		if rightValue == 0 {
+			return newError("invalid operation: %s / 0.0 (division by zero)", (&object.Float{Value: leftValue}).Inspect())
+		}
+
+		return &object.Float{Value: leftValue / rightValue}
+	case "%":
+		if rightValue == 0 {
+			return newError("invalid operation: %s %% 0.0 (division by zero)", (&object.Float{Value: leftValue}).Inspect())
+		}
+
+		return &object.Float{Value: math.Mod(leftValue, rightValue)}
+	case "<":
+		return nativeBooleanObject(leftValue < rightValue)
+	case ">":
+		return nativeBooleanObject(leftValue > rightValue)
+	case ">=":
+		return nativeBooleanObject(leftValue >= rightValue)
+	case "<=":
+		return nativeBooleanObject(leftValue <= rightValue)
+	case "!=":
+		return nativeBooleanObject(leftValue != rightValue)
+	case "==":
+		return nativeBooleanObject(leftValue == rightValue)
+	case "&&", "||":
+		return newError(
+			"invalid operation: %s %s %s , operator %s can only be used with BOOLEAN. got FLOAT",
+			(&object.Float{Value: leftValue}).Inspect(),
+			operator,
+			(&object.Float{Value: rightValue}).Inspect(),
+			operator,
+		)
+	default:
+		return newError(
+			"invalid operation: %s %s %s",
+			(&object.Float{Value: leftValue}).Inspect(),
+			operator,
+			(&object.Float{Value: rightValue}).Inspect(),
+		)
+	}
 }
 
 func evalBooleanInfixExpression(operator string, left object.Object, right object.Object) object.Object {
@@ -281,8 +540,21 @@ func evalStringInfixExpression(operator string, left object.Object, right object
 	leftValue := left.(*object.String).Value
 	rightValue := right.(*object.String).Value
 
-	if operator == "+" {
+	switch operator {
+	case "+":
 		return &object.String{Value: leftValue + rightValue}
+	case "==":
+		return nativeBooleanObject(leftValue == rightValue)
+	case "!=":
+		return nativeBooleanObject(leftValue != rightValue)
+	case "<":
+		return nativeBooleanObject(leftValue < rightValue)
+	case ">":
+		return nativeBooleanObject(leftValue > rightValue)
+	case "<=":
+		return nativeBooleanObject(leftValue <= rightValue)
+	case ">=":
+		return nativeBooleanObject(leftValue >= rightValue)
 	}
 
 	return newError(
@@ -294,215 +566,1008 @@ func evalStringInfixExpression(operator string, left object.Object, right object
 
 }
 
-func evalConditionalExpression(node *ast.IfElseExpression, env *object.Environment) object.Object {
-	cond := Eval(node.Condition, env)
-	if isError(cond) {
+func (s *state) evalConditionalExpression(node *ast.IfElseExpression, env *object.Environment) object.Object {
+	cond := s.eval(node.Condition, env)
+	if isAbrupt(cond) {
 		return cond
 	}
 
 	if cond.Type() != object.BOOLEAN_OBJ {
-		return newError("non-boolean value in if-statement , ( got=%s, want=BOOLEAN )", cond.Type())
+		return ConditionError(IfCondition, cond)
 	}
 
 	if cond.Inspect() == "true" {
-		return Eval(node.Consequence, env)
+		return s.eval(node.Consequence, env)
 	}
 
 	if node.Alternative != nil {
-		return Eval(node.Alternative, env)
+		return s.eval(node.Alternative, env)
 	}
 
 	return NULL
 }
 
-func evalVariableInitializationExpression(node *ast.VarStatement, env *object.Environment) object.Object {
-	val := Eval(node.Value, env)
-	if isError(val) {
-		return val
+// evalMatchExpression evaluates the body of the first arm whose pattern matches the subject and whose guard holds,
+// the default arm is evaluated when no other arm matches wherever it appears.
+func (s *state) evalMatchExpression(node *ast.MatchExpression, env *object.Environment) object.Object {
+	subject := s.eval(node.Subject, env)
+	if isAbrupt(subject) {
+		return subject
 	}
 
-	if env.Has(node.Name.Value) {
-		return newError("variable %s already defined", node.Name.Value)
+	var fallback *ast.MatchArm
+
+	for _, arm := range node.Arms {
+		if arm.Pattern == nil {
+			fallback = arm
+			continue
+		}
+
+		if result, selected := s.evalMatchArm(arm, subject, env); selected {
+			return result
+		}
 	}
 
-	env.Set(node.Name.Value, val)
+	if fallback != nil {
+		fallbackEnv, err := s.enclose(env, 0)
+		if err != nil {
+			return err
+		}
 
-	return NULL
-}
+		result := s.eval(fallback.Body, fallbackEnv)
+		s.leave(fallbackEnv)
 
-func evalCallExpression(node *ast.CallExpression, env *object.Environment) object.Object {
-	function := Eval(node.Function, env)
-	if isError(function) {
-		return function
+		return result
 	}
 
-	args := evalExpressions(node.Arguments, env)
-	if len(args) == 1 && isError(args[0]) {
-		return args[0]
-	}
+	return NoMatchError(subject)
+}
 
-	if fn, ok := function.(*object.Builtin); ok {
-		return fn.Fn(args...)
+// evalMatchArm evaluates the body of the arm if its pattern matches the subject and its guard holds, selected
+// reports whether it did or whether the evaluation stopped in the arm, with an error or a break in the guard.
+// The scope of the arm is released whatever happens, the arm can be evaluated at every iteration of a loop.
+func (s *state) evalMatchArm(arm *ast.MatchArm, subject object.Object, env *object.Environment) (result object.Object, selected bool) {
+	armEnv, err := s.enclose(env, 0)
+	if err != nil {
+		return err, true
 	}
 
-	fn, ok := function.(*object.Function)
-	if !ok {
-		return newError(
-			"invalid identifier in function call : %s is not a valid identifier or function literal",
-			function.Inspect(),
-		)
+	defer s.leave(armEnv)
+
+	matched, err := s.matchPattern(arm.Pattern, subject, armEnv)
+	if err != nil {
+		return err, true
 	}
 
-	if len(fn.Params) != len(node.Arguments) {
-		return newError("invalid arguments count in function call, expected %d argumets, got %d ",
-			len(fn.Params),
-			len(node.Arguments),
-		)
+	// the variables of the pattern are bound even if it doesn't match, they are released with the scope
+	if err := s.reserve(variableSize * int64(len(armEnv.Store))); err != nil {
+		return err, true
 	}
 
-	newEnv := object.NewEnclosedEnvironment(fn.Env)
-	for k, v := range fn.Params {
-		newEnv.Set(v.Value, args[k])
+	if !matched {
+		return nil, false
 	}
 
-	result := Eval(fn.Body, newEnv)
+	if arm.Guard != nil {
+		guard := s.eval(arm.Guard, armEnv)
+		if isAbrupt(guard) {
+			return guard, true
+		}
 
-	if result.Type() == object.RETURN_VALUE_OBJ {
-		return result.(*object.ReturnValue).Value
-	}
+		if guard.Type() != object.BOOLEAN_OBJ {
+			return ConditionError(MatchGuard, guard), true
+		}
 
-	if result.Type() == object.ERROR_OBJ {
-		return result
+		if guard == FALSE {
+			return nil, false
+		}
 	}
 
-	return NULL
+	return s.eval(arm.Body, armEnv), true
 }
 
-func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
-	var result []object.Object
+// matchPattern reports whether the value matches the pattern, the identifiers of the pattern
+// are bound in env to the values they matched, except _ that matches without binding.
+// array patterns match arrays of the same length, hash patterns match hashes having at least their keys.
+// The error of the evaluation of a literal of the pattern, like an exhausted budget, is returned.
+func (s *state) matchPattern(pattern ast.Expression, value object.Object, env *object.Environment) (bool, *object.Error) {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		if pattern.Value != "_" {
+			env.Set(pattern.Value, value)
+		}
 
-	for _, exp := range exps {
-		evaluated := Eval(exp, env)
+		return true, nil
+	case *ast.ArrayLiteral:
+		arr, ok := value.(*object.Array)
+		if !ok || len(arr.Items) != len(pattern.Items) {
+			return false, nil
+		}
 
-		if isError(evaluated) {
-			return []object.Object{evaluated}
+		for i, item := range pattern.Items {
+			if matched, err := s.matchPattern(item, arr.Items[i], env); !matched || err != nil {
+				return false, err
+			}
 		}
 
-		result = append(result, evaluated)
-	}
+		return true, nil
+	case *ast.HashLiteral:
+		hash, ok := value.(*object.Hash)
+		if !ok {
+			return false, nil
+		}
 
-	return result
-}
+		for k, v := range pattern.Items {
+			evaluated := s.eval(k, env)
+			if isError(evaluated) {
+				return false, evaluated.(*object.Error)
+			}
 
-func evalHash(hash *ast.HashLiteral, env *object.Environment) object.Object {
-	h := &object.Hash{Items: make(map[object.HashKey]object.HashPair)}
+			key, ok := evaluated.(object.Hashable)
+			if !ok {
+				return false, nil
+			}
 
-	for k, v := range hash.Items {
-		key := Eval(k, env)
-		if isError(key) {
-			return key
-		}
+			pair, ok := hash.Items[key.HashKey()]
+			if !ok {
+				return false, nil
+			}
 
-		val := Eval(v, env)
-		if isError(val) {
-			return val
+			if matched, err := s.matchPattern(v, pair.Value, env); !matched || err != nil {
+				return false, err
+			}
 		}
 
-		var hashKey object.HashKey
-		if b, ok := key.(*object.Boolean); ok {
-			hashKey = b.HashKey()
-		} else if i, ok := key.(*object.Integer); ok {
-			hashKey = i.HashKey()
-		} else if s, ok := key.(*object.String); ok {
-			hashKey = s.HashKey()
-		} else {
-			return newError("cannot use value of type %T as hash key", key)
+		return true, nil
+	default:
+		literal := s.eval(pattern, env)
+		if isError(literal) {
+			return false, literal.(*object.Error)
 		}
 
-		h.Items[hashKey] = object.HashPair{
-			Key:   key,
-			Value: val,
-		}
+		return literalMatches(literal, value), nil
 	}
-
-	return h
 }
 
-func evalIndexExression(arr *ast.IndexExpression, env *object.Environment) object.Object {
-	left := Eval(arr.Left, env)
-	if isError(left) {
-		return left
+// literalMatches reports whether the value equals the literal, numbers of different types are compared by value.
+func literalMatches(literal object.Object, value object.Object) bool {
+	if isNumeric(literal) && isNumeric(value) {
+		return evalInfixExpression("==", literal, value) == TRUE
 	}
 
-	switch v := left.(type) {
-	case *object.Array:
-		return evalArrayIndexExression(v, arr.Index, env)
-	case *object.Hash:
-		return evalHashIndexExression(v, arr.Index, env)
-	default:
-		return newError("failed to read index on type %s", v.Type())
+	switch literal := literal.(type) {
+	case *object.String:
+		str, ok := value.(*object.String)
+		return ok && str.Value == literal.Value
+	case *object.Boolean:
+		return value == literal
 	}
 
+	return false
 }
 
-func evalArrayIndexExression(array *object.Array, i ast.Expression, env *object.Environment) object.Object {
+func (s *state) evalWhileStatement(node *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		if err := s.interrupted(); err != nil {
+			return err
+		}
+
+		cond := s.evalLoopCondition(node.Condition, env)
+		if isAbrupt(cond) {
+			return cond
+		}
+
+		if cond == FALSE {
+			return NULL
+		}
+
+		bodyEnv, err := s.enclose(env, 0)
+		if err != nil {
+			return err
+		}
+
+		result := s.eval(node.Body, bodyEnv)
+		s.leave(bodyEnv)
 
-	ind := Eval(i, env)
-	if isError(ind) {
-		return ind
+		if stop, out := loopControl(node.Label, result); stop {
+			return out
+		}
 	}
+}
 
-	index, ok := ind.(*object.Integer)
-	if !ok {
-		return newError("cannot convert %s of type %s to type %s",
-			ind.Inspect(),
-			ind.Type(),
-			object.INTEGER_OBJ,
-		)
+func (s *state) evalForStatement(node *ast.ForStatement, env *object.Environment) object.Object {
+	// the variables declared by the init statement are only visible to the loop
+	loopEnv, err := s.enclose(env, 0)
+	if err != nil {
+		return err
 	}
+	defer s.leave(loopEnv)
 
-	if index.Value >= int64(len(array.Items)) {
-		return newError("index out of range [%d] with length %d",
-			index.Value,
-			len(array.Items),
-		)
+	if node.Init != nil {
+		if init := s.eval(node.Init, loopEnv); isAbrupt(init) {
+			return init
+		}
 	}
 
-	return array.Items[index.Value]
-}
+	for {
+		if err := s.interrupted(); err != nil {
+			return err
+		}
+
+		if node.Condition != nil {
+			cond := s.evalLoopCondition(node.Condition, loopEnv)
+			if isAbrupt(cond) {
+				return cond
+			}
+
+			if cond == FALSE {
+				return NULL
+			}
+		}
+
+		bodyEnv, err := s.enclose(loopEnv, 0)
+		if err != nil {
+			return err
+		}
+
+		result := s.eval(node.Body, bodyEnv)
+		s.leave(bodyEnv)
 
-func evalHashIndexExression(hash *object.Hash, i ast.Expression, env *object.Environment) object.Object {
+		if stop, out := loopControl(node.Label, result); stop {
+			return out
+		}
 
-	ind := Eval(i, env)
-	if isError(ind) {
-		return ind
+		if node.Post != nil {
+			if post := s.eval(node.Post, loopEnv); isAbrupt(post) {
+				return post
+			}
+		}
 	}
+}
 
-	if ind.Type() != object.STRING_OBJ && ind.Type() != object.BOOLEAN_OBJ && ind.Type() != object.INTEGER_OBJ {
-		return newError("invalid hash key  %s of type %s ",
-			ind.Inspect(),
-			ind.Type(),
-		)
+// evalForInStatement iterates over the items of arrays, the characters of strings and the pairs of hashes,
+// the collection is evaluated once, items added to it while iterating are not visited.
+func (s *state) evalForInStatement(node *ast.ForInStatement, env *object.Environment) object.Object {
+	iterable := s.eval(node.Iterable, env)
+	if isAbrupt(iterable) {
+		return iterable
 	}
 
-	var hashKey object.HashKey
-	if b, ok := ind.(*object.Boolean); ok {
-		hashKey = b.HashKey()
-	} else if i, ok := ind.(*object.Integer); ok {
-		hashKey = i.HashKey()
-	} else if s, ok := ind.(*object.String); ok {
-		hashKey = s.HashKey()
-	} else {
-		return newError("cannot use value of type %T as hash key", ind)
+	keys, values, err := iterate(iterable, node.Value == nil)
+	if err != nil {
+		return err
 	}
 
-	result, ok := hash.Items[hashKey]
-	if !ok {
-		return newError("attempts to read undefined hash key [%s]", ind.Inspect())
+	// the characters of the strings are new strings
+	if _, ok := iterable.(*object.String); ok {
+		for _, value := range values {
+			if measured := s.measure(value); isAbrupt(measured) {
+				return measured
+			}
+		}
 	}
 
-	return result.Value
-}
+	variables := []*ast.Identifier{node.Key}
+	if node.Value != nil {
+		variables = append(variables, node.Value)
+	}
+
+	for i := range keys {
+		if err := s.interrupted(); err != nil {
+			return err
+		}
+
+		iterEnv, err := s.enclose(env, len(variables))
+		if err != nil {
+			return err
+		}
+
+		iterEnv.Set(node.Key.Value, keys[i])
+
+		if node.Value != nil {
+			iterEnv.Set(node.Value.Value, values[i])
+		}
+
+		result := s.eval(node.Body, iterEnv)
+		s.leave(iterEnv)
+
+		if stop, out := loopControl(node.Label, result); stop {
+			return out
+		}
+	}
+
+	return NULL
+}
+
+// iterate returns the keys and the values visited by a for-in loop over the collection,
+// in the one variable form the keys are the items of arrays and strings and the keys of hashes.
+func iterate(collection object.Object, oneVariable bool) (keys, values []object.Object, err *object.Error) {
+	switch v := collection.(type) {
+	case *object.Array:
+		for i, item := range v.Items {
+			keys = append(keys, &object.Integer{Value: int64(i)})
+			values = append(values, item)
+		}
+	case *object.String:
+		for i, char := range []rune(v.Value) {
+			keys = append(keys, &object.Integer{Value: int64(i)})
+			values = append(values, &object.String{Value: string(char)})
+		}
+	case *object.Hash:
+		for _, pair := range v.Pairs() {
+			keys = append(keys, pair.Key)
+			values = append(values, pair.Value)
+		}
+	default:
+		return nil, nil, newError("cannot iterate over value of type %s", collection.Type())
+	}
+
+	if _, ok := collection.(*object.Hash); !ok && oneVariable {
+		keys = values
+	}
+
+	return keys, values, nil
+}
+
+// evalLoopCondition evaluates the condition of a loop, it returns TRUE or FALSE, or an error.
+func (s *state) evalLoopCondition(node ast.Expression, env *object.Environment) object.Object {
+	cond := s.eval(node, env)
+	if isAbrupt(cond) {
+		return cond
+	}
+
+	if cond.Type() != object.BOOLEAN_OBJ {
+		return ConditionError(LoopCondition, cond)
+	}
+
+	return cond
+}
+
+// loopControl inspects the result of an iteration of the loop with the given label, it reports
+// whether the loop must stop, and the value the loop evaluates to in that case.
+// the signals targeting an outer loop are passed through.
+func loopControl(label *ast.Identifier, result object.Object) (bool, object.Object) {
+	targets := func(target string) bool {
+		return target == "" || (label != nil && label.Value == target)
+	}
+
+	switch result := result.(type) {
+	case *object.Break:
+		if targets(result.Label) {
+			return true, NULL
+		}
+
+		return true, result
+	case *object.Continue:
+		if targets(result.Label) {
+			return false, nil
+		}
+
+		return true, result
+	case *object.ReturnValue, *tailCall, *object.Error:
+		return true, result
+	}
+
+	return false, nil
+}
+
+func (s *state) evalVariableInitializationExpression(node *ast.VarStatement, env *object.Environment) object.Object {
+	val := s.eval(node.Value, env)
+	if isAbrupt(val) {
+		return val
+	}
+
+	if env.Has(node.Name.Value) {
+		return RedefinitionError(node.Name.Value)
+	}
+
+	if err := s.reserve(variableSize); err != nil {
+		return err
+	}
+
+	// the functions are named after the variable they are declared with
+	if _, ok := node.Value.(*ast.FunctionLiteral); ok {
+		val.(*object.Function).Name = node.Name.Value
+	}
+
+	if node.Token.Type == token.CONST {
+		env.SetConst(node.Name.Value, val)
+	} else {
+		env.Set(node.Name.Value, val)
+	}
+
+	return NULL
+}
+
+// evalAssignExpression evaluates the assignments, it returns the assigned value.
+func (s *state) evalAssignExpression(node *ast.AssignExpression, env *object.Environment) object.Object {
+	val := s.eval(node.Value, env)
+	if isAbrupt(val) {
+		return val
+	}
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		return s.assignVariable(node.Operator, target.Value, val, env)
+	case *ast.IndexExpression:
+		return s.assignIndex(node.Operator, target, val, env)
+	case *ast.MemberExpression:
+		return s.assignAttr(node.Operator, target, val, env)
+	default:
+		return newError("cannot assign to %s", node.Target.String())
+	}
+}
+
+func (s *state) assignVariable(operator string, name string, val object.Object, env *object.Environment) object.Object {
+	scope := env.Resolve(name)
+	if scope == nil {
+		return UndefinedAssignmentError(name)
+	}
+
+	if scope.IsConst(name) {
+		return ConstantAssignmentError(name)
+	}
+
+	if operator != "=" {
+		current, _ := scope.Get(name)
+
+		val = s.infix(strings.TrimSuffix(operator, "="), current, val)
+		if isAbrupt(val) {
+			return val
+		}
+	}
+
+	return scope.Set(name, val)
+}
+
+func (s *state) assignIndex(operator string, target *ast.IndexExpression, val object.Object, env *object.Environment) object.Object {
+	left := s.eval(target.Left, env)
+	if isAbrupt(left) {
+		return left
+	}
+
+	index := s.eval(target.Index, env)
+	if isAbrupt(index) {
+		return index
+	}
+
+	if operator != "=" {
+		current := evalIndex(left, index)
+		if isAbrupt(current) {
+			return current
+		}
+
+		val = s.infix(strings.TrimSuffix(operator, "="), current, val)
+		if isAbrupt(val) {
+			return val
+		}
+	}
+
+	// the pair may be new
+	if _, ok := left.(*object.Hash); ok {
+		if err := s.reserve(pairSize); err != nil {
+			return err
+		}
+	}
+
+	return setIndex(left, index, val)
+}
+
+// setIndex stores the value at the index of an array or a hash, it returns the value.
+func setIndex(left object.Object, index object.Object, val object.Object) object.Object {
+	switch v := left.(type) {
+	case *object.Array:
+		i, err := arrayIndex(v, index)
+		if err != nil {
+			return err
+		}
+
+		v.Items[i] = val
+	case *object.Hash:
+		if err := hashPut(v, index, val); err != nil {
+			return err
+		}
+	default:
+		return newError("cannot assign to index of type %s", left.Type())
+	}
+
+	return val
+}
+
+func (s *state) assignAttr(operator string, target *ast.MemberExpression, val object.Object, env *object.Environment) object.Object {
+	obj := s.eval(target.Object, env)
+	if isAbrupt(obj) {
+		return obj
+	}
+
+	if operator != "=" {
+		current := getAttr(obj, target.Property.Value)
+		if isAbrupt(current) {
+			return current
+		}
+
+		val = s.infix(strings.TrimSuffix(operator, "="), current, val)
+		if isAbrupt(val) {
+			return val
+		}
+	}
+
+	return setAttr(obj, target.Property.Value, val)
+}
+
+// evalCallExpression evaluates the call, the errors that propagate out of it carry the call stack
+func (s *state) evalCallExpression(node *ast.CallExpression, env *object.Environment) object.Object {
+	result := s.evalCall(node, env)
+	s.traceError(result)
+
+	return result
+}
+
+func (s *state) evalCall(node *ast.CallExpression, env *object.Environment) object.Object {
+	function := s.evalCallee(node.Function, env)
+	if isAbrupt(function) {
+		return function
+	}
+
+	args := s.evalExpressions(node.Arguments, env)
+	if len(args) == 1 && isAbrupt(args[0]) {
+		return args[0]
+	}
+
+	return s.apply(node, function, args)
+}
+
+// evalCallee evaluates the function of a call, the method of a host object is bound to the object
+// instead of being read as an attribute.
+func (s *state) evalCallee(node ast.Expression, env *object.Environment) object.Object {
+	member, ok := node.(*ast.MemberExpression)
+	if !ok {
+		return s.eval(node, env)
+	}
+
+	if err := s.consume(s.costs.Node); err != nil {
+		return err
+	}
+
+	obj := s.eval(member.Object, env)
+	if isAbrupt(obj) {
+		return obj
+	}
+
+	return method(obj, member.Property.Value)
+}
+
+// tailCall is the result of a return statement calling a function, the function is called
+// by the caller once the current call is done so the tail calls don't grow the Go stack.
+// It has the type of the return values so it unwinds the function just like them.
+type tailCall struct {
+	node     *ast.CallExpression
+	function object.Object
+	args     []object.Object
+}
+
+func (*tailCall) Type() object.ObjectType { return object.RETURN_VALUE_OBJ }
+func (*tailCall) Inspect() string         { return "tail call" }
+
+func (s *state) evalTailCall(node *ast.CallExpression, env *object.Environment) object.Object {
+	function := s.evalCallee(node.Function, env)
+	if isAbrupt(function) {
+		return function
+	}
+
+	args := s.evalExpressions(node.Arguments, env)
+	if len(args) == 1 && isAbrupt(args[0]) {
+		return args[0]
+	}
+
+	// only the calls of functions are deferred, the other calls can't grow the stack
+	if _, ok := function.(*object.Function); !ok {
+		result := s.apply(node, function, args)
+		if isAbrupt(result) {
+			return result
+		}
+
+		return &object.ReturnValue{Value: result}
+	}
+
+	return &tailCall{node: node, function: function, args: args}
+}
+
+// apply calls the function with the arguments, then the functions it tail calls one after the other.
+func (s *state) apply(node *ast.CallExpression, function object.Object, args []object.Object) object.Object {
+	tail := false
+
+	for {
+		result := s.applyOnce(node, function, args, tail)
+
+		call, ok := result.(*tailCall)
+		if !ok {
+			// the errors of the tail calls are located at them, not at the first call,
+			// the calls made from Go have no node to locate the errors at.
+			if err, ok := result.(*object.Error); ok && !err.Pos.IsValid() && node != nil {
+				err.Pos = node.Pos()
+				err.End = node.End()
+			}
+
+			return result
+		}
+
+		node, function, args, tail = call.node, call.function, call.args, true
+	}
+}
+
+// Call calls back a function of the program from a builtin, see object.Runtime
+func (s *state) Call(fn object.Object, args ...object.Object) object.Object {
+	return s.apply(nil, fn, args)
+}
+
+// Allocate accounts for a value created by a builtin, see object.Runtime
+func (s *state) Allocate(items int, bytes int64) *object.Error {
+	return s.allocate(items, bytes)
+}
+
+// applyOnce calls the function, the result is a tail call if the function ends with one
+func (s *state) applyOnce(node *ast.CallExpression, function object.Object, args []object.Object, tail bool) object.Object {
+	if fn, ok := function.(*object.Builtin); ok {
+		if err := s.consume(s.costs.Builtin); err != nil {
+			return err
+		}
+
+		if fn.Runtime != nil {
+			return fn.Runtime(s, args...)
+		}
+
+		return s.measure(fn.Fn(args...), args...)
+	}
+
+	fn, ok := function.(*object.Function)
+	if !ok {
+		return NotCallableError(function)
+	}
+
+	if len(fn.Params) != len(args) {
+		return ArgumentsCountError(len(fn.Params), len(args))
+	}
+
+	if err := s.interrupted(); err != nil {
+		return err
+	}
+
+	if len(s.calls) >= s.options.CallDepthLimit() {
+		return RecursionDepthError()
+	}
+
+	newEnv, err := s.enclose(fn.Env, len(fn.Params))
+	if err != nil {
+		return err
+	}
+
+	for k, v := range fn.Params {
+		newEnv.Set(v.Value, args[k])
+	}
+
+	var pos token.Position
+	if node != nil {
+		pos = node.Pos()
+	}
+
+	s.calls = append(s.calls, call{fn: fn, pos: pos, args: args, tail: tail})
+	result := s.eval(fn.Body, newEnv)
+	s.traceError(result)
+	s.calls = s.calls[:len(s.calls)-1]
+	s.leave(newEnv)
+
+	switch result := result.(type) {
+	case *tailCall, *object.Error:
+		return result
+	case *object.ReturnValue:
+		return result.Value
+	}
+
+	return NULL
+}
+
+// traceError records the current call stack in the error, unless it is already recorded
+// by a deeper call. The stack is not recorded for the errors outside of functions.
+func (s *state) traceError(obj object.Object) {
+	err, ok := obj.(*object.Error)
+	if !ok || err.Stack != nil || len(s.calls) == 0 {
+		return
+	}
+
+	for i := len(s.calls) - 1; i >= 0; i-- {
+		c := s.calls[i]
+		err.Stack = append(err.Stack, object.Frame{Function: c.fn.Name, Pos: c.pos, Args: summarizeArgs(c.args), Tail: c.tail})
+	}
+}
+
+// summarizeArgs describes the arguments of a call, the long values are shortened
+func summarizeArgs(args []object.Object) string {
+	const maxLength = 20
+
+	summary := make([]string, len(args))
+
+	for i, arg := range args {
+		value := arg.Inspect()
+		if arg.Type() == object.STRING_OBJ {
+			value = strconv.Quote(value)
+		}
+
+		if runes := []rune(value); len(runes) > maxLength {
+			value = string(runes[:maxLength]) + "..."
+		}
+
+		summary[i] = value
+	}
+
+	return strings.Join(summary, ", ")
+}
+
+func (s *state) evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+	var result []object.Object
+
+	for _, exp := range exps {
+		evaluated := s.eval(exp, env)
+
+		if isAbrupt(evaluated) {
+			return []object.Object{evaluated}
+		}
+
+		result = append(result, evaluated)
+	}
+
+	return result
+}
+
+func (s *state) evalHash(hash *ast.HashLiteral, env *object.Environment) object.Object {
+	h := &object.Hash{Items: make(map[object.HashKey]object.HashPair)}
+
+	for _, k := range hash.Keys() {
+		v := hash.Items[k]
+
+		key := s.eval(k, env)
+		if isAbrupt(key) {
+			return key
+		}
+
+		val := s.eval(v, env)
+		if isAbrupt(val) {
+			return val
+		}
+
+		if err := hashPut(h, key, val); err != nil {
+			return err
+		}
+	}
+
+	if err := s.allocate(len(h.Items), hashSize+pairSize*int64(len(h.Items))); err != nil {
+		return err
+	}
+
+	return h
+}
+
+func hashPut(hash *object.Hash, key object.Object, value object.Object) *object.Error {
+	hashed, err := hashKey(key)
+	if err != nil {
+		return err
+	}
+
+	hash.Items[hashed] = object.HashPair{Key: key, Value: value}
+
+	return nil
+}
+
+func (s *state) evalMemberExpression(node *ast.MemberExpression, env *object.Environment) object.Object {
+	obj := s.eval(node.Object, env)
+	if isAbrupt(obj) {
+		return obj
+	}
+
+	// the attributes of the host objects may be computed when they are read
+	return s.measure(getAttr(obj, node.Property.Value))
+}
+
+// getAttr reads the attribute of a host object
+func getAttr(obj object.Object, name string) object.Object {
+	host, ok := obj.(object.HostObject)
+	if !ok {
+		return newError("cannot read attribute %s of type %s", name, obj.Type())
+	}
+
+	return hostResult(host.GetAttr(name))
+}
+
+// setAttr assigns the attribute of a host object, it returns the value
+func setAttr(obj object.Object, name string, value object.Object) object.Object {
+	host, ok := obj.(object.HostObject)
+	if !ok {
+		return newError("cannot assign to attribute %s of type %s", name, obj.Type())
+	}
+
+	if err := host.SetAttr(name, value); err != nil {
+		return &object.Error{Message: err.Error(), Cause: err}
+	}
+
+	return value
+}
+
+// method returns a builtin function calling the method of a host object
+func method(obj object.Object, name string) object.Object {
+	host, ok := obj.(object.HostObject)
+	if !ok {
+		return newError("cannot call method %s of type %s", name, obj.Type())
+	}
+
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			return hostResult(host.CallMethod(name, args...))
+		},
+	}
+}
+
+// hostResult converts the results of the methods of a host object to an object, the errors become error objects
+func hostResult(result object.Object, err error) object.Object {
+	if err != nil {
+		return &object.Error{Message: err.Error(), Cause: err}
+	}
+
+	if result == nil {
+		return NULL
+	}
+
+	return result
+}
+
+func (s *state) evalIndexExression(arr *ast.IndexExpression, env *object.Environment) object.Object {
+	left := s.eval(arr.Left, env)
+	if isAbrupt(left) {
+		return left
+	}
+
+	if err := checkIndexable(left); err != nil {
+		return err
+	}
+
+	index := s.eval(arr.Index, env)
+	if isAbrupt(index) {
+		return index
+	}
+
+	return evalIndex(left, index)
+}
+
+// checkIndexable returns an error if the value doesn't support the index operator
+func checkIndexable(left object.Object) *object.Error {
+	switch left.(type) {
+	case *object.Array, *object.Hash, object.Indexer:
+		return nil
+	default:
+		return newError("failed to read index on type %s", left.Type())
+	}
+}
+
+func evalIndex(left object.Object, index object.Object) object.Object {
+	switch v := left.(type) {
+	case *object.Array:
+		i, err := arrayIndex(v, index)
+		if err != nil {
+			return err
+		}
+
+		return v.Items[i]
+	case *object.Hash:
+		return evalHashIndexExression(v, index)
+	case object.Indexer:
+		return hostResult(v.Index(index))
+	default:
+		return newError("failed to read index on type %s", v.Type())
+	}
+}
+
+// arrayIndex validates the index of the array and converts it to int
+func arrayIndex(array *object.Array, ind object.Object) (int, *object.Error) {
+	if b, ok := ind.(*object.BigInt); ok {
+		return 0, newError("index out of range [%s] with length %d", b.Inspect(), len(array.Items))
+	}
+
+	index, ok := ind.(*object.Integer)
+	if !ok {
+		return 0, newError("cannot convert %s of type %s to type %s",
+			ind.Inspect(),
+			ind.Type(),
+			object.INTEGER_OBJ,
+		)
+	}
+
+	if index.Value < 0 || index.Value >= int64(len(array.Items)) {
+		return 0, newError("index out of range [%d] with length %d",
+			index.Value,
+			len(array.Items),
+		)
+	}
+
+	return int(index.Value), nil
+}
+
+func evalHashIndexExression(hash *object.Hash, ind object.Object) object.Object {
+	hashable, ok := ind.(object.Hashable)
+	if !ok {
+		return newError("invalid hash key  %s of type %s ",
+			ind.Inspect(),
+			ind.Type(),
+		)
+	}
+
+	result, ok := hash.Items[hashable.HashKey()]
+	if !ok {
+		return newError("attempts to read undefined hash key [%s]", ind.Inspect())
+	}
+
+	return result.Value
+}
+
+func isNumeric(obj object.Object) bool {
+	return isInteger(obj) || obj.Type() == object.FLOAT_OBJ
+}
+
+func isInteger(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.BIGINT_OBJ
+}
+
+// toFloat converts a numeric object to float64, it must only be called on numeric objects
+func toFloat(obj object.Object) float64 {
+	switch v := obj.(type) {
+	case *object.Integer:
+		return float64(v.Value)
+	case *object.BigInt:
+		f, _ := new(big.Float).SetInt(v.Value).Float64()
+		return f
+	default:
+		return obj.(*object.Float).Value
+	}
+}
+
+// toBigInt converts an integer object to *big.Int, it must only be called on integer objects
+func toBigInt(obj object.Object) *big.Int {
+	if i, ok := obj.(*object.Integer); ok {
+		return big.NewInt(i.Value)
+	}
+
+	return obj.(*object.BigInt).Value
+}
+
+// bigIntObject returns an Integer if the value fits in 64 bits, a BigInt otherwise
+func bigIntObject(value *big.Int) object.Object {
+	if value.IsInt64() {
+		return &object.Integer{Value: value.Int64()}
+	}
+
+	return &object.BigInt{Value: value}
+}
+
+// isTruthy reports whether the object is considered true, false, null, zero,
+// the empty string, the empty array and the empty hash are falsy, everything else is truthy.
+func isTruthy(obj object.Object) bool {
+	switch v := obj.(type) {
+	case *object.Boolean:
+		return v.Value
+	case *object.Null:
+		return false
+	case *object.Integer:
+		return v.Value != 0
+	case *object.Float:
+		return v.Value != 0
+	case *object.String:
+		return v.Value != ""
+	case *object.Array:
+		return len(v.Items) > 0
+	case *object.Hash:
+		return len(v.Items) > 0
+	default:
+		return true
+	}
+}
 
 func nativeBooleanObject(input bool) *object.Boolean {
 	if input {
@@ -516,6 +1581,22 @@ func newError(msg string, args ...any) *object.Error {
 	return &object.Error{Message: fmt.Sprintf(msg, args...)}
 }
 
+// isAbrupt reports whether the evaluation of a node didn't complete normally, because of an
+// error or a return, break or continue statement. The enclosing nodes must stop and pass the
+// object through, even when the statement is nested in an expression.
+func isAbrupt(obj object.Object) bool {
+	if obj == nil {
+		return false
+	}
+
+	switch obj.Type() {
+	case object.ERROR_OBJ, object.RETURN_VALUE_OBJ, object.BREAK_OBJ, object.CONTINUE_OBJ:
+		return true
+	}
+
+	return false
+}
+
 func isError(obj object.Object) bool {
 	if obj != nil {
 		return obj.Type() == object.ERROR_OBJ
@@ -1,8 +1,15 @@
 package eval
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/yassinebenaid/nishimia/ast"
 	"github.com/yassinebenaid/nishimia/lexer"
 	"github.com/yassinebenaid/nishimia/object"
 	"github.com/yassinebenaid/nishimia/parser"
@@ -37,6 +44,143 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestBigIntegerPromotion(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"-9223372036854775807 - 2", "-9223372036854775809"},
+		{"9223372036854775807 * 2", "18446744073709551614"},
+		{"-9223372036854775807 - 1", "-9223372036854775808"},
+		{"(-9223372036854775807 - 1) / -1", "9223372036854775808"},
+		{"-(-9223372036854775807 - 1)", "9223372036854775808"},
+		{"99999999999999999999", "99999999999999999999"},
+		{"99999999999999999999 * 99999999999999999999", "9999999999999999999800000000000000000001"},
+		{"99999999999999999999 - 99999999999999999998", "1"},
+		{"(9223372036854775807 + 1) / 2", "4611686018427387904"},
+		{`int("123456789012345678901234567890")`, "123456789012345678901234567890"},
+		{"int(1e20)", "100000000000000000000"},
+		{"float(99999999999999999999)", "1e+20"},
+		{"99999999999999999999 + 0.5", "1e+20"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if isError(evaluated) {
+			t.Errorf("unexpected error for %q: %s", tt.input, evaluated.Inspect())
+			continue
+		}
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	// results that fit in 64 bits are always demoted back to integers
+	testIntegerObject(t, testEval("99999999999999999999 - 99999999999999999998"), 1)
+	testIntegerObject(t, testEval("-(-9223372036854775807 - 1) - 1"), 9223372036854775807)
+}
+
+func TestBigIntegerComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"99999999999999999999 > 1", true},
+		{"1 < 99999999999999999999", true},
+		{"99999999999999999999 == 99999999999999999999", true},
+		{"99999999999999999999 != 99999999999999999998", true},
+		{"99999999999999999999 <= 99999999999999999998", false},
+		{"-99999999999999999999 < -99999999999999999998", true},
+		{`{99999999999999999999: true}[99999999999999999998 + 1]`, true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14;", 3.14},
+		{".5;", 0.5},
+		{"-2.5;", -2.5},
+		{"+2.5;", 2.5},
+		{"1.5 + 1.5", 3},
+		{"1 + 0.5", 1.5},
+		{"0.5 + 1", 1.5},
+		{"10 * 0.15", 1.5},
+		{"7 / 2.0", 3.5},
+		{"1e3 - 1", 999},
+		{"float(7) / 2", 3.5},
+		{`float("2.25")`, 2.25},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testFloatObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestMixedNumericComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"1 == 1.0", true},
+		{"1.0 != 1", false},
+		{"0.5 < 1", true},
+		{"2 > 2.5", false},
+		{"2 >= 2.0", true},
+		{"0.1 + 0.2 <= 0.3", false},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestNumericConversion(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"int(3.99)", 3},
+		{"int(-3.99)", -3},
+		{"int(5)", 5},
+		{`int("42")`, 42},
+		{"7 / 2", 3},
+		{`int("4.2")`, "cannot convert \"4.2\" to INTEGER"},
+		{`int(true)`, "argument to `int` not supported, got BOOLEAN"},
+		{`float("abc")`, "cannot convert \"abc\" to FLOAT"},
+		{"1.5 && 2", "invalid operation: operator && not defined on 1.5 (FLOAT)"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
 func TestEvalStringExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -74,233 +218,1440 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"(true && false) != true;", true},
 		{"(true && false) != true;", true},
 		{"(false && false) != true;", true},
+		{`"a" == "a";`, true},
+		{`"a" != "a";`, false},
+		{`"abc" < "abd";`, true},
+		{`"b" > "abc";`, true},
+		{`"é" >= "e";`, true},
+		{`"" <= "";`, true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestShortCircuitEvaluation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"false && undefined", false},
+		{"true || undefined", true},
+		{"false && 1 / 0 == 1", false},
+		{"true || [][0]", true},
+		{"var arr = []; len(\"\") != 0 && arr[0] == 1", false},
+		{"var fail = func() { return 1 / 0; }; false && fail()", false},
+		{"var fail = func() { return 1 / 0; }; true || fail()", true},
+		{"true && (false || true)", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+
+	errors := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"true && undefined", "undefined identifier : undefined"},
+		{"false || 1 / 0", "invalid operation: 1 / 0 (division by zero)"},
+		{"1 && false", "invalid operation: operator && not defined on 1 (INTEGER)"},
+		{"true && 1", "invalid operation: true && 1 (mismatched types BOOLEAN and INTEGER)"},
+		{`"a" || "b"`, "invalid operation: operator || not defined on a (STRING)"},
+		{"1 && 1 / 0", "invalid operation: operator && not defined on 1 (INTEGER)"},
+	}
+
+	for _, tt := range errors {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+	}
+
+	// the right operand doesn't run when the left one is not a boolean
+	for _, input := range []string{"1 && count()", `"a" || count()`, "[] && count()"} {
+		env := object.NewEnvirement()
+		program := parser.New(lexer.New("var n = 0; var count = func() { n += 1; return true; }; " + input)).ParseProgram()
+
+		testErrorObject(t, Eval(program, env))
+
+		if n, _ := env.Get("n"); n.Inspect() != "0" {
+			t.Errorf("expected the right operand of %q not to be evaluated, it ran %s times", input, n.Inspect())
+		}
+	}
+}
+
+func TestTruthyOr(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`"" || "default"`, "default"},
+		{`"name" || "default"`, "name"},
+		{`0 || 5`, 5},
+		{`3 || undefined`, 3},
+		{`[] || "empty"`, "empty"},
+		{`{} || "empty"`, "empty"},
+		{`if true {} || "null"`, "null"},
+		{`false || 0.0 || "zero"`, "zero"},
+		{`true || false`, true},
+		{`false || false`, false},
+	}
+
+	evaluator := New(Options{TruthyOr: true})
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		evaluated := evaluator.Eval(program, object.NewEnvirement())
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testStringObject(t, evaluated, expected)
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		}
+	}
+
+	// the default evaluator keeps || strictly boolean
+	testErrorObject(t, testEval(`"" || "default"`))
+}
+
+func TestBangOperatorExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"!true;", false},
+		{"!false;", true},
+		{"!!false;", false},
+		{"!!!true;", false},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestIfElseExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"if (true) { +10 }", 10},
+		{"if (false) { 10 }", nil},
+		{"if (1 < 2) { 10 }", 10},
+		{"if (1 > 2) { 10 }", nil},
+		{"if (1 > 2) { 10 } else { 20 }", 20},
+		{"if (1 < 2) { 10 } else { 20 }", 10},
+		{"if (1 <= 2) { 10 } else { 20 }", 10},
+		{"if (1 >= 2) { 10 } else { 20 }", 20},
+		{"if (true && false) { 10 } else { 20 }", 20},
+		{"if (false || true) { 10 } else { 20 }", 10},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestReturnStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"return 10;", 10},
+		{"return 10; 9;", 10},
+		{"return 10; 9;", 10},
+		{"return 2 * 5; 9;", 10},
+		{"9; return 2 * 5; 9;", 10},
+		{"9; return true; 9;", true},
+		{"9; return false; 9;", false},
+		{"9; return true && false; 9;", false},
+		{"9; return false || true; 9;", true},
+		{"9; return 10 <= 2; 9;", false},
+		{`if 10 > 1 {
+			if 10 > 1 {
+				if true {
+					return 10;
+				}
+			}
+			return 1;
+		}`, 10},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch v := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(v))
+		case bool:
+			testBooleanObject(t, evaluated, v)
+		}
+	}
+}
+
+func TestErrorHandling(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"5 + true;", "invalid operation: 5 + true (mismatched types INTEGER and BOOLEAN)"},
+		{"5 + true; 5;", "invalid operation: 5 + true (mismatched types INTEGER and BOOLEAN)"},
+		{"-true", "invalid operation: -true (operator \"-\" not defined on BOOLEAN)"},
+		{"+false", "invalid operation: +false (operator \"+\" not defined on BOOLEAN)"},
+		{"!1", "invalid operation: !1 (operator \"!\" not defined on INTEGER)"},
+		{"!(1*5)", "invalid operation: !5 (operator \"!\" not defined on INTEGER)"},
+		{"if(1+5){5}", "non-boolean value in if-statement , ( got=INTEGER, want=BOOLEAN )"},
+		{"if(1){5}", "non-boolean value in if-statement , ( got=INTEGER, want=BOOLEAN )"},
+		{"a;", "undefined identifier : a"},
+		{`"yassine" - "benaid"`, "invalid operation: yassine - benaid"},
+		{"var a = [1]; a[0] = a; a + 1;", "invalid operation: [[...]] + 1 (mismatched types ARRAY and INTEGER)"},
+		{"{} + 1", "invalid operation: {} + 1 (mismatched types HASH and INTEGER)"},
+		{"var a = [1]; a[0] = a; var f = func(x) { return x + 1; }; f(a)", "invalid operation: [[...]] + 1 (mismatched types ARRAY and INTEGER)"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)",
+				evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `
+	// doubles its argument
+	var double = func(x) {
+		/* the result /* is always even */ */
+		return x * 2; // trailing
+	};
+
+	double(21) // 42
+	`
+
+	testIntegerObject(t, testEval(input), 42)
+}
+
+func TestArithmeticErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"10 / 0", "invalid operation: 10 / 0 (division by zero)"},
+		{"var zero = 1 - 1; 10 / zero", "invalid operation: 10 / 0 (division by zero)"},
+		{"99999999999999999999 / 0", "invalid operation: 99999999999999999999 / 0 (division by zero)"},
+		{"1.5 / 0", "invalid operation: 1.5 / 0.0 (division by zero)"},
+		{"1 / 0.0", "invalid operation: 1.0 / 0.0 (division by zero)"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestEmptyBlocks(t *testing.T) {
+	testNullObject(t, testEval("func(){}()"))
+	testNullObject(t, testEval("if true {}"))
+	testNullObject(t, testEval("var x = if true {}; x"))
+}
+
+func TestInternalPanicRecovery(t *testing.T) {
+	// a malformed tree that the parser would never produce
+	program := &ast.Program{Statements: []ast.Statement{&ast.ExpressionStatement{}}}
+
+	evaluated := Eval(program, object.NewEnvirement())
+
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+
+	if !strings.HasPrefix(errObj.Message, "internal error: ") {
+		t.Errorf("expected an internal error, got=%q", errObj.Message)
+	}
+
+	if !strings.Contains(errObj.GoStack, "evalNode") {
+		t.Errorf("expected the error to carry the Go stack trace, got=%q", errObj.GoStack)
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"5 + true;", "ERROR: main.ns:1:1: invalid operation: 5 + true (mismatched types INTEGER and BOOLEAN)"},
+		{"var a = 1;\n\nvar b = a + c;", "ERROR: main.ns:3:13: undefined identifier : c"},
+		{"var f = func() {\n\treturn -true;\n};\nf();", "ERROR: main.ns:2:9: invalid operation: -true (operator \"-\" not defined on BOOLEAN)"},
+		{`var s = "x"; len(1)`, "ERROR: main.ns:1:14: argument to `len` not supported, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.NewFile("main.ns", tt.input)).ParseProgram()
+		evaluated := Eval(program, object.NewEnvirement())
+
+		if !testErrorObject(t, evaluated) {
+			continue
+		}
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestVarStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"var a = 5; a;", 5},
+		{"var a = 5 * 5; a;", 25},
+		{"var a = 5; var b = a; b;", 5},
+		{"var a = 5; var b = a; var c = a + b + 5; c;", 15},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+
+	testNullObject(t, testEval("var num = 10;"))
+}
+
+func TestAssignments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"var a = 5; a = 10; a;", 10},
+		{"var a = 5; a = 10;", 10},
+		{"var a = 1; var b = 2; a = b = 3; a + b;", 6},
+		{"var a = 5; a += 2; a;", 7},
+		{"var a = 5; a -= 2; a;", 3},
+		{"var a = 5; a *= 2; a;", 10},
+		{"var a = 5; a /= 2; a;", 2},
+		{"var a = 5; a %= 2; a;", 1},
+		{"var a = 1.5; a += 1; a;", 2.5},
+		{`var s = "hello"; s += " world"; s;`, "hello world"},
+		{"var a = 9223372036854775807; a += 1; a;", "9223372036854775808"},
+		{"var count = 0; var inc = func() { count += 1; }; inc(); inc(); count;", 2},
+		{"var a = 1; var f = func() { var a = 2; a = 3; }; f(); a;", 1},
+		{"var arr = [1, 2, 3]; arr[0] = 10; arr[0] + arr[2];", 13},
+		{"var arr = [1, 2, 3]; arr[1] += 5; arr;", "[1, 7, 3]"},
+		{"var arr = [1, 2, 3]; var alias = arr; alias[2] = 0; arr[2];", 0},
+		{`var h = {"a": 1}; h["b"] = 2; h["a"] + h["b"];`, 3},
+		{`var h = {"a": 1}; h["a"] *= 10; h["a"];`, 10},
+		{`var h = {}; h[true] = "yes"; h[true];`, "yes"},
+		{"const pi = 3; var area = func(r) { return pi * r * r; }; area(2);", 12},
+		{"const c = 1; var f = func() { var c = 2; c = 3; return c; }; f();", 3},
+		{"const arr = [1]; arr[0] = 2; arr[0];", 2},
+		{"10 % 3", 1},
+		{"-10 % 3", -1},
+		{"5.5 % 2", 1.5},
+		{"99999999999999999999 % 7", 1},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case string:
+			if evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %q. expected=%s, got=%s", tt.input, expected, evaluated.Inspect())
+			}
+		}
+	}
+
+	errors := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"a = 1", "cannot assign to undefined variable a"},
+		{"len = 1", "cannot assign to undefined variable len"},
+		{"const a = 1; a = 2", "cannot assign to constant a"},
+		{"const a = 1; a += 2", "cannot assign to constant a"},
+		{"const a = 1; var f = func() { a = 2; }; f()", "cannot assign to constant a"},
+		{"const a = 1; var a = 2;", "variable a already defined"},
+		{`var a = 1; a += "x"`, "invalid operation: 1 + x (mismatched types INTEGER and STRING)"},
+		{"var a = 1; a /= 0", "invalid operation: 1 / 0 (division by zero)"},
+		{"var a = 1; a %= 0", "invalid operation: 1 % 0 (division by zero)"},
+		{"var arr = [1]; arr[1] = 2", "index out of range [1] with length 1"},
+		{"var arr = [1]; arr[-1] += 2", "index out of range [-1] with length 1"},
+		{`var h = {}; h["a"] += 1`, "attempts to read undefined hash key [a]"},
+		{`var h = {}; h[[1]] = 1`, "unusable as hash key: ARRAY"},
+		{"var h = {}; h[func(x) { x }] = 1", "unusable as hash key: FUNCTION"},
+		{"var f = func(x) { x }; {f: 1}", "unusable as hash key: FUNCTION"},
+		{`var s = "abc"; s[0] = "x"`, "cannot assign to index of type STRING"},
+	}
+
+	for _, tt := range errors {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestElseIfChains(t *testing.T) {
+	sign := "var sign = func(x) { if x < 0 { return -1; } else if x == 0 { return 0; } else { return 1; } };"
+
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{sign + "sign(-5)", -1},
+		{sign + "sign(0)", 0},
+		{sign + "sign(7)", 1},
+		{"if false { 1 } else if false { 2 } else if true { 3 } else { 4 }", 3},
+		{"if false { 1 } else if false { 2 }", nil},
+		{"if false { 1 } else if true { 2 }", 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if expected, ok := tt.expected.(int); ok {
+			testIntegerObject(t, evaluated, int64(expected))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestMatchExpressions(t *testing.T) {
+	describe := `var describe = func(v) {
+		return match v {
+			case 0 { "zero" }
+			case -1 { "minus one" }
+			case "hi" { "greeting" }
+			case true { "yes" }
+			case [] { "empty" }
+			case [a, _] if a > 10 { "big pair" }
+			case [a, b] { "pair " + b }
+			case [[x], _, _] { "nested " + x }
+			case {"name": n, "age": 30} { "thirty " + n }
+			case {"name": n} { "named " + n }
+			default { "other" }
+		};
+	};`
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`describe(0)`, "zero"},
+		{`describe(0.0)`, "zero"},
+		{`describe(-1)`, "minus one"},
+		{`describe("hi")`, "greeting"},
+		{`describe(true)`, "yes"},
+		{`describe(false)`, "other"},
+		{`describe([])`, "empty"},
+		{`describe([11, 2])`, "big pair"},
+		{`describe([1, "two"])`, "pair two"},
+		{`describe([["a"], 1, 2])`, "nested a"},
+		{`describe([1, 2, 3])`, "other"},
+		{`describe({"name": "bob", "age": 30})`, "thirty bob"},
+		{`describe({"name": "alice", "age": 20})`, "named alice"},
+		{`describe({"age": 20})`, "other"},
+		{`describe(5)`, "other"},
+		{`describe("0")`, "other"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(describe + tt.input)
+		testStringObject(t, evaluated, tt.expected)
+	}
+
+	others := []struct {
+		input    string
+		expected any
+	}{
+		{"match 5 { default { 1 } case 5 { 2 } }", 2},
+		{"match 5 { case x { x * 2 } }", 10},
+		{"match 150 { case n if n > 100 { 1 } case n if n > 10 { 2 } }", 1},
+		{"match 50 { case n if n > 100 { 1 } case n if n > 10 { 2 } }", 2},
+		{"match 5 { case _ { 1 } default { 2 } }", 1},
+		{"match 5 { case 5 { } }", nil},
+		{"var x = 1; match [2] { case [x] { x } }; x;", 1},
+		{"match 99999999999999999999 { case 99999999999999999999 { 1 } }", 1},
+		{"var f = func() { match 1 { case 1 { return 7; } }; return 0; }; f();", 7},
+		{"var n = 0; for x in [1, 2, 3] { match x { case 2 { break; } default { n += x; } } } n;", 1},
+	}
+
+	for _, tt := range others {
+		evaluated := testEval(tt.input)
+
+		if expected, ok := tt.expected.(int); ok {
+			testIntegerObject(t, evaluated, int64(expected))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+
+	errors := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"match 3 { case 1 { } case 2 { } }", "no match arm for value 3"},
+		{"match [1] { case [x] if x > 1 { } }", "no match arm for value [1]"},
+		{"match 1 { case x if x { } }", "non-boolean value in match guard , ( got=INTEGER, want=BOOLEAN )"},
+		{"match y { default { } }", "undefined identifier : y"},
+		{"match 1 { case x { x + true } }", "invalid operation: 1 + true (mismatched types INTEGER and BOOLEAN)"},
+	}
+
+	for _, tt := range errors {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"var i = 0; while i < 10 { i += 1; } i;", 10},
+		{"var i = 0; while false { i += 1; } i;", 0},
+		{"var sum = 0; for var i = 1; i <= 100; i += 1 { sum += i; } sum;", 5050},
+		{"var i = 0; for ; i < 3; { i += 1; } i;", 3},
+		{"var i = 0; for ;; { i += 1; if i == 5 { break; } } i;", 5},
+		{"var n = 0; for var i = 0; i < 10; i += 1 { if i % 2 == 0 { continue; } n += 1; } n;", 5},
+		{"var i = 0; while true { i += 1; if i < 3 { continue; } break; } i;", 3},
+		{"var sum = 0; for x in [1, 2, 3] { sum += x; } sum;", 6},
+		{"var sum = 0; for i, x in [10, 20, 30] { sum += i * x; } sum;", 80},
+		{`var s = ""; for k in {"b": 1, "a": 2} { s += k; } s;`, "ab"},
+		{`var sum = 0; for k, v in {"a": 1, "b": 2} { sum += v; } sum;`, 3},
+		{`var s = ""; for k, v in {3: "c", 1: "a", 2: "b"} { s += v; } s;`, "abc"},
+		{`var s = ""; for c in "héllo" { s = c + s; } s;`, "olléh"},
+		{`var n = 0; for i, c in "日本語" { n = i; } n;`, 2},
+		{"var sum = 0; for x in [] { sum += 1; } sum;", 0},
+		{"var arr = [1, 2]; for x in arr { arr[0] = 5; } arr[0];", 5},
+		{"for x in [1] { var y = x; } for x in [2] { var y = x; }", nil},
+		{"var i = 0; while i < 3 { var x = i; i += 1; } i;", 3},
+		{"for var i = 0; i < 3; i += 1 {}", nil},
+		{`
+		var count = 0;
+		outer: for var i = 0; i < 3; i += 1 {
+			for var j = 0; j < 3; j += 1 {
+				if j == 1 { continue outer; }
+				count += 1;
+			}
+		}
+		count;`, 3},
+		{`
+		var count = 0;
+		outer: while true {
+			for x in [1, 2, 3] {
+				if x == 2 { break outer; }
+				count += x;
+			}
+		}
+		count;`, 1},
+		{`
+		var find = func(items, target) {
+			for i, item in items {
+				if item == target { return i; }
+			}
+			return -1;
+		};
+		find([5, 6, 7], 7) + find([1], 5);`, 1},
+		{`
+		var first = 0;
+		for x in [1, 2, 3] { if x == 1 { first = func() { return x; }; } }
+		first();`, 1},
+		{"var sum = 0; for x in [1, 2, 3] { sum += match x { case 2 { continue; } default { x } }; } sum;", 4},
+		{"var sum = 0; for x in [1, 2, 3] { sum += [if x == 2 { break; } else { x }][0]; } sum;", 1},
+		{"var sum = 0; outer: for x in [1, 2] { while if x == 2 { break outer; } else { false } { } sum += x; } sum;", 1},
+		{"var f = func() { var x = [1, if true { return 2; } else { 3 }]; return 0; }; f();", 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testStringObject(t, evaluated, expected)
+		case nil:
+			testNullObject(t, evaluated)
+		}
+	}
+
+	errors := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"while 1 { }", "non-boolean value in loop condition , ( got=INTEGER, want=BOOLEAN )"},
+		{"for ; 1; { }", "non-boolean value in loop condition , ( got=INTEGER, want=BOOLEAN )"},
+		{"for x in 5 { }", "cannot iterate over value of type INTEGER"},
+		{"for x in [1] { x + true; }", "invalid operation: 1 + true (mismatched types INTEGER and BOOLEAN)"},
+		{"for var i = 0; i < 3; i += true { }", "invalid operation: 0 + true (mismatched types INTEGER and BOOLEAN)"},
+		{"for var i = 0; i < 3; i += 1 { } i;", "undefined identifier : i"},
+		{"for x in [1] { } x;", "undefined identifier : x"},
+	}
+
+	for _, tt := range errors {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestLargeLoop(t *testing.T) {
+	evaluated := testEval("var n = 0; while n < 1000000 { n += 1; } n;")
+	testIntegerObject(t, evaluated, 1000000)
+}
+
+func TestFunction(t *testing.T) {
+	input := "func(x) { x + 2; };"
+
+	evaluated := testEval(input)
+
+	fn, ok := evaluated.(*object.Function)
+	if !ok {
+		t.Fatalf("object is not Function. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if len(fn.Params) != 1 {
+		t.Fatalf("function has wrong params. Params=%+v",
+			fn.Params)
+	}
+
+	if fn.Params[0].String() != "x" {
+		t.Fatalf("param is not 'x'. got=%q", fn.Params[0])
+	}
+
+	expectedBody := "{(x + 2)}"
+
+	if fn.Body.String() != expectedBody {
+		t.Fatalf("body is not %q. got=%q", expectedBody, fn.Body.String())
+	}
+
+}
+
+func TestFunctionApplication(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"func(x) { return x; }(5);", 5},
+		{"var identity = func(x) { return x; }; identity(5);", 5},
+		{"var identity = func(x) { return x; }; identity(5);", 5},
+		{"var double = func(x) { return x * 2; }; double(5);", 10},
+		{"var add = func(x, y) { return x + y; }; add(5, 5);", 10},
+		{"var add = func(x, y) { return x + y; }; add(5 + 5, add(5, 5));", 20},
+		{"var num = 4;var add = func(num) { return num + 2; }; add(num);", 6},
+		{"var num = 4;var add = func(num) { return num + 2; }; add(num+5);", 11},
+		{"var num = 4;var add = func(num) { return num + 2; }; add(num*2);", 10},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestClosures(t *testing.T) {
+	input := `
+	var i = 0;
+	var newAdder = func(x) {
+		var it = i;
+		return func(y) { return x + y + +it; };
+	};
+	var addTwo = newAdder(2);
+	addTwo(2);
+	`
+	testIntegerObject(t, testEval(input), 4)
+}
+
+func TestCallStack(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + true", ""},
+		{"var f = func(x) { x + true }; f(1)", "at f(1) 1:31"},
+		{
+			"var f = func(x) { return x / 0; };\nvar g = func(s, a) { return f(len(s)); };\ng(\"name\", [1, 2]);",
+			"at f(4) 2:29\n(...tail calls...)",
+		},
+		{"var f = func() { return undefined; }; var g = func(h) { h() }; g(f)", "at f() 1:57\nat g(func(){return undefi...) 1:64"},
+		{"func(x) { x() }(1)", "at <anonymous>(1) 1:1"},
+		{"var f = func() { return len(1); }; f()", "at f() 1:36"},
+		{"var f = func(x, y) { x - y }; [f(1, 2), f(\"abcdefghijklmnopqrstuvwxyz\", 2)]", "at f(\"abcdefghijklmnopqrs..., 2) 1:41"},
+		{"var f = func(x) { x }; f(1, 2)", ""},
+	}
+
+	for _, tt := range tests {
+		err, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("expected an error for %q", tt.input)
+			continue
+		}
+
+		if err.StackTrace() != tt.expected {
+			t.Errorf("wrong stack trace for %q.\nexpected=\n%s\ngot=\n%s", tt.input, tt.expected, err.StackTrace())
+		}
+	}
+}
+
+func TestCallDepth(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"var f = func(n) { return 1 + f(n + 1); }; f(0)", "ERROR: 1:30: maximum recursion depth exceeded"},
+		{"var f = func(n) { if n == 0 { return 0; } return 1 + f(n - 1); }; f(5000)", 5000},
+		{"var loop = func(n, acc) { if n == 0 { return acc; } return loop(n - 1, acc + 1); }; loop(100000, 0)", 100000},
+		{
+			"var even = func(n) { if n == 0 { return true; } return odd(n - 1); }; var odd = func(n) { if n == 0 { return false; } return even(n - 1); }; even(50001)",
+			false,
+		},
+		{"var f = func(n) { for x in [1] { return match n { case 0 { 0 } default { f(n - 1) } }; } }; f(100)", 0},
+		{"var f = func(n) { while true { if n == 0 { return 0; } return f(n - 1); } }; f(100000)", 0},
+		{"var f = func(n) { return len(n); }; f(\"abc\")", 3},
+		{"var f = func(n) { return n(1); }; f(2)", "ERROR: 1:26: invalid identifier in function call : 2 is not a valid identifier or function literal"},
+		{"var g = func(x, y) { x }; var f = func(n) { return g(n); }; f(2)", "ERROR: 1:52: invalid arguments count in function call, expected 2 argumets, got 1 "},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			if evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %q. expected=%s, got=%s", tt.input, expected, evaluated.Inspect())
+			}
+		}
+	}
+
+	evaluator := New(Options{MaxCallDepth: 3})
+	program := parser.New(lexer.New("var f = func(n) { if n == 0 { return 0; } return 1 + f(n - 1); }; [f(2), f(3)]")).ParseProgram()
+
+	if result := evaluator.Eval(program, object.NewEnvirement()); result.Inspect() != "ERROR: 1:54: maximum recursion depth exceeded" {
+		t.Errorf("expected the maximum depth to be exceeded, got=%s", result.Inspect())
+	}
+}
+
+func TestEvalContext(t *testing.T) {
+	inputs := []string{
+		"while true { }",
+		"for ; ; { }",
+		"var i = 0; for x in [1, 2] { while true { i += x; } }",
+		"var f = func() { return f(); }; f()",
+		"var f = func(n) { if n > 0 { return 1 + f(n - 1); } return 0; }; var g = func() { while true { f(100); } }; g()",
+	}
+
+	for _, input := range inputs {
+		program := parser.New(lexer.New(input)).ParseProgram()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		result := EvalContext(ctx, program, object.NewEnvirement())
+		cancel()
+
+		if !IsCancellation(result) {
+			t.Errorf("expected a cancellation error for %q, got=%s", input, result.Inspect())
+			continue
+		}
+
+		if !errors.Is(result.(*object.Error).Cause, context.DeadlineExceeded) {
+			t.Errorf("expected the deadline to be the cause of the error for %q", input)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	program := parser.New(lexer.New("var f = func(x) { x }; f(1)")).ParseProgram()
+	if result := EvalContext(ctx, program, object.NewEnvirement()); result.Inspect() != "ERROR: 1:24: execution cancelled: context canceled" {
+		t.Errorf("expected the call to be cancelled, got=%s", result.Inspect())
+	}
+
+	// the programs that don't loop or call functions run to completion
+	if result := EvalContext(ctx, parser.New(lexer.New("1 + 2")).ParseProgram(), object.NewEnvirement()); IsCancellation(result) {
+		t.Errorf("expected the program to run, got=%s", result.Inspect())
+	}
+
+	if IsCancellation(testEval("1 / 0")) {
+		t.Errorf("expected the other errors not to be cancellations")
+	}
+}
+
+func TestBudget(t *testing.T) {
+	costs := &Costs{Node: 1, Allocation: 10, Builtin: 100}
+
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"1 + 2", 5},
+		{"[1, 2]", 5 + 30},
+		{`{"a": 1}`, 5 + 20},
+		{`len("abc")`, 5 + 100},
+		{"var f = func() { 1 }; f()", 9 + 20},
+		{"for x in [1, 2] { }", 7 + 30 + 20},
+		// the builtins creating collections pay for every item
+		{"len(range(3))", 7 + 200 + 40},
+		{"sort(reverse([1, 2]))", 9 + 200 + 30 + 30 + 30},
+		{`keys({"a": 1})`, 7 + 100 + 20 + 20},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+
+		// the same program always consumes the same gas
+		for i := 0; i < 2; i++ {
+			result, stats := New(Options{Costs: costs}).EvalWithStats(context.Background(), program, object.NewEnvirement())
+			if isError(result) {
+				t.Fatalf("unexpected error for %q: %s", tt.input, result.Inspect())
+			}
+
+			if stats.Gas != tt.expected {
+				t.Errorf("wrong gas consumed by %q. expected=%d, got=%d", tt.input, tt.expected, stats.Gas)
+			}
+		}
+	}
+
+	program := parser.New(lexer.New("var i = 0;\nwhile true { i += 1; }")).ParseProgram()
+	result, stats := New(Options{Budget: 1000}).EvalWithStats(context.Background(), program, object.NewEnvirement())
+
+	err, ok := result.(*object.Error)
+	if !ok || err.Message != "budget exhausted" || !errors.Is(err.Cause, ErrBudgetExhausted) {
+		t.Fatalf("expected the budget to be exhausted, got=%s", result.Inspect())
+	}
+
+	if !err.Pos.IsValid() {
+		t.Errorf("expected the error to be located")
+	}
+
+	if stats.Gas != 1000 {
+		t.Errorf("expected the whole budget to be consumed, got=%d", stats.Gas)
+	}
+
+	// the gas is counted even without a budget
+	if _, stats := New(Options{}).EvalWithStats(context.Background(), program.Statements[0], object.NewEnvirement()); stats.Gas != DefaultCosts.Node*2 {
+		t.Errorf("wrong gas consumed without a budget. expected=%d, got=%d", DefaultCosts.Node*2, stats.Gas)
+	}
+}
+
+func TestMemoryLimit(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{`"ab" + "cd"`, 18 + 18 + 20},
+		{"[1, 2]", 24 + 32},
+		{`{"a": [1]}`, 17 + 24 + 16 + 48 + 64},
+		{"var f = func(x) { x }; f(1)", 32 + 64 + 64 + 32},
+		// the scopes of the iterations are released, only the peak is reported
+		{`for c in "ab" { }`, 18 + 34 + 64 + 32},
+		{"var i = 0; while i < 3 { var j = i; i += 1; }", 32 + 64 + 32},
+		// the array returned by push_mut already exists, only its new items are counted
+		{"var a = []; push_mut(a, 1); push_mut(a, 2)", 24 + 32 + 32},
+		{"range(3)", 24 + 48},
+		{`repeat("ab", 2)`, 18 + 20},
+		{`pad_left("a", 3, "é")`, 17 + 18 + 21},
+		{"zip([1], [2])", 40 + 40 + 24 + 16 + 56},
+		{"9223372036854775807 + 1", 32 + 8},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+
+		result, stats := New(Options{}).EvalWithStats(context.Background(), program, object.NewEnvirement())
+		if isError(result) {
+			t.Fatalf("unexpected error for %q: %s", tt.input, result.Inspect())
+		}
+
+		if stats.Memory != tt.expected {
+			t.Errorf("wrong memory allocated by %q. expected=%d, got=%d", tt.input, tt.expected, stats.Memory)
+		}
+	}
+
+	exceeding := []string{
+		`var s = "a"; while true { s += s; }`,
+		"var a = [1]; while true { a = [a, a, a]; }",
+		`var h = {}; var i = 0; while true { h[i] = i; i += 1; }`,
+		`var f = func(n) { var s = "a" + "b"; return f(n + 1); }; f(0)`,
+		"var x = 3; while true { x = x * x; }",
+		// the builtins check the size of the strings before creating them
+		`repeat("ab", 1000000)`,
+		`pad_left("a", 2000000)`,
+		`replace(repeat("a", 1000), "a", repeat("b", 2000))`,
+	}
+
+	for _, input := range exceeding {
+		program := parser.New(lexer.New(input)).ParseProgram()
+		result, stats := New(Options{MaxMemory: 1 << 20}).EvalWithStats(context.Background(), program, object.NewEnvirement())
+
+		err, ok := result.(*object.Error)
+		if !ok || err.Message != "memory limit exceeded" || !errors.Is(err.Cause, ErrMemoryLimit) {
+			t.Errorf("expected the memory limit to be exceeded by %q, got=%s", input, result.Inspect())
+			continue
+		}
+
+		if stats.Memory > 1<<20 {
+			t.Errorf("expected the memory allocated by %q to stay under the limit, got=%d", input, stats.Memory)
+		}
+	}
+
+	// the scopes of the loops and the calls are released once they are left
+	releasing := []string{
+		"var i = 0; while i < 100000 { i += 1; }",
+		"for var i = 0; i < 100000; i += 1 { var j = i; }",
+		"var i = 0; while i < 100000 { match i { case 1 { i } case x { x } }; i += 1; }",
+		// the guard breaks out of the inner loop, leaving the arm before its body
+		"var i = 0; while i < 100000 { i += 1; while true { match i { case x if match x { default { break; } } { x } }; } }",
+		"var f = func(n) { var m = n; return m; }; var i = 0; while i < 100000 { f(i); i += 1; }",
+		"var f = func(n) { if n == 0 { return 0; } return f(n - 1); }; f(100000)",
+	}
+
+	for _, input := range releasing {
+		program := parser.New(lexer.New(input)).ParseProgram()
+		result, stats := New(Options{MaxMemory: 1 << 20}).EvalWithStats(context.Background(), program, object.NewEnvirement())
+
+		if isError(result) {
+			t.Errorf("unexpected error for %q: %s", input, result.Inspect())
+		}
+
+		if stats.Memory > 1<<12 {
+			t.Errorf("expected the memory of %q to be released, got a peak of %d", input, stats.Memory)
+		}
+	}
+
+	// the errors of the literals of the patterns stop the match instead of failing the arm,
+	// the limits leave room for the subject and the scope of the arm but not for the literal.
+	patterns := []struct {
+		input string
+		limit int64
+	}{
+		{`match "a" { case "the literal of the pattern is too long" { 1 } default { 2 } }`, 17 + 64 + 32},
+		{`match {} { case {"the key of the pattern is too long": x} { 1 } default { 2 } }`, 48 + 64 + 32},
+		{`match [{}] { case [{"the key of the pattern is too long": x}] { 1 } default { 2 } }`, 48 + 40 + 64 + 32},
+	}
+
+	for _, tt := range patterns {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		result := New(Options{MaxMemory: tt.limit}).Eval(program, object.NewEnvirement())
+
+		if err, ok := result.(*object.Error); !ok || !errors.Is(err.Cause, ErrMemoryLimit) {
+			t.Errorf("expected the memory limit to be exceeded by %q, got=%s", tt.input, result.Inspect())
+		}
+	}
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len("hello world")`, 11},
+		{`len("héllo 世界")`, 8},
+		{`len([1, [2, 3]])`, 2},
+		{`len({"a": 1})`, 1},
+		{`len(1)`, "argument to `len` not supported, got INTEGER"},
+		{`len(true)`, "argument to `len` not supported, got BOOLEAN"},
+		{`len("one", "two")`, "invalid arguments count in function call, expected 1 argumets, got 2 "},
 	}
-
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
-		testBooleanObject(t, evaluated, tt.expected)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)",
+					evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q",
+					expected, errObj.Message)
+			}
+		}
 	}
 }
 
-func TestBangOperatorExpression(t *testing.T) {
+func TestStringBuiltins(t *testing.T) {
 	tests := []struct {
 		input    string
-		expected bool
+		expected string
 	}{
-		{"!true;", false},
-		{"!false;", true},
-		{"!!false;", false},
-		{"!!!true;", false},
+		{`split("a,b,,c", ",")`, "[a, b, , c]"},
+		{`split("héllo", "")`, "[h, é, l, l, o]"},
+		{`join(["a", "b", "c"], ", ")`, "a, b, c"},
+		{`join([], "-")`, ""},
+		{`trim("  a b  ")`, "a b"},
+		{`trim_left("  a ") + "|"`, "a |"},
+		{`trim_right("  a ") + "|"`, "  a|"},
+		{`trim_prefix("prefix.go", "prefix.")`, "go"},
+		{`trim_suffix("main.go", ".go")`, "main"},
+		{`upper("héllo")`, "HÉLLO"},
+		{`lower("HÉLLO")`, "héllo"},
+		{`contains("nishimia", "shim")`, "true"},
+		{`starts_with("nishimia", "nis")`, "true"},
+		{`ends_with("nishimia", "nis")`, "false"},
+		{`index_of("héllo", "l")`, "2"},
+		{`index_of("héllo", "x")`, "-1"},
+		{`replace("a-b-c", "-", "+")`, "a+b+c"},
+		{`repeat("ab", 3)`, "ababab"},
+		{`repeat("ab", 0)`, ""},
+		{`pad_left("7", 3, "0")`, "007"},
+		{`pad_left("é", 3)`, "  é"},
+		{`pad_right("ab", 7, "xyz")`, "abxyzxy"},
+		{`pad_right("abc", 2)`, "abc"},
+		{`pad_left("a", 4, "éü")`, "éüéa"},
+		{`replace("ab", "", "-")`, "-a-b-"},
+		{`chars("世界")`, "[世, 界]"},
+		{`reverse("héllo")`, "olléh"},
+		{`split(1, ",")`, "ERROR: 1:1: argument 1 to `split` must be STRING, got INTEGER"},
+		{`join(["a", 1], ",")`, "ERROR: 1:1: item 1 of the array joined by `join` must be STRING, got INTEGER"},
+		{`join("a", ",")`, "ERROR: 1:1: argument 1 to `join` must be ARRAY, got STRING"},
+		{`repeat("a", -1)`, "ERROR: 1:1: negative count in `repeat`: -1"},
+		{`repeat("ab", 1099511627776)`, "ERROR: 1:1: string too long in `repeat`"},
+		{`pad_left("a")`, "ERROR: 1:1: invalid arguments count in function call, expected 2 or 3 argumets, got 1 "},
+		{`pad_left("a", 3, "")`, "ERROR: 1:1: empty pad in `pad_left`"},
+		{`replace("a", "b")`, "ERROR: 1:1: invalid arguments count in function call, expected 3 argumets, got 2 "},
 	}
 
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
-		testBooleanObject(t, evaluated, tt.expected)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
 	}
 }
 
-func TestIfElseExpressions(t *testing.T) {
+func TestCollectionBuiltins(t *testing.T) {
 	tests := []struct {
 		input    string
-		expected interface{}
+		expected string
 	}{
-		{"if (true) { +10 }", 10},
-		{"if (false) { 10 }", nil},
-		{"if (1 < 2) { 10 }", 10},
-		{"if (1 > 2) { 10 }", nil},
-		{"if (1 > 2) { 10 } else { 20 }", 20},
-		{"if (1 < 2) { 10 } else { 20 }", 10},
-		{"if (1 <= 2) { 10 } else { 20 }", 10},
-		{"if (1 >= 2) { 10 } else { 20 }", 20},
-		{"if (true && false) { 10 } else { 20 }", 20},
-		{"if (false || true) { 10 } else { 20 }", 10},
+		{`var a = [1, 2]; var b = push(a, 3); [a, b]`, "[[1, 2], [1, 2, 3]]"},
+		{`var a = [1, 2]; push_mut(a, 3); a`, "[1, 2, 3]"},
+		{`var a = [1, 2]; [pop(a), a]`, "[[1], [1, 2]]"},
+		{`var a = [1, 2]; [pop_mut(a), a, pop_mut([])]`, "[[1], [1], []]"},
+		{`var a = [1, 2]; var b = pop_mut(a); push_mut(b, 3); a`, "[1, 3]"},
+		{`[first([1, 2]), last([1, 2]), first([]), rest([1, 2, 3]), rest([])]`, "[1, 2, null, [2, 3], []]"},
+		{`[slice([1, 2, 3, 4], 1, 3), slice([1, 2, 3], 1), slice([1], 1)]`, "[[2, 3], [2, 3], []]"},
+		{`concat([1], [], [2, 3])`, "[1, 2, 3]"},
+		{`map([1, 2, 3], func(x) { return x * 2; })`, "[2, 4, 6]"},
+		{`map(["a", "b"], upper)`, "[A, B]"},
+		{`filter(range(10), func(x) { return x % 3 == 0; })`, "[0, 3, 6, 9]"},
+		{`reduce([1, 2, 3, 4], func(acc, x) { return acc + x; }, 10)`, "20"},
+		{`[find([1, 2, 3], func(x) { return x > 1; }), find([], func(x) { return true; })]`, "[2, null]"},
+		{`[any([1, 2], func(x) { return x > 1; }), all([1, 2], func(x) { return x > 1; }), all([], func(x) { return false; })]`, "[true, false, true]"},
+		{`var a = [3, 1, 2]; [sort(a), a]`, "[[1, 2, 3], [3, 1, 2]]"},
+		{`sort(["b", "c", "a"], func(x, y) { return x > y; })`, "[c, b, a]"},
+		{`sort([[2, "a"], [1, "b"], [2, "c"]], func(x, y) { return x[0] < y[0]; })`, "[[1, b], [2, a], [2, c]]"},
+		{`var a = [3, 1, 2]; sort_mut(a); a`, "[1, 2, 3]"},
+		{`var a = [1, 2, 3]; [reverse(a), a]`, "[[3, 2, 1], [1, 2, 3]]"},
+		{`var a = [1, 2, 3]; reverse_mut(a); a`, "[3, 2, 1]"},
+		{`zip([1, 2, 3], ["a", "b"])`, "[[1, a], [2, b]]"},
+		{`flatten([1, [2, [3]], []])`, "[1, 2, [3]]"},
+		{`unique([1, 2, 1, "a", 2, "a"])`, "[1, 2, a]"},
+		{`[range(3), range(2, 5), range(5, 0, -2), range(0)]`, "[[0, 1, 2], [2, 3, 4], [5, 3, 1], []]"},
+		{`var h = {"b": 2, "a": 1}; [keys(h), values(h), entries(h)]`, "[[a, b], [1, 2], [[a, 1], [b, 2]]]"},
+		{`var h = {"a": 1}; [has(h, "a"), has(h, "b")]`, "[true, false]"},
+		{`var h = {"a": 1, "b": 2}; [delete(h, "a"), h]`, "[{b: 2}, {a: 1, b: 2}]"},
+		{`var h = {"a": 1, "b": 2}; delete_mut(h, "a"); h`, "{b: 2}"},
+		{`var h = {"a": 1}; [merge(h, {"a": 2, "b": 3}), h]`, "[{a: 2, b: 3}, {a: 1}]"},
+		{`var h = {"a": 1}; merge_mut(h, {"b": 2}); h`, "{a: 1, b: 2}"},
+		{`map([1, 0], func(x) { return 1 / x; })`, "ERROR: 1:30: invalid operation: 1 / 0 (division by zero)"},
+		{`map([1], func(x, y) { return x; })`, "ERROR: 1:1: invalid arguments count in function call, expected 2 argumets, got 1 "},
+		{`filter([1], func(x) { return x; })`, "ERROR: 1:1: the function given to `filter` must return BOOLEAN, got INTEGER"},
+		{`sort([1, "a"])`, "ERROR: 1:1: invalid operation: a < 1 (mismatched types STRING and INTEGER)"},
+		{`map(1, upper)`, "ERROR: 1:1: argument 1 to `map` must be ARRAY, got INTEGER"},
+		{`keys([1])`, "ERROR: 1:1: argument 1 to `keys` must be HASH, got ARRAY"},
+		{`push({}, 1)`, "ERROR: 1:1: argument 1 to `push` must be ARRAY, got HASH"},
+		{`slice([1, 2], 2, 1)`, "ERROR: 1:1: slice bounds out of range [2:1] with length 2"},
+		{`unique([[1]])`, "ERROR: 1:1: unusable as hash key: ARRAY"},
+		{`range(0, 10, 0)`, "ERROR: 1:1: zero step in `range`"},
+		{`range(0, 1099511627776)`, "ERROR: 1:1: array too long in `range`"},
+		{`len(range(9223372036854775807, -9223372036854775807 - 1, -4611686018427387904))`, "4"},
+		{`var f = func(x) { return x + 1; }; map([1, 2], func(x) { return f(x); })`, "[2, 3]"},
+		{`var depth = func(n) { return map(range(n), depth); }; depth(3)`, "[[], [[]], [[], [[]]]]"},
+		{`var f = func(x) { return map([x], f); }; f(1)`, "ERROR: 1:26: maximum recursion depth exceeded"},
 	}
 
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
-		integer, ok := tt.expected.(int)
-		if ok {
-			testIntegerObject(t, evaluated, int64(integer))
-		} else {
-			testNullObject(t, evaluated)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
 		}
 	}
 }
 
-func TestReturnStatements(t *testing.T) {
+func TestRuntimeBuiltins(t *testing.T) {
+	builtins := DefaultBuiltins()
+	builtins.RegisterRuntime("twice", 2, "twice(fn, x) returns fn(fn(x))", func(rt object.Runtime, args ...object.Object) object.Object {
+		result := rt.Call(args[0], args[1])
+		if isError(result) {
+			return result
+		}
+
+		return rt.Call(args[0], result)
+	})
+
+	var calls int
+	err := builtins.Wrap("twice", func(fn object.BuiltinFunction) object.BuiltinFunction {
+		return func(args ...object.Object) object.Object {
+			calls++
+			return fn(args...)
+		}
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
 	tests := []struct {
 		input    string
-		expected any
+		expected string
 	}{
-		{"return 10;", 10},
-		{"return 10; 9;", 10},
-		{"return 10; 9;", 10},
-		{"return 2 * 5; 9;", 10},
-		{"9; return 2 * 5; 9;", 10},
-		{"9; return true; 9;", true},
-		{"9; return false; 9;", false},
-		{"9; return true && false; 9;", false},
-		{"9; return false || true; 9;", true},
-		{"9; return 10 <= 2; 9;", false},
-		{`if 10 > 1 {
-			if 10 > 1 {
-				if true {
-					return 10;
-				}
-			}
-			return 1;
-		}`, 10},
+		{`twice(func(x) { return x * 3; }, 2)`, "18"},
+		{`twice(upper, "a")`, "A"},
+		{`twice(1, 2)`, "ERROR: 1:1: invalid identifier in function call : 1 is not a valid identifier or function literal"},
 	}
 
+	evaluator := New(Options{Builtins: builtins})
+
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
 
-		switch v := tt.expected.(type) {
-		case int:
-			testIntegerObject(t, evaluated, int64(v))
-		case bool:
-			testBooleanObject(t, evaluated, v)
+		result := evaluator.Eval(program, object.NewEnvirement())
+		if result.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, result.Inspect())
 		}
 	}
-}
 
-func TestErrorHandling(t *testing.T) {
-	tests := []struct {
-		input           string
-		expectedMessage string
-	}{
-		{"5 + true;", "invalid operation: 5 + true (mismatched types INTEGER and BOOLEAN)"},
-		{"5 + true; 5;", "invalid operation: 5 + true (mismatched types INTEGER and BOOLEAN)"},
-		{"-true", "invalid operation: -true (operator \"-\" not defined on BOOLEAN)"},
-		{"+false", "invalid operation: +false (operator \"+\" not defined on BOOLEAN)"},
-		{"!1", "invalid operation: !1 (operator \"!\" not defined on INTEGER)"},
-		{"!(1*5)", "invalid operation: !5 (operator \"!\" not defined on INTEGER)"},
-		{"if(1+5){5}", "non-boolean value in if-statement , ( got=INTEGER, want=BOOLEAN )"},
-		{"if(1){5}", "non-boolean value in if-statement , ( got=INTEGER, want=BOOLEAN )"},
-		{"a;", "undefined identifier : a"},
-		{`"yassine" - "benaid"`, "invalid operation: yassine - benaid"},
+	if calls != 3 {
+		t.Errorf("wrong number of calls of twice. expected=3, got=%d", calls)
 	}
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		errObj, ok := evaluated.(*object.Error)
-		if !ok {
-			t.Errorf("no error object returned. got=%T(%+v)",
-				evaluated, evaluated)
-			continue
+
+	env := object.NewEnvirement()
+	Eval(parser.New(lexer.New("var double = func(x) { return x * 2; };")).ParseProgram(), env)
+	double, _ := env.Get("double")
+
+	// called from Go, the builtins call back the functions with Apply
+	mapper, _ := LookupBuiltin("map")
+	result := mapper.Fn(&object.Array{Items: []object.Object{&object.Integer{Value: 1}, &object.Integer{Value: 2}}}, double)
+	if result.Inspect() != "[2, 4]" {
+		t.Errorf("wrong result of map called from Go. got=%s", result.Inspect())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := Apply(ctx, mapper, &object.Array{Items: []object.Object{&object.Integer{Value: 1}}}, double); err == nil {
+		t.Errorf("expected the functions called back to stop once the context is done")
+	}
+}
+
+func TestBuiltinRegistry(t *testing.T) {
+	builtins := DefaultBuiltins().Restrict("len", "int", "float")
+
+	builtins.Register("sum", Variadic, "sum(n...) adds the integers", func(args ...object.Object) object.Object {
+		var sum int64
+		for _, arg := range args {
+			sum += arg.(*object.Integer).Value
 		}
-		if errObj.Message != tt.expectedMessage {
-			t.Errorf("wrong error message. expected=%q, got=%q",
-				tt.expectedMessage, errObj.Message)
+
+		return &object.Integer{Value: sum}
+	})
+
+	var calls int
+	err := builtins.Wrap("len", func(fn object.BuiltinFunction) object.BuiltinFunction {
+		return func(args ...object.Object) object.Object {
+			calls++
+			return fn(args...)
 		}
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
 	}
-}
 
-func TestVarStatements(t *testing.T) {
+	if err := builtins.Wrap("undefined", nil); err == nil || err.Error() != "undefined builtin undefined" {
+		t.Errorf("expected wrapping an undefined builtin to fail, got=%v", err)
+	}
+
+	builtins.Remove("float")
+
 	tests := []struct {
 		input    string
-		expected int64
+		expected string
 	}{
-		{"var a = 5; a;", 5},
-		{"var a = 5 * 5; a;", 25},
-		{"var a = 5; var b = a; b;", 5},
-		{"var a = 5; var b = a; var c = a + b + 5; c;", 15},
+		{"sum()", "0"},
+		{"sum(1, 2, 3)", "6"},
+		{`len("abc") + len("de")`, "5"},
+		{`len("a", "b")`, "ERROR: 1:1: invalid arguments count in function call, expected 1 argumets, got 2 "},
+		{"float(1)", "ERROR: 1:1: undefined identifier : float"},
+		{"var len = 1; len", "1"},
 	}
 
+	evaluator := New(Options{Builtins: builtins})
+
 	for _, tt := range tests {
-		testIntegerObject(t, testEval(tt.input), tt.expected)
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+
+		result := evaluator.Eval(program, object.NewEnvirement())
+		if result.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, result.Inspect())
+		}
 	}
 
-	testNullObject(t, testEval("var num = 10;"))
+	// the wrapper doesn't see the calls with the wrong number of arguments
+	if calls != 2 {
+		t.Errorf("wrong number of calls of len. expected=2, got=%d", calls)
+	}
+
+	if info, ok := builtins.Info("len"); !ok || info.Arity != 1 || info.Doc == "" {
+		t.Errorf("wrong info of len. got=%+v", info)
+	}
+
+	if names := strings.Join(builtins.Names(), ","); names != "int,len,sum" {
+		t.Errorf("wrong names. got=%s", names)
+	}
+
+	restricted := builtins.Restrict("sum", "undefined")
+	restricted.Register("one", 0, "one() returns 1", func(args ...object.Object) object.Object {
+		return &object.Integer{Value: 1}
+	})
+
+	if names := strings.Join(restricted.Names(), ","); names != "one,sum" {
+		t.Errorf("wrong names of the restricted registry. got=%s", names)
+	}
+
+	// the registries are independent
+	if _, ok := builtins.Lookup("one"); ok {
+		t.Errorf("expected one not to be registered in the original registry")
+	}
+
+	if _, ok := DefaultBuiltins().Lookup("sum"); ok {
+		t.Errorf("expected sum not to be a default builtin")
+	}
+
+	if _, ok := LookupBuiltin("float"); !ok {
+		t.Errorf("expected float to remain a builtin of the language")
+	}
 }
 
-func TestFunction(t *testing.T) {
-	input := "func(x) { x + 2; };"
+func TestApply(t *testing.T) {
+	env := object.NewEnvirement()
+	input := `
+var less = func(a, b) { return a < b; };
+var div = func(n) { return 10 / n; };
+var loop = func(n) { return loop(n + 1); };
+`
 
-	evaluated := testEval(input)
+	if result := Eval(parser.New(lexer.New(input)).ParseProgram(), env); isError(result) {
+		t.Fatalf("unexpected error: %s", result.Inspect())
+	}
 
-	fn, ok := evaluated.(*object.Function)
-	if !ok {
-		t.Fatalf("object is not Function. got=%T (%+v)", evaluated, evaluated)
+	less, _ := env.Get("less")
+	div, _ := env.Get("div")
+	loop, _ := env.Get("loop")
+	ctx := context.Background()
+
+	// the functions of the programs can be used as comparators
+	items := []int64{3, 1, 2}
+	sort.Slice(items, func(i, j int) bool {
+		result, err := Apply(ctx, less, &object.Integer{Value: items[i]}, &object.Integer{Value: items[j]})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		return result == TRUE
+	})
+
+	if items[0] != 1 || items[1] != 2 || items[2] != 3 {
+		t.Errorf("wrong order. got=%v", items)
 	}
 
-	if len(fn.Params) != 1 {
-		t.Fatalf("function has wrong params. Params=%+v",
-			fn.Params)
+	length, _ := LookupBuiltin("len")
+	if result, err := Apply(ctx, length, &object.String{Value: "abc"}); err != nil || result.Inspect() != "3" {
+		t.Errorf("wrong result of len. got=%v %v", result, err)
 	}
 
-	if fn.Params[0].String() != "x" {
-		t.Fatalf("param is not 'x'. got=%q", fn.Params[0])
+	_, err := Apply(ctx, div, &object.Integer{Value: 0})
+
+	var errObj *object.Error
+	if !errors.As(err, &errObj) {
+		t.Fatalf("expected an error object, got=%v", err)
 	}
 
-	expectedBody := "{(x + 2)}"
+	if err.Error() != "3:28: invalid operation: 10 / 0 (division by zero)" || errObj.StackTrace() != "at div(0)" {
+		t.Errorf("wrong error. got=%q with the stack %q", err.Error(), errObj.StackTrace())
+	}
 
-	if fn.Body.String() != expectedBody {
-		t.Fatalf("body is not %q. got=%q", expectedBody, fn.Body.String())
+	errorTests := []struct {
+		fn       object.Object
+		args     []object.Object
+		expected string
+	}{
+		{div, nil, "invalid arguments count in function call, expected 1 argumets, got 0 "},
+		{&object.Integer{Value: 1}, nil, "invalid identifier in function call : 1 is not a valid identifier or function literal"},
+	}
+
+	for _, tt := range errorTests {
+		if _, err := Apply(ctx, tt.fn, tt.args...); err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%v", tt.expected, err)
+		}
+	}
+
+	// the calls are limited like the evaluations
+	if _, err := New(Options{Budget: 1000}).Apply(ctx, loop, &object.Integer{Value: 0}); !errors.Is(err, ErrBudgetExhausted) {
+		t.Errorf("expected the budget to be exhausted, got=%v", err)
 	}
 
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	if _, err := Apply(cancelled, loop, &object.Integer{Value: 0}); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the call to be cancelled, got=%v", err)
+	}
 }
 
-func TestFunctionApplication(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected int64
-	}{
-		{"func(x) { return x; }(5);", 5},
-		{"var identity = func(x) { return x; }; identity(5);", 5},
-		{"var identity = func(x) { return x; }; identity(5);", 5},
-		{"var double = func(x) { return x * 2; }; double(5);", 10},
-		{"var add = func(x, y) { return x + y; }; add(5, 5);", 10},
-		{"var add = func(x, y) { return x + y; }; add(5 + 5, add(5, 5));", 20},
-		{"var num = 4;var add = func(num) { return num + 2; }; add(num);", 6},
-		{"var num = 4;var add = func(num) { return num + 2; }; add(num+5);", 11},
-		{"var num = 4;var add = func(num) { return num + 2; }; add(num*2);", 10},
+// request is a host object with the attributes of a hash, the method add and the index operator
+type request struct {
+	attrs map[string]object.Object
+}
+
+func (r *request) Type() object.ObjectType { return "REQUEST" }
+func (r *request) Inspect() string         { return "request" }
+
+func (r *request) GetAttr(name string) (object.Object, error) {
+	if value, ok := r.attrs[name]; ok {
+		return value, nil
 	}
 
-	for _, tt := range tests {
-		testIntegerObject(t, testEval(tt.input), tt.expected)
+	return nil, fmt.Errorf("request has no attribute %s", name)
+}
+
+func (r *request) SetAttr(name string, value object.Object) error {
+	if name == "method" {
+		return errors.New("the method of the request is read only")
 	}
+
+	r.attrs[name] = value
+	return nil
 }
 
-func TestClosures(t *testing.T) {
-	input := `
-	var i = 0;
-	var newAdder = func(x) {
-		var it = i;
-		return func(y) { return x + y + +it; };
-	};
-	var addTwo = newAdder(2);
-	addTwo(2);
-	`
-	testIntegerObject(t, testEval(input), 4)
+func (r *request) CallMethod(name string, args ...object.Object) (object.Object, error) {
+	switch name {
+	case "add":
+		var sum int64
+		for _, arg := range args {
+			sum += arg.(*object.Integer).Value
+		}
+
+		return &object.Integer{Value: sum}, nil
+	case "self":
+		return r, nil
+	case "nothing":
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("request has no method %s", name)
 }
 
-func TestBuiltinFunctions(t *testing.T) {
+func (r *request) Index(index object.Object) (object.Object, error) {
+	return r.GetAttr(index.Inspect())
+}
+
+func TestHostObjects(t *testing.T) {
 	tests := []struct {
 		input    string
-		expected interface{}
+		expected string
 	}{
-		{`len("")`, 0},
-		{`len("four")`, 4},
-		{`len("hello world")`, 11},
-		{`len(1)`, "argument to `len` not supported, got INTEGER"},
-		{`len("one", "two")`, "invalid arguments count in function call, expected 1 argumets, got 2 "},
+		{"req.method", "GET"},
+		{"req.count", "1"},
+		{"req.self().self().method", "GET"},
+		{`req["method"]`, "GET"},
+		{"req.add(1, 2, req.count)", "4"},
+		{"req.nothing()", "null"},
+		{`req.path = "/home"; req.path`, "/home"},
+		{"req.count += 2; req.count * 10", "30"},
+		{"var f = func(r) { return r.add(1, 1); }; f(req)", "2"},
+		{"var m = req.add; m", "ERROR: 1:9: request has no attribute add"},
+		{"req.missing", "ERROR: 1:1: request has no attribute missing"},
+		{"req.missing()", "ERROR: 1:1: request has no method missing"},
+		{`req.method = "POST"`, "ERROR: 1:1: the method of the request is read only"},
+		{"var n = 1; n.name", "ERROR: 1:12: cannot read attribute name of type INTEGER"},
+		{"var n = 1; n.name()", "ERROR: 1:12: cannot call method name of type INTEGER"},
+		{"var n = 1; n.name = 2", "ERROR: 1:12: cannot assign to attribute name of type INTEGER"},
 	}
+
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		switch expected := tt.expected.(type) {
-		case int:
-			testIntegerObject(t, evaluated, int64(expected))
-		case string:
-			errObj, ok := evaluated.(*object.Error)
-			if !ok {
-				t.Errorf("object is not Error. got=%T (%+v)",
-					evaluated, evaluated)
-				continue
-			}
-			if errObj.Message != expected {
-				t.Errorf("wrong error message. expected=%q, got=%q",
-					expected, errObj.Message)
-			}
+		env := object.NewEnvirement()
+		env.Set("req", &request{attrs: map[string]object.Object{
+			"method": &object.String{Value: "GET"},
+			"count":  &object.Integer{Value: 1},
+		}})
+
+		result := Eval(parser.New(lexer.New(tt.input)).ParseProgram(), env)
+		if result.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, result.Inspect())
 		}
 	}
 }
@@ -336,6 +1687,9 @@ func TestArrayIndexExpression(t *testing.T) {
 		{`var arr = [1,2,3];arr[2]`, 3},
 		{`var arr = func(){ return [1,2,3];};arr()[2]`, 3},
 		{`[1,2,3][5]`, "index out of range [5] with length 3"},
+		{`[1,2,3][-1]`, "index out of range [-1] with length 3"},
+		{`[1,2,3][99999999999999999999]`, "index out of range [99999999999999999999] with length 3"},
+		{`[1, a, 3]`, "undefined identifier : a"},
 	}
 
 	for _, tt := range tests {
@@ -464,6 +1818,21 @@ func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	return true
 }
 
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("float object is not Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("float object has wrong value. got=%v, want=%v",
+			result.Value, expected)
+		return false
+	}
+
+	return true
+}
+
 func testStringObject(t *testing.T, obj object.Object, expected string) bool {
 	result, ok := obj.(*object.String)
 	if !ok {
@@ -0,0 +1,167 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/yassinebenaid/nishimia/ast"
+	"github.com/yassinebenaid/nishimia/object"
+	"github.com/yassinebenaid/nishimia/token"
+)
+
+// Options configures the behaviour of an Evaluator
+type Options struct {
+	// TruthyOr makes "a || b" evaluate to a if it is truthy and to b otherwise, whatever their types are,
+	// it allows providing default values like `name || "anonymous"`. See isTruthy for the falsy values.
+	TruthyOr bool
+
+	// MaxCallDepth is the maximum number of nested function calls, deeper calls fail with an error
+	// instead of overflowing the Go stack. DefaultMaxCallDepth is used when it is zero. The tail
+	// calls replace the call they are made from so they don't count.
+	MaxCallDepth int
+
+	// Budget is the gas an evaluation can consume, the evaluation fails with a "budget exhausted" error
+	// once it is consumed. The operations cost the gas set by Costs, the evaluation is unlimited when it is zero.
+	// Only the evaluator meters the gas, the virtual machine ignores the budget.
+	Budget int64
+
+	// Costs are the costs of the operations in gas, DefaultCosts are used when it is nil
+	Costs *Costs
+
+	// MaxMemory is the approximate number of bytes an evaluation can use for its strings, arrays, hashes,
+	// functions and scopes, it fails with a "memory limit exceeded" error past it. The scopes are released
+	// once they are left, the values are not. The evaluation is unlimited when it is zero. Like the budget,
+	// it is ignored by the virtual machine.
+	MaxMemory int64
+
+	// Builtins is the registry of the builtin functions the programs can call, the registry of the builtins
+	// of the language is used when it is nil. The registry can be modified between and during the evaluations.
+	Builtins *Builtins
+}
+
+// DefaultMaxCallDepth is the maximum call depth when the options don't set one
+const DefaultMaxCallDepth = 10000
+
+// CallDepthLimit returns the maximum number of nested calls, MaxCallDepth or DefaultMaxCallDepth if it is not set
+func (o Options) CallDepthLimit() int {
+	if o.MaxCallDepth > 0 {
+		return o.MaxCallDepth
+	}
+
+	return DefaultMaxCallDepth
+}
+
+// Evaluator evaluates nishimia programs using a set of options,
+// it holds no evaluation state so it is safe for concurrent use.
+type Evaluator struct {
+	options Options
+}
+
+func New(options Options) *Evaluator {
+	return &Evaluator{options: options}
+}
+
+// state holds everything that is specific to a single evaluation
+type state struct {
+	*Evaluator
+	ctx    context.Context
+	costs  Costs
+	gas    int64 // the gas consumed so far
+	memory int64 // the bytes in use, the scopes that were left are released
+	peak   int64 // the highest memory usage so far
+
+	calls []call // the calls of the functions being evaluated, the innermost last
+}
+
+// call is a frame of the call stack, its arguments are only summarized when an error needs them
+type call struct {
+	fn   *object.Function
+	pos  token.Position
+	args []object.Object
+	tail bool // whether the call replaced the call it was made from
+}
+
+var defaultEvaluator = New(Options{})
+
+// Eval evaluates the node in the given environment using the default options
+func Eval(node ast.Node, env *object.Environment) object.Object {
+	return defaultEvaluator.Eval(node, env)
+}
+
+// EvalContext evaluates the node in the given environment using the default options, it stops once the context is done
+func EvalContext(ctx context.Context, node ast.Node, env *object.Environment) object.Object {
+	return defaultEvaluator.EvalContext(ctx, node, env)
+}
+
+// Eval evaluates the node in the given environment, any unexpected panic
+// of the interpreter is turned into an error instead of crashing the host process.
+func (e *Evaluator) Eval(node ast.Node, env *object.Environment) object.Object {
+	return e.EvalContext(context.Background(), node, env)
+}
+
+// EvalContext evaluates the node like Eval, the evaluation stops with a cancellation error once
+// the context is done. The context is checked at every loop iteration and function call.
+func (e *Evaluator) EvalContext(ctx context.Context, node ast.Node, env *object.Environment) object.Object {
+	result, _ := e.EvalWithStats(ctx, node, env)
+	return result
+}
+
+// Stats reports the resources consumed by an evaluation
+type Stats struct {
+	Gas int64 // the gas consumed, it is at most the budget of the options
+
+	// Memory is the approximate peak memory usage in bytes, at most the memory limit of the options.
+	// The scopes are released once they are left, the values are never considered released.
+	Memory int64
+}
+
+// EvalWithStats evaluates the node like EvalContext, it also reports the resources the evaluation consumed.
+func (e *Evaluator) EvalWithStats(ctx context.Context, node ast.Node, env *object.Environment) (result object.Object, stats Stats) {
+	s := &state{Evaluator: e, ctx: ctx, costs: e.options.costs()}
+
+	defer func() {
+		if r := recover(); r != nil {
+			result = internalError(r)
+		}
+
+		// the consumption is reported even if the interpreter panicked
+		stats = Stats{Gas: s.gas, Memory: s.peak}
+	}()
+
+	return s.eval(node, env), stats
+}
+
+// Apply calls the function with the arguments using the default options, see Evaluator.Apply
+func Apply(ctx context.Context, fn object.Object, args ...object.Object) (object.Object, error) {
+	return defaultEvaluator.Apply(ctx, fn, args...)
+}
+
+// Apply calls a function of a program or a builtin function with the arguments, it allows Go code to call
+// the callbacks the programs hand over. The call is an evaluation of its own: it has its own budget and memory
+// limit, and it stops once the context is done. The error it fails with is returned as an *object.Error
+// holding the call stack, the call made from Go is its outermost frame.
+func (e *Evaluator) Apply(ctx context.Context, fn object.Object, args ...object.Object) (result object.Object, err error) {
+	s := &state{Evaluator: e, ctx: ctx, costs: e.options.costs()}
+
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, internalError(r)
+		}
+	}()
+
+	result = s.apply(nil, fn, args)
+	if err, ok := result.(*object.Error); ok {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// internalError turns an unexpected panic of the interpreter into an error instead of crashing the host process
+func internalError(r any) *object.Error {
+	return &object.Error{
+		Message: fmt.Sprintf("internal error: %v", r),
+		GoStack: string(debug.Stack()),
+	}
+}
@@ -0,0 +1,158 @@
+package eval
+
+import (
+	"context"
+	"errors"
+
+	"github.com/yassinebenaid/nishimia/object"
+)
+
+// Costs are the costs in gas of the operations of the evaluations, the same program
+// always consumes the same gas so it can be billed whatever the load of the machine.
+type Costs struct {
+	Node       int64 // the evaluation of any node of the program
+	Allocation int64 // the creation of an array, a hash, a function or a scope, and of every item of the arrays and hashes
+	Builtin    int64 // a call of a builtin function
+}
+
+// DefaultCosts are the costs used when the options don't set any
+var DefaultCosts = Costs{Node: 1, Allocation: 2, Builtin: 5}
+
+func (o Options) costs() Costs {
+	if o.Costs != nil {
+		return *o.Costs
+	}
+
+	return DefaultCosts
+}
+
+// ErrBudgetExhausted is the cause of the errors of the evaluations that consumed all their budget
+var ErrBudgetExhausted = errors.New("budget exhausted")
+
+// consume adds the cost to the consumed gas, it fails once the budget is exhausted,
+// the consumed gas never goes over the budget.
+func (s *state) consume(cost int64) *object.Error {
+	if budget := s.options.Budget; budget > 0 && s.gas+cost > budget {
+		s.gas = budget
+		return &object.Error{Message: ErrBudgetExhausted.Error(), Cause: ErrBudgetExhausted}
+	}
+
+	s.gas += cost
+
+	return nil
+}
+
+// The approximate sizes in bytes of the values and of their items, they are
+// used to account for the memory of the evaluations.
+const (
+	stringSize   = 16
+	arraySize    = 24
+	itemSize     = 16
+	hashSize     = 48
+	pairSize     = 64
+	bigIntSize   = 32
+	wordSize     = 8
+	functionSize = 64
+	scopeSize    = 64
+	variableSize = 32
+)
+
+// ErrMemoryLimit is the cause of the errors of the evaluations that allocated more memory than their limit
+var ErrMemoryLimit = errors.New("memory limit exceeded")
+
+// reserve accounts for the allocation of the given number of bytes, it fails once the memory limit is exceeded.
+// Only the scopes are released, it is not known when the values are collected by the Go runtime.
+func (s *state) reserve(bytes int64) *object.Error {
+	if limit := s.options.MaxMemory; limit > 0 && s.memory+bytes > limit {
+		return &object.Error{Message: ErrMemoryLimit.Error(), Cause: ErrMemoryLimit}
+	}
+
+	s.memory += bytes
+	s.peak = max(s.peak, s.memory)
+
+	return nil
+}
+
+// allocate consumes the gas and the memory of the allocation of a value with the given number of items
+func (s *state) allocate(items int, bytes int64) *object.Error {
+	if err := s.consume(s.costs.Allocation * int64(1+items)); err != nil {
+		return err
+	}
+
+	return s.reserve(bytes)
+}
+
+// measure accounts for the memory of a value created by an operation or a builtin function, only the strings,
+// the big integers, the arrays and the hashes are counted. The value is not counted when it is one of the
+// operands, which already exist, like the array returned by push_mut. It returns the value, or an error.
+func (s *state) measure(obj object.Object, operands ...object.Object) object.Object {
+	var bytes int64
+
+	switch v := obj.(type) {
+	case *object.String:
+		bytes = stringSize + int64(len(v.Value))
+	case *object.BigInt:
+		bytes = bigIntSize + wordSize*int64(len(v.Value.Bits()))
+	case *object.Array:
+		bytes = arraySize + itemSize*int64(len(v.Items))
+	case *object.Hash:
+		bytes = hashSize + pairSize*int64(len(v.Items))
+	default:
+		return obj
+	}
+
+	// the measured values are pointers, comparing them with the operands can't panic
+	for _, operand := range operands {
+		if obj == operand {
+			return obj
+		}
+	}
+
+	if err := s.reserve(bytes); err != nil {
+		return err
+	}
+
+	return obj
+}
+
+// enclose creates a scope enclosed by the environment, for the given number of variables. The scope is
+// accounted for until it is left, the variables declared in it are accounted for when they are declared.
+func (s *state) enclose(env *object.Environment, variables int) (*object.Environment, *object.Error) {
+	if err := s.allocate(0, scopeSize+variableSize*int64(variables)); err != nil {
+		return nil, err
+	}
+
+	return object.NewEnclosedEnvironment(env), nil
+}
+
+// leave releases the memory of a scope created by enclose and of its variables, once its evaluation is over.
+// The scopes captured by the functions outlive their evaluation, they are released all the same.
+func (s *state) leave(scope *object.Environment) {
+	s.memory -= scopeSize + variableSize*int64(len(scope.Store))
+}
+
+// infix applies the operator like evalInfixExpression, the memory of its result is accounted for
+func (s *state) infix(operator string, left object.Object, right object.Object) object.Object {
+	return s.measure(evalInfixExpression(operator, left, right), left, right)
+}
+
+// interrupted returns a cancellation error if the context of the evaluation is done
+func (s *state) interrupted() *object.Error {
+	select {
+	case <-s.ctx.Done():
+		return NewCancellationError(s.ctx.Err())
+	default:
+		return nil
+	}
+}
+
+// NewCancellationError returns the error stopping an execution because its context is done
+func NewCancellationError(cause error) *object.Error {
+	return &object.Error{Message: "execution cancelled: " + cause.Error(), Cause: cause}
+}
+
+// IsCancellation reports whether the object is an error caused by a cancelled or expired context
+func IsCancellation(obj object.Object) bool {
+	err, ok := obj.(*object.Error)
+	return ok && (errors.Is(err.Cause, context.Canceled) || errors.Is(err.Cause, context.DeadlineExceeded))
+}
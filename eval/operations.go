@@ -0,0 +1,135 @@
+package eval
+
+import "github.com/yassinebenaid/nishimia/object"
+
+// The functions below expose the semantics of the language operations, they are used by
+// the other engines executing nishimia programs so they behave exactly like the evaluator.
+// the errors they return have no position, it is up to the caller to locate them.
+
+// InfixOperation applies a binary operator like + or <= to the operands
+func InfixOperation(operator string, left object.Object, right object.Object) object.Object {
+	return evalInfixExpression(operator, left, right)
+}
+
+// PrefixOperation applies one of the unary operators !, - and + to the operand
+func PrefixOperation(operator string, right object.Object) object.Object {
+	return evalPrefixExpression(operator, right)
+}
+
+// CheckIndexable returns an error if the value doesn't support the index operator,
+// the evaluator checks it before evaluating the index.
+func CheckIndexable(left object.Object) *object.Error {
+	return checkIndexable(left)
+}
+
+// IndexOperation reads the item of an array or a hash at the given index
+func IndexOperation(left object.Object, index object.Object) object.Object {
+	return evalIndex(left, index)
+}
+
+// SetIndexOperation stores the value at the index of an array or a hash, it returns the value.
+func SetIndexOperation(left object.Object, index object.Object, value object.Object) object.Object {
+	return setIndex(left, index, value)
+}
+
+// GetAttrOperation reads the attribute of a host object
+func GetAttrOperation(obj object.Object, name string) object.Object {
+	return getAttr(obj, name)
+}
+
+// SetAttrOperation assigns the attribute of a host object, it returns the value
+func SetAttrOperation(obj object.Object, name string, value object.Object) object.Object {
+	return setAttr(obj, name, value)
+}
+
+// MethodOperation returns a builtin function calling the method of a host object
+func MethodOperation(obj object.Object, name string) object.Object {
+	return method(obj, name)
+}
+
+// HashPut adds the pair to the hash, it fails if the key is not hashable
+func HashPut(hash *object.Hash, key object.Object, value object.Object) *object.Error {
+	return hashPut(hash, key, value)
+}
+
+// Iterate returns the keys and the values visited by a for-in loop over the collection,
+// oneVariable is set for the loops of the form "for x in collection".
+func Iterate(collection object.Object, oneVariable bool) (keys, values []object.Object, err *object.Error) {
+	return iterate(collection, oneVariable)
+}
+
+// LiteralMatches reports whether the value matches a literal pattern of a match expression
+func LiteralMatches(literal object.Object, value object.Object) bool {
+	return literalMatches(literal, value)
+}
+
+// IsTruthy reports whether the value is considered true by the TruthyOr option
+func IsTruthy(obj object.Object) bool {
+	return isTruthy(obj)
+}
+
+// LookupBuiltin returns the builtin function of the language with the given name
+func LookupBuiltin(name string) (*object.Builtin, bool) {
+	return defaultBuiltins.Lookup(name)
+}
+
+// The functions below build the errors raised by both engines, the engines share them so their messages can't drift.
+
+// UndefinedIdentifierError reports an identifier that is neither a variable nor a builtin
+func UndefinedIdentifierError(name string) *object.Error {
+	return newError("undefined identifier : %s", name)
+}
+
+// RedefinitionError reports a variable declared twice in the same scope
+func RedefinitionError(name string) *object.Error {
+	return newError("variable %s already defined", name)
+}
+
+// UndefinedAssignmentError reports an assignment to a variable that is not declared
+func UndefinedAssignmentError(name string) *object.Error {
+	return newError("cannot assign to undefined variable %s", name)
+}
+
+// ConstantAssignmentError reports an assignment to a constant
+func ConstantAssignmentError(name string) *object.Error {
+	return newError("cannot assign to constant %s", name)
+}
+
+// NoMatchError reports a match expression without an arm for its subject
+func NoMatchError(subject object.Object) *object.Error {
+	return newError("no match arm for value %s", subject.Inspect())
+}
+
+// NotCallableError reports a call of a value that is not a function
+func NotCallableError(fn object.Object) *object.Error {
+	return newError("invalid identifier in function call : %s is not a valid identifier or function literal", fn.Inspect())
+}
+
+// ArgumentsCountError reports a call with a wrong number of arguments
+func ArgumentsCountError(expected int, got int) *object.Error {
+	return newError("invalid arguments count in function call, expected %d argumets, got %d ", expected, got)
+}
+
+// RecursionDepthError reports a call nested deeper than Options.CallDepthLimit
+func RecursionDepthError() *object.Error {
+	return newError("maximum recursion depth exceeded")
+}
+
+// LogicalOperandError reports a left operand of && or || that is not a boolean, the right operand is not evaluated
+func LogicalOperandError(operator string, left object.Object) *object.Error {
+	return newError("invalid operation: operator %s not defined on %s (%s)", operator, left.Inspect(), left.Type())
+}
+
+// Condition is the construct a condition belongs to, it is named in the errors of the non-boolean conditions
+type Condition string
+
+const (
+	IfCondition   Condition = "if-statement"
+	LoopCondition Condition = "loop condition"
+	MatchGuard    Condition = "match guard"
+)
+
+// ConditionError reports a condition that is not a boolean
+func ConditionError(condition Condition, value object.Object) *object.Error {
+	return newError("non-boolean value in %s , ( got=%s, want=BOOLEAN )", condition, value.Type())
+}
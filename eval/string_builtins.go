@@ -0,0 +1,293 @@
+package eval
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/yassinebenaid/nishimia/object"
+)
+
+// registerStringBuiltins adds the builtins working on strings, they count and index the strings in runes
+func registerStringBuiltins(b *Builtins) {
+	b.Register("split", 2, "split(s, sep) splits s around each sep, an empty sep splits s in characters", func(args ...object.Object) object.Object {
+		return withStrings("split", args, func(s ...string) object.Object {
+			return stringArray(strings.Split(s[0], s[1]))
+		})
+	})
+
+	b.Register("join", 2, "join(items, sep) concatenates the strings of the array items, separated by sep", func(args ...object.Object) object.Object {
+		arr, ok := args[0].(*object.Array)
+		if !ok {
+			return argumentError("join", 1, object.ARRAY_OBJ, args[0])
+		}
+
+		sep, ok := args[1].(*object.String)
+		if !ok {
+			return argumentError("join", 2, object.STRING_OBJ, args[1])
+		}
+
+		items := make([]string, len(arr.Items))
+		for i, item := range arr.Items {
+			str, ok := item.(*object.String)
+			if !ok {
+				return newError("item %d of the array joined by `join` must be STRING, got %s", i, item.Type())
+			}
+
+			items[i] = str.Value
+		}
+
+		return &object.String{Value: strings.Join(items, sep.Value)}
+	})
+
+	b.Register("trim", 1, "trim(s) removes the leading and trailing white space of s", func(args ...object.Object) object.Object {
+		return withStrings("trim", args, func(s ...string) object.Object {
+			return &object.String{Value: strings.TrimSpace(s[0])}
+		})
+	})
+
+	b.Register("trim_left", 1, "trim_left(s) removes the leading white space of s", func(args ...object.Object) object.Object {
+		return withStrings("trim_left", args, func(s ...string) object.Object {
+			return &object.String{Value: strings.TrimLeftFunc(s[0], unicode.IsSpace)}
+		})
+	})
+
+	b.Register("trim_right", 1, "trim_right(s) removes the trailing white space of s", func(args ...object.Object) object.Object {
+		return withStrings("trim_right", args, func(s ...string) object.Object {
+			return &object.String{Value: strings.TrimRightFunc(s[0], unicode.IsSpace)}
+		})
+	})
+
+	b.Register("trim_prefix", 2, "trim_prefix(s, prefix) removes prefix from the start of s if it is there", func(args ...object.Object) object.Object {
+		return withStrings("trim_prefix", args, func(s ...string) object.Object {
+			return &object.String{Value: strings.TrimPrefix(s[0], s[1])}
+		})
+	})
+
+	b.Register("trim_suffix", 2, "trim_suffix(s, suffix) removes suffix from the end of s if it is there", func(args ...object.Object) object.Object {
+		return withStrings("trim_suffix", args, func(s ...string) object.Object {
+			return &object.String{Value: strings.TrimSuffix(s[0], s[1])}
+		})
+	})
+
+	b.Register("upper", 1, "upper(s) returns s with all its letters in upper case", func(args ...object.Object) object.Object {
+		return withStrings("upper", args, func(s ...string) object.Object {
+			return &object.String{Value: strings.ToUpper(s[0])}
+		})
+	})
+
+	b.Register("lower", 1, "lower(s) returns s with all its letters in lower case", func(args ...object.Object) object.Object {
+		return withStrings("lower", args, func(s ...string) object.Object {
+			return &object.String{Value: strings.ToLower(s[0])}
+		})
+	})
+
+	b.Register("contains", 2, "contains(s, sub) reports whether sub is within s", func(args ...object.Object) object.Object {
+		return withStrings("contains", args, func(s ...string) object.Object {
+			return nativeBooleanObject(strings.Contains(s[0], s[1]))
+		})
+	})
+
+	b.Register("starts_with", 2, "starts_with(s, prefix) reports whether s begins with prefix", func(args ...object.Object) object.Object {
+		return withStrings("starts_with", args, func(s ...string) object.Object {
+			return nativeBooleanObject(strings.HasPrefix(s[0], s[1]))
+		})
+	})
+
+	b.Register("ends_with", 2, "ends_with(s, suffix) reports whether s ends with suffix", func(args ...object.Object) object.Object {
+		return withStrings("ends_with", args, func(s ...string) object.Object {
+			return nativeBooleanObject(strings.HasSuffix(s[0], s[1]))
+		})
+	})
+
+	b.Register("index_of", 2, "index_of(s, sub) returns the index in characters of the first sub in s, or -1", func(args ...object.Object) object.Object {
+		return withStrings("index_of", args, func(s ...string) object.Object {
+			i := strings.Index(s[0], s[1])
+			if i < 0 {
+				return &object.Integer{Value: -1}
+			}
+
+			return &object.Integer{Value: int64(utf8.RuneCountInString(s[0][:i]))}
+		})
+	})
+
+	b.RegisterRuntime("replace", 3, "replace(s, old, new) replaces all the old in s by new", func(rt object.Runtime, args ...object.Object) object.Object {
+		return withStrings("replace", args, func(s ...string) object.Object {
+			// an empty old matches around every character, Count counts these matches as well
+			length := int64(len(s[0])) + int64(strings.Count(s[0], s[1]))*(int64(len(s[2]))-int64(len(s[1])))
+			if length > maxStringLength {
+				return newError("string too long in `replace`")
+			}
+
+			if err := allocateString(rt, length); err != nil {
+				return err
+			}
+
+			return &object.String{Value: strings.ReplaceAll(s[0], s[1], s[2])}
+		})
+	})
+
+	b.RegisterRuntime("repeat", 2, "repeat(s, n) returns n copies of s", func(rt object.Runtime, args ...object.Object) object.Object {
+		str, ok := args[0].(*object.String)
+		if !ok {
+			return argumentError("repeat", 1, object.STRING_OBJ, args[0])
+		}
+
+		count, ok := args[1].(*object.Integer)
+		if !ok {
+			return argumentError("repeat", 2, object.INTEGER_OBJ, args[1])
+		}
+
+		if count.Value < 0 {
+			return newError("negative count in `repeat`: %d", count.Value)
+		}
+
+		if len(str.Value) > 0 && count.Value > maxStringLength/int64(len(str.Value)) {
+			return newError("string too long in `repeat`")
+		}
+
+		if err := allocateString(rt, int64(len(str.Value))*count.Value); err != nil {
+			return err
+		}
+
+		return &object.String{Value: strings.Repeat(str.Value, int(count.Value))}
+	})
+
+	b.RegisterRuntime("pad_left", Variadic, "pad_left(s, width, pad) prepends the characters of pad to s until it is width characters long, pad defaults to a space", func(rt object.Runtime, args ...object.Object) object.Object {
+		return pad("pad_left", rt, args, func(s, padding string) string { return padding + s })
+	})
+
+	b.RegisterRuntime("pad_right", Variadic, "pad_right(s, width, pad) appends the characters of pad to s until it is width characters long, pad defaults to a space", func(rt object.Runtime, args ...object.Object) object.Object {
+		return pad("pad_right", rt, args, func(s, padding string) string { return s + padding })
+	})
+
+	b.Register("chars", 1, "chars(s) returns the characters of s", func(args ...object.Object) object.Object {
+		return withStrings("chars", args, func(s ...string) object.Object {
+			return stringArray(strings.Split(s[0], ""))
+		})
+	})
+
+	b.RegisterRuntime("reverse", 1, "reverse(x) returns the characters of the string x, or a copy of the array x, in the reverse order", func(rt object.Runtime, args ...object.Object) object.Object {
+		if arr, ok := args[0].(*object.Array); ok {
+			reversed := newArray(rt, arr.Items)
+			if arr, ok := reversed.(*object.Array); ok {
+				reverseItems(arr.Items)
+			}
+
+			return reversed
+		}
+
+		return withStrings("reverse", args, func(s ...string) object.Object {
+			if err := allocateString(rt, int64(len(s[0]))); err != nil {
+				return err
+			}
+
+			runes := []rune(s[0])
+			for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+				runes[i], runes[j] = runes[j], runes[i]
+			}
+
+			return &object.String{Value: string(runes)}
+		})
+	})
+}
+
+// maxStringLength is the length of the longest string the builtins create, it stops
+// the builtins from exhausting the memory of the host before the limits can catch it.
+const maxStringLength = 1 << 30
+
+// withStrings calls fn with the values of the arguments, which must all be strings
+func withStrings(name string, args []object.Object, fn func(s ...string) object.Object) object.Object {
+	values := make([]string, len(args))
+
+	for i, arg := range args {
+		str, ok := arg.(*object.String)
+		if !ok {
+			return argumentError(name, i+1, object.STRING_OBJ, arg)
+		}
+
+		values[i] = str.Value
+	}
+
+	return fn(values...)
+}
+
+// pad fills the string with the pad until it is as long as the width, the pad is
+// repeated and cut as needed. add puts the padding on the right side of the string.
+func pad(name string, rt object.Runtime, args []object.Object, add func(s, padding string) string) object.Object {
+	if len(args) != 2 && len(args) != 3 {
+		return newError("invalid arguments count in function call, expected 2 or 3 argumets, got %d ", len(args))
+	}
+
+	str, ok := args[0].(*object.String)
+	if !ok {
+		return argumentError(name, 1, object.STRING_OBJ, args[0])
+	}
+
+	width, ok := args[1].(*object.Integer)
+	if !ok {
+		return argumentError(name, 2, object.INTEGER_OBJ, args[1])
+	}
+
+	padding := " "
+	if len(args) == 3 {
+		p, ok := args[2].(*object.String)
+		if !ok {
+			return argumentError(name, 3, object.STRING_OBJ, args[2])
+		}
+
+		if p.Value == "" {
+			return newError("empty pad in `%s`", name)
+		}
+
+		padding = p.Value
+	}
+
+	missing := width.Value - int64(utf8.RuneCountInString(str.Value))
+	if missing <= 0 {
+		return str
+	}
+
+	if missing > maxStringLength {
+		return newError("string too long in `%s`", name)
+	}
+
+	// the padding is made of full copies of the pad followed by the first characters of the pad
+	full, rest := missing/int64(utf8.RuneCountInString(padding)), missing%int64(utf8.RuneCountInString(padding))
+
+	cut := 0
+	for ; rest > 0; rest-- {
+		_, size := utf8.DecodeRuneInString(padding[cut:])
+		cut += size
+	}
+
+	length := int64(len(str.Value)) + full*int64(len(padding)) + int64(cut)
+	if length > maxStringLength {
+		return newError("string too long in `%s`", name)
+	}
+
+	if err := allocateString(rt, length); err != nil {
+		return err
+	}
+
+	return &object.String{Value: add(str.Value, strings.Repeat(padding, int(full))+padding[:cut])}
+}
+
+// allocateString accounts for a string of the given length in bytes the builtin is about to create
+func allocateString(rt object.Runtime, length int64) *object.Error {
+	return rt.Allocate(0, stringSize+length)
+}
+
+func stringArray(values []string) *object.Array {
+	items := make([]object.Object, len(values))
+	for i, value := range values {
+		items[i] = &object.String{Value: value}
+	}
+
+	return &object.Array{Items: items}
+}
+
+// argumentError reports an argument of a builtin that doesn't have the expected type, the arguments are counted from 1
+func argumentError(name string, position int, expected object.ObjectType, arg object.Object) *object.Error {
+	return newError("argument %d to `%s` must be %s, got %s", position, name, expected, arg.Type())
+}
@@ -0,0 +1,217 @@
+package interop
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yassinebenaid/nishimia/eval"
+	"github.com/yassinebenaid/nishimia/lexer"
+	"github.com/yassinebenaid/nishimia/object"
+	"github.com/yassinebenaid/nishimia/parser"
+)
+
+type user struct {
+	Name    string
+	Age     int      `nishimia:"age"`
+	Tags    []string `nishimia:"tags"`
+	Manager *user    `nishimia:"manager"`
+	Secret  string   `nishimia:"-"`
+	private int
+}
+
+func TestWrap(t *testing.T) {
+	var nilUser *user
+	var nilErr error
+	big, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+
+	tests := []struct {
+		value    any
+		expected string
+	}{
+		{nil, "null"},
+		{nilUser, "null"},
+		{nilErr, "null"},
+		{true, "true"},
+		{int8(-3), "-3"},
+		{uint64(math.MaxUint64), "18446744073709551615"},
+		{1.5, "1.5"},
+		{float32(2), "2.0"},
+		{"hello", "hello"},
+		{big, "123456789012345678901234567890"},
+		{[]int{1, 2}, "[1, 2]"},
+		{[2]bool{true, false}, "[true, false]"},
+		{[]string(nil), "null"},
+		{map[string]int{"b": 2, "a": 1}, "{a: 1, b: 2}"},
+		{map[string]any{"x": []any{1, "y", nil}}, "{x: [1, y, null]}"},
+		{&user{Name: "bob", Age: 30, Secret: "s", private: 1}, "{Name: bob, age: 30, manager: null, tags: null}"},
+		{user{Name: "bob", Manager: &user{Name: "alice"}}, "{Name: bob, age: 0, manager: {Name: alice, age: 0, manager: null, tags: null}, tags: null}"},
+		{&object.Integer{Value: 1}, "1"},
+		{strings.ToUpper, "builtin function"},
+		{errors.New("failed"), "ERROR: failed"},
+		{make(chan int), "ERROR: cannot convert chan int to a nishimia value"},
+		{map[any]int{"a": 1, [1]int{1}: 2}, "ERROR: unusable as hash key: ARRAY"},
+	}
+
+	for _, tt := range tests {
+		wrapped := Wrap(tt.value)
+
+		if wrapped.Inspect() != tt.expected {
+			t.Errorf("wrong wrapped value of %#v. expected=%q, got=%q", tt.value, tt.expected, wrapped.Inspect())
+		}
+	}
+
+	if Wrap(true) != eval.TRUE || Wrap(false) != eval.FALSE || Wrap(nil) != eval.NULL {
+		t.Errorf("expected the booleans and null to be the objects of the language")
+	}
+
+	cause := errors.New("failed")
+	if err := Wrap(cause).(*object.Error); err.Cause != cause {
+		t.Errorf("expected the error to be the cause of the error object")
+	}
+}
+
+func TestUnwrap(t *testing.T) {
+	var u user
+	err := Unwrap(Wrap(map[string]any{
+		"Name":    "bob",
+		"age":     30,
+		"tags":    []string{"a", "b"},
+		"manager": map[string]any{"Name": "alice"},
+		"unknown": 1,
+	}), &u)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := user{Name: "bob", Age: 30, Tags: []string{"a", "b"}, Manager: &user{Name: "alice"}}
+	if !reflect.DeepEqual(u, expected) {
+		t.Errorf("wrong struct. expected=%+v, got=%+v", expected, u)
+	}
+
+	var f float64
+	if err := Unwrap(Wrap(3), &f); err != nil || f != 3 {
+		t.Errorf("expected the integer to convert to a float, got=%v %v", f, err)
+	}
+
+	var b big.Int
+	if err := Unwrap(Wrap(uint64(math.MaxUint64)), &b); err != nil || b.String() != "18446744073709551615" {
+		t.Errorf("wrong big integer, got=%v %v", b.String(), err)
+	}
+
+	var m map[int][2]string
+	if err := Unwrap(Wrap(map[int][]string{1: {"a", "b"}}), &m); err != nil || m[1] != [2]string{"a", "b"} {
+		t.Errorf("wrong map, got=%v %v", m, err)
+	}
+
+	var value any
+	if err := Unwrap(Wrap(map[string]any{"a": []int{1}, "b": nil}), &value); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if !reflect.DeepEqual(value, map[any]any{"a": []any{int64(1)}, "b": nil}) {
+		t.Errorf("wrong native value. got=%#v", value)
+	}
+
+	var fn object.Object
+	function := &object.Function{}
+	if err := Unwrap(function, &fn); err != nil || fn != function {
+		t.Errorf("expected the object to be unwrapped as is, got=%v %v", fn, err)
+	}
+
+	errorTests := []struct {
+		obj      object.Object
+		target   any
+		expected string
+	}{
+		{Wrap(300), new(int8), "300 overflows int8"},
+		{Wrap(-1), new(uint), "-1 overflows uint"},
+		{Wrap("a"), new(int), "cannot convert STRING to int"},
+		{Wrap(1.5), new(int), "cannot convert FLOAT to int"},
+		{Wrap(nil), new(string), "cannot convert NULL to string"},
+		{Wrap([]int{1, 2}), new([3]int), "cannot convert ARRAY of 2 items to [3]int"},
+		{Wrap([]any{1, "a"}), new([]int), "item 1: cannot convert STRING to int"},
+		{Wrap(map[string]any{"age": "old"}), new(user), "field age: cannot convert STRING to int"},
+	}
+
+	for _, tt := range errorTests {
+		err := unwrap(tt.obj, reflect.ValueOf(tt.target).Elem())
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong error for %s. expected=%q, got=%v", tt.obj.Inspect(), tt.expected, err)
+		}
+	}
+}
+
+func TestWrapFunc(t *testing.T) {
+	builtins := eval.DefaultBuiltins()
+
+	builtins.Register("greet", 1, "", WrapFunc(func(u user) string {
+		return "hello " + u.Name + " (" + strings.Join(u.Tags, ", ") + ")"
+	}))
+	builtins.Register("join", eval.Variadic, "", WrapFunc(strings.Join))
+	builtins.Register("divide", 2, "", WrapFunc(func(a, b int) (int, error) {
+		if b == 0 {
+			return 0, errors.New("division by zero")
+		}
+
+		return a / b, nil
+	}))
+	builtins.Register("concat", eval.Variadic, "", WrapFunc(func(sep string, parts ...string) string {
+		return strings.Join(parts, sep)
+	}))
+	builtins.Register("split", 1, "", WrapFunc(func(s string) (string, string) {
+		before, after, _ := strings.Cut(s, "=")
+		return before, after
+	}))
+	builtins.Register("nothing", 0, "", WrapFunc(func() {}))
+	builtins.Register("describe", 1, "", WrapFunc(func(v any) string { return fmt.Sprint(v) }))
+	builtins.Register("count", 1, "", WrapFunc(func(items []any) int { return len(items) }))
+	builtins.Register("size", 1, "", WrapFunc(func(m map[string]any) int { return len(m) }))
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`greet({"Name": "bob", "tags": ["a", "b"]})`, "hello bob (a, b)"},
+		{`greet("bob")`, "ERROR: 1:1: invalid argument 1: cannot convert STRING to interop.user"},
+		{`join(["a", "b"], "-")`, "a-b"},
+		{`join(["a", "b"])`, "ERROR: 1:1: invalid arguments count in function call, expected 2 argumets, got 1 "},
+		{`divide(7, 2)`, "3"},
+		{`divide(7, 0)`, "ERROR: 1:1: division by zero"},
+		{`concat("-")`, ""},
+		{`concat("-", "a", "b", "c")`, "a-b-c"},
+		{`concat()`, "ERROR: 1:1: invalid arguments count in function call, expected at least 1 argumets, got 0 "},
+		{`concat("-", 1)`, "ERROR: 1:1: invalid argument 2: cannot convert INTEGER to string"},
+		{`split("a=b")`, "[a, b]"},
+		{`nothing()`, "null"},
+		// the programs can build values containing themselves, they can't be converted
+		{"var a = [1]; a[0] = a; describe(a)", "ERROR: 1:24: invalid argument 1: item 0: cannot convert cyclic value ARRAY"},
+		{"var a = [1]; a[0] = a; count(a)", "ERROR: 1:24: invalid argument 1: item 0: cannot convert cyclic value ARRAY"},
+		{`var h = {}; h["self"] = h; size(h)`, "ERROR: 1:28: invalid argument 1: item self: cannot convert cyclic value HASH"},
+		{`var h = {}; h["a"] = [h]; describe(h)`, "ERROR: 1:27: invalid argument 1: item a: item 0: cannot convert cyclic value HASH"},
+		{"var s = [1]; describe([s, s])", "[[1] [1]]"},
+	}
+
+	evaluator := eval.New(eval.Options{Builtins: builtins})
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+
+		result := evaluator.Eval(program, object.NewEnvirement())
+		if result.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected WrapFunc to panic for a non-function")
+		}
+	}()
+
+	WrapFunc(1)
+}
@@ -0,0 +1,309 @@
+package interop
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+
+	"github.com/yassinebenaid/nishimia/object"
+)
+
+// Unwrap converts the object to the Go value target points to, it is the reverse of Wrap:
+//   - integers convert to the integer types they fit in, and to the float types
+//   - big integers convert to *big.Int and big.Int
+//   - arrays convert to slices and to arrays of the same length, hashes to maps and to structs
+//   - the keys of a hash that are not the name of a field of the struct are ignored
+//   - null converts to the zero value of the pointers, slices, maps and interfaces
+//   - the objects convert to the interfaces they implement, like object.Object, so the functions
+//     can take the values they can't convert like the nishimia functions
+//
+// The objects convert to the following Go values when the target is an empty interface:
+// int64, *big.Int, float64, string, bool, nil, []any and map[any]any.
+// The arrays and the hashes containing themselves can't be converted, Unwrap returns an error for them.
+func Unwrap[T any](obj object.Object, target *T) error {
+	return unwrap(obj, reflect.ValueOf(target).Elem())
+}
+
+func unwrap(obj object.Object, dst reflect.Value) error {
+	return (&unwrapper{visiting: map[object.Object]bool{}}).unwrap(obj, dst)
+}
+
+// unwrapper converts the objects to Go values. The programs can build arrays and hashes containing themselves,
+// they are tracked while their items are converted and the conversion fails when one is reached again.
+type unwrapper struct {
+	visiting map[object.Object]bool
+}
+
+// enter marks the array or the hash as being converted, leave must be called once its items are converted
+func (u *unwrapper) enter(obj object.Object) error {
+	if u.visiting[obj] {
+		return fmt.Errorf("cannot convert cyclic value %s", obj.Type())
+	}
+
+	u.visiting[obj] = true
+	return nil
+}
+
+func (u *unwrapper) leave(obj object.Object) {
+	delete(u.visiting, obj)
+}
+
+func (u *unwrapper) unwrap(obj object.Object, dst reflect.Value) error {
+	if reflect.TypeOf(obj).AssignableTo(dst.Type()) && (dst.Kind() != reflect.Interface || dst.NumMethod() > 0) {
+		dst.Set(reflect.ValueOf(obj))
+		return nil
+	}
+
+	if _, ok := obj.(*object.Null); ok {
+		switch dst.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Map, reflect.Interface:
+			dst.SetZero()
+			return nil
+		}
+
+		return cannotConvert(obj, dst)
+	}
+
+	switch dst.Kind() {
+	case reflect.Interface:
+		value, err := u.native(obj)
+		if err != nil {
+			return err
+		}
+
+		if value != nil {
+			dst.Set(reflect.ValueOf(value))
+		}
+
+		return nil
+	case reflect.Pointer:
+		if dst.Type() == bigIntType {
+			if b, ok := bigIntValue(obj); ok {
+				dst.Set(reflect.ValueOf(b))
+				return nil
+			}
+
+			return cannotConvert(obj, dst)
+		}
+
+		value := reflect.New(dst.Type().Elem())
+		if err := u.unwrap(obj, value.Elem()); err != nil {
+			return err
+		}
+
+		dst.Set(value)
+		return nil
+	case reflect.Bool:
+		if b, ok := obj.(*object.Boolean); ok {
+			dst.SetBool(b.Value)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i, ok := obj.(*object.Integer); ok {
+			if dst.OverflowInt(i.Value) {
+				return fmt.Errorf("%d overflows %s", i.Value, dst.Type())
+			}
+
+			dst.SetInt(i.Value)
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		switch i := obj.(type) {
+		case *object.Integer:
+			if i.Value < 0 || dst.OverflowUint(uint64(i.Value)) {
+				return fmt.Errorf("%d overflows %s", i.Value, dst.Type())
+			}
+
+			dst.SetUint(uint64(i.Value))
+			return nil
+		case *object.BigInt:
+			if !i.Value.IsUint64() || dst.OverflowUint(i.Value.Uint64()) {
+				return fmt.Errorf("%s overflows %s", i.Value, dst.Type())
+			}
+
+			dst.SetUint(i.Value.Uint64())
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		switch f := obj.(type) {
+		case *object.Float:
+			dst.SetFloat(f.Value)
+			return nil
+		case *object.Integer:
+			dst.SetFloat(float64(f.Value))
+			return nil
+		}
+	case reflect.String:
+		if s, ok := obj.(*object.String); ok {
+			dst.SetString(s.Value)
+			return nil
+		}
+	case reflect.Slice, reflect.Array:
+		if arr, ok := obj.(*object.Array); ok {
+			return u.unwrapArray(arr, dst)
+		}
+	case reflect.Map:
+		if hash, ok := obj.(*object.Hash); ok {
+			return u.unwrapMap(hash, dst)
+		}
+	case reflect.Struct:
+		if dst.Type() == bigIntType.Elem() {
+			if b, ok := bigIntValue(obj); ok {
+				dst.Set(reflect.ValueOf(b).Elem())
+				return nil
+			}
+
+			return cannotConvert(obj, dst)
+		}
+
+		if hash, ok := obj.(*object.Hash); ok {
+			return u.unwrapStruct(hash, dst)
+		}
+	}
+
+	return cannotConvert(obj, dst)
+}
+
+// bigIntValue returns a copy of the value of an integer or a big integer
+func bigIntValue(obj object.Object) (*big.Int, bool) {
+	switch i := obj.(type) {
+	case *object.Integer:
+		return big.NewInt(i.Value), true
+	case *object.BigInt:
+		return new(big.Int).Set(i.Value), true
+	}
+
+	return nil, false
+}
+
+func (u *unwrapper) unwrapArray(arr *object.Array, dst reflect.Value) error {
+	if err := u.enter(arr); err != nil {
+		return err
+	}
+	defer u.leave(arr)
+
+	if dst.Kind() == reflect.Array {
+		if dst.Len() != len(arr.Items) {
+			return fmt.Errorf("cannot convert ARRAY of %d items to %s", len(arr.Items), dst.Type())
+		}
+	} else {
+		dst.Set(reflect.MakeSlice(dst.Type(), len(arr.Items), len(arr.Items)))
+	}
+
+	for i, item := range arr.Items {
+		if err := u.unwrap(item, dst.Index(i)); err != nil {
+			return fmt.Errorf("item %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+func (u *unwrapper) unwrapMap(hash *object.Hash, dst reflect.Value) error {
+	if err := u.enter(hash); err != nil {
+		return err
+	}
+	defer u.leave(hash)
+
+	m := reflect.MakeMapWithSize(dst.Type(), len(hash.Items))
+
+	for _, pair := range hash.Pairs() {
+		key := reflect.New(dst.Type().Key()).Elem()
+		if err := u.unwrap(pair.Key, key); err != nil {
+			return fmt.Errorf("key %s: %w", pair.Key.Inspect(), err)
+		}
+
+		value := reflect.New(dst.Type().Elem()).Elem()
+		if err := u.unwrap(pair.Value, value); err != nil {
+			return fmt.Errorf("item %s: %w", pair.Key.Inspect(), err)
+		}
+
+		m.SetMapIndex(key, value)
+	}
+
+	dst.Set(m)
+	return nil
+}
+
+func (u *unwrapper) unwrapStruct(hash *object.Hash, dst reflect.Value) error {
+	if err := u.enter(hash); err != nil {
+		return err
+	}
+	defer u.leave(hash)
+
+	for _, field := range fields(dst.Type()) {
+		pair, ok := hash.Items[(&object.String{Value: field.name}).HashKey()]
+		if !ok {
+			continue
+		}
+
+		if err := u.unwrap(pair.Value, dst.Field(field.index)); err != nil {
+			return fmt.Errorf("field %s: %w", field.name, err)
+		}
+	}
+
+	return nil
+}
+
+// native returns the Go value an object converts to when the target is an empty interface
+func (u *unwrapper) native(obj object.Object) (any, error) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return obj.Value, nil
+	case *object.BigInt:
+		return new(big.Int).Set(obj.Value), nil
+	case *object.Float:
+		return obj.Value, nil
+	case *object.String:
+		return obj.Value, nil
+	case *object.Boolean:
+		return obj.Value, nil
+	case *object.Null:
+		return nil, nil
+	case *object.Array:
+		if err := u.enter(obj); err != nil {
+			return nil, err
+		}
+		defer u.leave(obj)
+
+		items := make([]any, len(obj.Items))
+		for i, item := range obj.Items {
+			value, err := u.native(item)
+			if err != nil {
+				return nil, fmt.Errorf("item %d: %w", i, err)
+			}
+
+			items[i] = value
+		}
+
+		return items, nil
+	case *object.Hash:
+		if err := u.enter(obj); err != nil {
+			return nil, err
+		}
+		defer u.leave(obj)
+
+		m := make(map[any]any, len(obj.Items))
+		for _, pair := range obj.Pairs() {
+			key, err := u.native(pair.Key)
+			if err != nil {
+				return nil, fmt.Errorf("key %s: %w", pair.Key.Inspect(), err)
+			}
+
+			value, err := u.native(pair.Value)
+			if err != nil {
+				return nil, fmt.Errorf("item %s: %w", pair.Key.Inspect(), err)
+			}
+
+			m[key] = value
+		}
+
+		return m, nil
+	default:
+		return obj, nil
+	}
+}
+
+func cannotConvert(obj object.Object, dst reflect.Value) error {
+	return fmt.Errorf("cannot convert %s to %s", obj.Type(), dst.Type())
+}
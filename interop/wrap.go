@@ -0,0 +1,291 @@
+// Package interop converts Go values to nishimia objects and back using reflection, it allows
+// exposing Go functions to the programs without writing the conversions of their arguments:
+//
+//	builtins.Register("greet", 1, "greet(name) greets the name", interop.WrapFunc(greet))
+package interop
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+
+	"github.com/yassinebenaid/nishimia/eval"
+	"github.com/yassinebenaid/nishimia/object"
+)
+
+var (
+	objectType = reflect.TypeOf((*object.Object)(nil)).Elem()
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+	bigIntType = reflect.TypeOf((*big.Int)(nil))
+)
+
+// Wrap converts the Go value to an object:
+//   - nil and nil pointers, slices, maps and interfaces become null
+//   - booleans, integers, floats and strings become the objects of the same kind, the integers
+//     that don't fit in 64 bits and *big.Int become big integers
+//   - slices and arrays become arrays, maps become hashes
+//   - structs become hashes of their exported fields, a field is named after its `nishimia` tag if
+//     it has one, the fields tagged with "-" are skipped
+//   - pointers become the object of the value they point to
+//   - errors become error objects, the evaluation fails when a program gets one
+//   - functions become builtins, see WrapFunc
+//
+// The objects are returned as is, the values of other types like channels become error objects.
+// The values must not contain cycles.
+func Wrap(value any) object.Object {
+	if value == nil {
+		return eval.NULL
+	}
+
+	return wrap(reflect.ValueOf(value))
+}
+
+func wrap(v reflect.Value) object.Object {
+	if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+		return eval.NULL
+	}
+
+	if v.Kind() == reflect.Interface {
+		return wrap(v.Elem())
+	}
+
+	switch {
+	case v.Type().Implements(objectType):
+		return v.Interface().(object.Object)
+	case v.Type().Implements(errorType):
+		err := v.Interface().(error)
+		return &object.Error{Message: err.Error(), Cause: err}
+	case v.Type() == bigIntType:
+		return bigInt(new(big.Int).Set(v.Interface().(*big.Int)))
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return eval.TRUE
+		}
+
+		return eval.FALSE
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: v.Int()}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return &object.BigInt{Value: new(big.Int).SetUint64(v.Uint())}
+		}
+
+		return &object.Integer{Value: int64(v.Uint())}
+	case reflect.Float32, reflect.Float64:
+		return &object.Float{Value: v.Float()}
+	case reflect.String:
+		return &object.String{Value: v.String()}
+	case reflect.Pointer:
+		return wrap(v.Elem())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return eval.NULL
+		}
+
+		items := make([]object.Object, v.Len())
+		for i := range items {
+			item := wrap(v.Index(i))
+			if isError(item) {
+				return item
+			}
+
+			items[i] = item
+		}
+
+		return &object.Array{Items: items}
+	case reflect.Map:
+		if v.IsNil() {
+			return eval.NULL
+		}
+
+		hash := &object.Hash{Items: make(map[object.HashKey]object.HashPair, v.Len())}
+
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := setPair(hash, wrap(iter.Key()), wrap(iter.Value())); err != nil {
+				return err
+			}
+		}
+
+		return hash
+	case reflect.Struct:
+		hash := &object.Hash{Items: make(map[object.HashKey]object.HashPair)}
+
+		for _, field := range fields(v.Type()) {
+			if err := setPair(hash, &object.String{Value: field.name}, wrap(v.Field(field.index))); err != nil {
+				return err
+			}
+		}
+
+		return hash
+	case reflect.Func:
+		if v.IsNil() {
+			return eval.NULL
+		}
+
+		return &object.Builtin{Fn: wrapFunc(v)}
+	default:
+		return &object.Error{Message: fmt.Sprintf("cannot convert %s to a nishimia value", v.Type())}
+	}
+}
+
+// setPair adds the pair to the hash, it returns an error if the key or the value is one or if the key is not hashable
+func setPair(hash *object.Hash, key, value object.Object) *object.Error {
+	if isError(key) {
+		return key.(*object.Error)
+	}
+
+	if isError(value) {
+		return value.(*object.Error)
+	}
+
+	hashable, ok := key.(object.Hashable)
+	if !ok {
+		return &object.Error{Message: fmt.Sprintf("unusable as hash key: %s", key.Type())}
+	}
+
+	hash.Items[hashable.HashKey()] = object.HashPair{Key: key, Value: value}
+	return nil
+}
+
+// field is an exported field of a struct and the name the programs know it by
+type field struct {
+	index int
+	name  string
+}
+
+func fields(t reflect.Type) []field {
+	var fields []field
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("nishimia"); ok {
+			if tag == "-" {
+				continue
+			}
+
+			if tag != "" {
+				name = tag
+			}
+		}
+
+		fields = append(fields, field{index: i, name: name})
+	}
+
+	return fields
+}
+
+// WrapFunc converts the Go function to a builtin function, the builtin converts its arguments to the types of the
+// parameters using Unwrap and fails if they can't be converted. The results are converted using Wrap:
+//   - if the last result is an error, the builtin fails with it when it is not nil, and ignores it otherwise
+//   - no other result gives null, a single one gives its value and several ones give an array of their values
+//
+// It panics if fn is not a function.
+func WrapFunc(fn any) object.BuiltinFunction {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func {
+		panic(fmt.Sprintf("interop: WrapFunc of non-function %T", fn))
+	}
+
+	return wrapFunc(v)
+}
+
+func wrapFunc(fn reflect.Value) object.BuiltinFunction {
+	t := fn.Type()
+
+	return func(args ...object.Object) object.Object {
+		if err := checkArity(t, len(args)); err != nil {
+			return err
+		}
+
+		in := make([]reflect.Value, len(args))
+		for i, arg := range args {
+			param := paramType(t, i)
+
+			in[i] = reflect.New(param).Elem()
+			if err := unwrap(arg, in[i]); err != nil {
+				return &object.Error{Message: fmt.Sprintf("invalid argument %d: %s", i+1, err), Cause: err}
+			}
+		}
+
+		return wrapResults(fn.Call(in))
+	}
+}
+
+func checkArity(t reflect.Type, n int) *object.Error {
+	if t.IsVariadic() {
+		if n < t.NumIn()-1 {
+			return &object.Error{Message: fmt.Sprintf(
+				"invalid arguments count in function call, expected at least %d argumets, got %d ", t.NumIn()-1, n,
+			)}
+		}
+
+		return nil
+	}
+
+	if n != t.NumIn() {
+		return &object.Error{Message: fmt.Sprintf(
+			"invalid arguments count in function call, expected %d argumets, got %d ", t.NumIn(), n,
+		)}
+	}
+
+	return nil
+}
+
+// paramType returns the type of the i-th argument, the variadic arguments have the type of the items of the last parameter
+func paramType(t reflect.Type, i int) reflect.Type {
+	if t.IsVariadic() && i >= t.NumIn()-1 {
+		return t.In(t.NumIn() - 1).Elem()
+	}
+
+	return t.In(i)
+}
+
+func wrapResults(results []reflect.Value) object.Object {
+	if n := len(results); n > 0 && results[n-1].Type() == errorType {
+		if !results[n-1].IsNil() {
+			return wrap(results[n-1])
+		}
+
+		results = results[:n-1]
+	}
+
+	switch len(results) {
+	case 0:
+		return eval.NULL
+	case 1:
+		return wrap(results[0])
+	}
+
+	items := make([]object.Object, len(results))
+	for i, result := range results {
+		items[i] = wrap(result)
+		if isError(items[i]) {
+			return items[i]
+		}
+	}
+
+	return &object.Array{Items: items}
+}
+
+func bigInt(value *big.Int) object.Object {
+	if value.IsInt64() {
+		return &object.Integer{Value: value.Int64()}
+	}
+
+	return &object.BigInt{Value: value}
+}
+
+func isError(obj object.Object) bool {
+	_, ok := obj.(*object.Error)
+	return ok
+}
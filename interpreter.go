@@ -0,0 +1,144 @@
+// Package nishimia embeds the nishimia language in Go programs, it wires the lexer,
+// the parser and the evaluator behind an Interpreter:
+//
+//	interp := nishimia.New(nishimia.Options{})
+//	result, err := interp.Eval(ctx, "1 + 2")
+package nishimia
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/yassinebenaid/nishimia/ast"
+	"github.com/yassinebenaid/nishimia/eval"
+	"github.com/yassinebenaid/nishimia/lexer"
+	"github.com/yassinebenaid/nishimia/object"
+	"github.com/yassinebenaid/nishimia/parser"
+)
+
+// Options configures an Interpreter
+type Options struct {
+	// Stdout is where the print builtin writes, os.Stdout is used when it is nil
+	Stdout io.Writer
+
+	// Evaluator holds the options of the language and the limits of every run, like the
+	// maximum call depth, the gas budget and the memory limit. When it has no registry of
+	// builtins, the interpreter gets its own with the builtins of the language and print,
+	// otherwise the programs can only call the builtins of the given registry.
+	Evaluator eval.Options
+}
+
+// Interpreter runs nishimia programs, the programs it runs share their global variables.
+// It is not safe for concurrent use, the interpreters are independent of each other.
+type Interpreter struct {
+	evaluator *eval.Evaluator
+	builtins  *eval.Builtins
+	globals   *object.Environment
+}
+
+// New returns an interpreter with no global variables
+func New(options Options) *Interpreter {
+	stdout := options.Stdout
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+
+	if options.Evaluator.Builtins == nil {
+		options.Evaluator.Builtins = eval.DefaultBuiltins()
+		options.Evaluator.Builtins.Register("print", eval.Variadic, PrintDoc, Print(stdout))
+	}
+
+	return &Interpreter{
+		evaluator: eval.New(options.Evaluator),
+		builtins:  options.Evaluator.Builtins,
+		globals:   object.NewEnvirement(),
+	}
+}
+
+// PrintDoc is the documentation of the print builtin
+const PrintDoc = "print(values...) writes the values separated by spaces on a line"
+
+// Print returns the print builtin writing to w, it allows adding print to a registry of builtins
+func Print(w io.Writer) object.BuiltinFunction {
+	return func(args ...object.Object) object.Object {
+		values := make([]string, len(args))
+		for i, arg := range args {
+			values[i] = arg.Inspect()
+		}
+
+		if _, err := fmt.Fprintln(w, strings.Join(values, " ")); err != nil {
+			return &object.Error{Message: "failed to print: " + err.Error(), Cause: err}
+		}
+
+		return eval.NULL
+	}
+}
+
+// Builtins returns the registry of the builtins of the interpreter, the changes
+// made to it apply to the programs run afterwards.
+func (i *Interpreter) Builtins() *eval.Builtins {
+	return i.builtins
+}
+
+// Program is a parsed program, it can be run several times
+type Program struct {
+	program *ast.Program
+}
+
+// Compile parses the source code, the syntax errors are reported by a *SyntaxError
+func (i *Interpreter) Compile(src string) (*Program, error) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+
+	if errs := p.Errors(); len(errs) > 0 {
+		return nil, &SyntaxError{Errors: errs}
+	}
+
+	return &Program{program: program}, nil
+}
+
+// Run runs the program until it is over or the context is done, it returns the value of the program.
+// The errors of the program are reported by a *RuntimeError.
+func (i *Interpreter) Run(ctx context.Context, program *Program) (object.Object, error) {
+	result := i.evaluator.EvalContext(ctx, program.program, i.globals)
+
+	if err, ok := result.(*object.Error); ok {
+		return nil, &RuntimeError{Value: err}
+	}
+
+	return result, nil
+}
+
+// Apply calls a function the programs handed over, like a callback stored in a global variable.
+// The errors of the call are reported by a *RuntimeError.
+func (i *Interpreter) Apply(ctx context.Context, fn object.Object, args ...object.Object) (object.Object, error) {
+	result, err := i.evaluator.Apply(ctx, fn, args...)
+	if err != nil {
+		return nil, &RuntimeError{Value: err.(*object.Error)}
+	}
+
+	return result, nil
+}
+
+// Eval compiles and runs the source code
+func (i *Interpreter) Eval(ctx context.Context, src string) (object.Object, error) {
+	program, err := i.Compile(src)
+	if err != nil {
+		return nil, err
+	}
+
+	return i.Run(ctx, program)
+}
+
+// Set defines the global variable, or replaces its value if it is already defined
+func (i *Interpreter) Set(name string, value object.Object) {
+	i.globals.Set(name, value)
+}
+
+// Get returns the value of the global variable, the builtins are not variables
+func (i *Interpreter) Get(name string) (object.Object, bool) {
+	return i.globals.Get(name)
+}
@@ -0,0 +1,185 @@
+package nishimia
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/yassinebenaid/nishimia/eval"
+	"github.com/yassinebenaid/nishimia/object"
+)
+
+func TestEval(t *testing.T) {
+	interp := New(Options{})
+	ctx := context.Background()
+
+	if _, err := interp.Eval(ctx, "var x = 40;"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	interp.Set("y", &object.Integer{Value: 2})
+
+	// the programs share their global variables
+	result, err := interp.Eval(ctx, "x + y")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if result.Inspect() != "42" {
+		t.Errorf("wrong result. expected=42, got=%s", result.Inspect())
+	}
+
+	if x, ok := interp.Get("x"); !ok || x.Inspect() != "40" {
+		t.Errorf("wrong value of x. got=%v", x)
+	}
+
+	if _, ok := interp.Get("print"); ok {
+		t.Errorf("expected the builtins not to be variables")
+	}
+
+	// the interpreters don't share their variables
+	if _, err := New(Options{}).Eval(ctx, "x"); err == nil {
+		t.Errorf("expected x to be undefined in another interpreter")
+	}
+}
+
+func TestCompile(t *testing.T) {
+	interp := New(Options{})
+
+	program, err := interp.Compile("var n = 0; n = n + 1;")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// a program can run several times, the second run finds n already defined
+	if _, err := interp.Run(context.Background(), program); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	_, err = interp.Run(context.Background(), program)
+
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) || err.Error() != "1:1: variable n already defined" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	interp := New(Options{Evaluator: eval.Options{Budget: 1000}})
+
+	_, err := interp.Eval(context.Background(), "var = 1;")
+
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) || len(syntaxErr.Errors) == 0 {
+		t.Fatalf("expected a syntax error, got=%v", err)
+	}
+
+	_, err = interp.Eval(context.Background(), "var f = func() { 1 + true };\nf()")
+
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) {
+		t.Fatalf("expected a runtime error, got=%v", err)
+	}
+
+	if err.Error() != "1:18: invalid operation: 1 + true (mismatched types INTEGER and BOOLEAN)" {
+		t.Errorf("wrong error message. got=%q", err.Error())
+	}
+
+	if runtimeErr.Value.StackTrace() != "at f() 2:1" {
+		t.Errorf("wrong stack trace. got=%q", runtimeErr.Value.StackTrace())
+	}
+
+	if _, err := interp.Eval(context.Background(), "while true { }"); !errors.Is(err, eval.ErrBudgetExhausted) {
+		t.Errorf("expected the budget to be exhausted, got=%v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := New(Options{}).Eval(ctx, "while true { }"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the run to be cancelled, got=%v", err)
+	}
+}
+
+func TestBuiltins(t *testing.T) {
+	var stdout bytes.Buffer
+
+	interp := New(Options{Stdout: &stdout})
+
+	interp.Builtins().Register("double", 1, "double(n) returns 2 * n", func(args ...object.Object) object.Object {
+		return &object.Integer{Value: args[0].(*object.Integer).Value * 2}
+	})
+
+	interp.Builtins().Register("len", 1, "len(x) returns -1", func(args ...object.Object) object.Object {
+		return &object.Integer{Value: -1}
+	})
+
+	result, err := interp.Eval(context.Background(), `print("a", 1, [true]); print(); double(21) + len("abc")`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if result.Inspect() != "41" {
+		t.Errorf("wrong result. expected=41, got=%s", result.Inspect())
+	}
+
+	if stdout.String() != "a 1 [true]\n\n" {
+		t.Errorf("wrong output. got=%q", stdout.String())
+	}
+
+	if _, err := interp.Eval(context.Background(), "print = 1"); err == nil || err.Error() != "1:1: cannot assign to undefined variable print" {
+		t.Errorf("expected the builtins not to be assignable, got=%v", err)
+	}
+
+	if result, err := interp.Eval(context.Background(), "var print = 1; print"); err != nil || result.Inspect() != "1" {
+		t.Errorf("expected the builtins to be shadowed, got=%v %v", result, err)
+	}
+
+	// the builtins of an interpreter don't leak to the others
+	if _, err := New(Options{}).Eval(context.Background(), "double(1)"); err == nil {
+		t.Errorf("expected double to be undefined in another interpreter")
+	}
+
+	// a tenant restricted to some builtins can't call the others, even print
+	tenant := New(Options{Evaluator: eval.Options{Builtins: interp.Builtins().Restrict("double")}})
+
+	if result, err := tenant.Eval(context.Background(), "double(2)"); err != nil || result.Inspect() != "4" {
+		t.Errorf("expected double to be allowed, got=%v %v", result, err)
+	}
+
+	if _, err := tenant.Eval(context.Background(), `print("a")`); err == nil || err.Error() != "1:1: undefined identifier : print" {
+		t.Errorf("expected print to be undefined, got=%v", err)
+	}
+}
+
+func TestApply(t *testing.T) {
+	interp := New(Options{})
+	ctx := context.Background()
+
+	_, err := interp.Eval(ctx, `var hooks = {"role": func(user) { return user + " is admin"; }, "fail": func() { return 1 + true; }};`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	hooks, _ := interp.Get("hooks")
+	hook := func(name string) object.Object {
+		return hooks.(*object.Hash).Items[(&object.String{Value: name}).HashKey()].Value
+	}
+
+	result, err := interp.Apply(ctx, hook("role"), &object.String{Value: "bob"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if result.Inspect() != "bob is admin" {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+
+	_, err = interp.Apply(ctx, hook("fail"))
+
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) || runtimeErr.Value.StackTrace() != "at <anonymous>()" {
+		t.Errorf("expected a runtime error, got=%v", err)
+	}
+}
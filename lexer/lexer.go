@@ -2,27 +2,65 @@ package lexer
 
 import (
 	"strings"
+	"unicode/utf8"
 
 	"github.com/yassinebenaid/nishimia/token"
 )
 
+// Mode controls the optional behaviours of the lexer
+type Mode uint
+
+const (
+	ScanComments Mode = 1 << iota // emit comments as token.COMMENT instead of skipping them
+)
+
 type Lexer struct {
+	mode         Mode   // the enabled optional behaviours
+	file         string // the name of the source file, may be empty
 	input        string // the source code
 	position     int    // the current position , points to the index of ch
 	readPosition int    // the current read position, the next character after
 	ch           byte   // the haracter under examination
+	line         int    // the line of ch, starting at 1
+	column       int    // the column of ch in runes, starting at 1
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewFile("", input)
+}
+
+// NewFile creates a lexer for the source code of the given file,
+// the file name is recorded in the position of every token.
+func NewFile(file string, input string) *Lexer {
+	l := &Lexer{file: file, input: input, line: 1}
 	l.readChar()
 	return l
 }
 
+// SetMode enables the given optional behaviours
+func (l *Lexer) SetMode(mode Mode) {
+	l.mode = mode
+}
+
 func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
+	for {
+		l.skipWhiteSpace()
 
-	l.skipWhiteSpace()
+		pos := l.pos()
+		tok := l.readToken()
+		tok.Pos = pos
+		tok.End = l.pos()
+
+		if tok.Type == token.COMMENT && l.mode&ScanComments == 0 {
+			continue
+		}
+
+		return tok
+	}
+}
+
+func (l *Lexer) readToken() token.Token {
+	var tok token.Token
 
 	switch l.ch {
 	case '=':
@@ -37,11 +75,13 @@ func (l *Lexer) NextToken() token.Token {
 		l.readChar()
 		tok = l.readString()
 	case '+':
-		tok = newToken(token.PLUS, '+')
+		tok = l.readOperator(token.PLUS, token.PLUS_ASSIGN)
 	case '-':
-		tok = newToken(token.MINUS, '-')
+		tok = l.readOperator(token.MINUS, token.MINUS_ASSIGN)
 	case '*':
-		tok = newToken(token.ASTERISK, '*')
+		tok = l.readOperator(token.ASTERISK, token.ASTERISK_ASSIGN)
+	case '%':
+		tok = l.readOperator(token.PERCENT, token.PERCENT_ASSIGN)
 	case '(':
 		tok = newToken(token.LPARENT, '(')
 	case ')':
@@ -60,8 +100,22 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.SEMICOLON, ';')
 	case ':':
 		tok = newToken(token.COLON, ':')
+	case '.':
+		// a dot followed by a digit starts a float like .5
+		if isDigit(l.peakChar()) {
+			tok.Literal, tok.Type = l.readNumber()
+			return tok
+		}
+
+		tok = newToken(token.DOT, '.')
 	case '/':
-		tok = newToken(token.SLASH, '/')
+		if l.peakChar() == '/' {
+			return l.readLineComment()
+		} else if l.peakChar() == '*' {
+			return l.readBlockComment()
+		}
+
+		tok = l.readOperator(token.SLASH, token.SLASH_ASSIGN)
 	case '!':
 		if l.peakChar() == '=' {
 			ch := l.ch
@@ -111,8 +165,7 @@ func (l *Lexer) NextToken() token.Token {
 			tok.Type = token.LookupIdent(tok.Literal)
 			return tok
 		} else if isDigit(l.ch) {
-			tok.Literal = l.readNumber()
-			tok.Type = token.INT
+			tok.Literal, tok.Type = l.readNumber()
 			return tok
 		} else {
 			tok = newToken(token.ILLIGAL, l.ch)
@@ -123,17 +176,51 @@ func (l *Lexer) NextToken() token.Token {
 	return tok
 }
 
+// readOperator reads an operator that becomes an assignment operator when followed by "=" like + and +=
+func (l *Lexer) readOperator(operator token.TokenType, assignment token.TokenType) token.Token {
+	if l.peakChar() == '=' {
+		ch := l.ch
+		l.readChar()
+		return token.Token{Type: assignment, Literal: string(ch) + string(l.ch)}
+	}
+
+	return newToken(operator, l.ch)
+}
+
 func (l *Lexer) readChar() {
+	if l.readPosition > len(l.input) {
+		return
+	}
+
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
 		l.ch = l.input[l.readPosition]
 	}
 
+	if utf8.RuneStart(l.ch) {
+		l.column++
+	}
+
 	l.position = l.readPosition
 	l.readPosition++
 }
 
+// pos returns the position of the character under examination
+func (l *Lexer) pos() token.Position {
+	return token.Position{
+		Filename: l.file,
+		Offset:   l.position,
+		Line:     l.line,
+		Column:   l.column,
+	}
+}
+
 func (l *Lexer) peakChar() byte {
 	if l.readPosition >= len(l.input) {
 		return 0
@@ -152,14 +239,45 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[position:l.position]
 }
 
-func (l *Lexer) readNumber() string {
+// readNumber reads an integer like 10, or a float like 3.14, .5 or 1e-9
+func (l *Lexer) readNumber() (string, token.TokenType) {
 	position := l.position
+	kind := token.INT
 
 	for isDigit(l.ch) {
 		l.readChar()
 	}
 
-	return l.input[position:l.position]
+	if l.ch == '.' && isDigit(l.peakChar()) {
+		kind = token.FLOAT
+		l.readChar()
+
+		for isDigit(l.ch) {
+			l.readChar()
+		}
+	}
+
+	if l.ch == 'e' || l.ch == 'E' {
+		next := l.peakChar()
+
+		// the sign is only part of the number if a digit follows it
+		if next == '+' || next == '-' {
+			if l.readPosition+1 < len(l.input) && isDigit(l.input[l.readPosition+1]) {
+				kind = token.FLOAT
+				l.readChar()
+				l.readChar()
+			}
+		} else if isDigit(next) {
+			kind = token.FLOAT
+			l.readChar()
+		}
+
+		for kind == token.FLOAT && isDigit(l.ch) {
+			l.readChar()
+		}
+	}
+
+	return l.input[position:l.position], kind
 }
 
 func (l *Lexer) skipWhiteSpace() {
@@ -183,6 +301,41 @@ func (l *Lexer) readString() token.Token {
 	return tok
 }
 
+func (l *Lexer) readLineComment() token.Token {
+	position := l.position
+
+	for l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
+
+	return token.Token{Type: token.COMMENT, Literal: l.input[position:l.position]}
+}
+
+// readBlockComment reads a comment delimited by "/*" and "*/", block comments can be nested.
+func (l *Lexer) readBlockComment() token.Token {
+	position := l.position
+	depth := 0
+
+	for {
+		switch {
+		case l.ch == 0:
+			return token.Token{Type: token.ILLIGAL, Literal: "unterminated comment"}
+		case l.ch == '/' && l.peakChar() == '*':
+			depth++
+			l.readChar()
+		case l.ch == '*' && l.peakChar() == '/':
+			depth--
+			l.readChar()
+		}
+
+		l.readChar()
+
+		if depth == 0 {
+			return token.Token{Type: token.COMMENT, Literal: l.input[position:l.position]}
+		}
+	}
+}
+
 func newToken(t token.TokenType, v byte) token.Token {
 	return token.Token{Type: t, Literal: string(v)}
 }
@@ -410,3 +410,214 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+func TestTokenPositions(t *testing.T) {
+	input := "var a = 5;\nvar s = \"héllo\"; s\n"
+
+	cases := []struct {
+		tokenType token.TokenType
+		pos       token.Position
+		end       token.Position
+	}{
+		{token.VAR, token.Position{Filename: "main.ns", Offset: 0, Line: 1, Column: 1}, token.Position{Filename: "main.ns", Offset: 3, Line: 1, Column: 4}},
+		{token.IDENT, token.Position{Filename: "main.ns", Offset: 4, Line: 1, Column: 5}, token.Position{Filename: "main.ns", Offset: 5, Line: 1, Column: 6}},
+		{token.ASSIGN, token.Position{Filename: "main.ns", Offset: 6, Line: 1, Column: 7}, token.Position{Filename: "main.ns", Offset: 7, Line: 1, Column: 8}},
+		{token.INT, token.Position{Filename: "main.ns", Offset: 8, Line: 1, Column: 9}, token.Position{Filename: "main.ns", Offset: 9, Line: 1, Column: 10}},
+		{token.SEMICOLON, token.Position{Filename: "main.ns", Offset: 9, Line: 1, Column: 10}, token.Position{Filename: "main.ns", Offset: 10, Line: 1, Column: 11}},
+		{token.VAR, token.Position{Filename: "main.ns", Offset: 11, Line: 2, Column: 1}, token.Position{Filename: "main.ns", Offset: 14, Line: 2, Column: 4}},
+		{token.IDENT, token.Position{Filename: "main.ns", Offset: 15, Line: 2, Column: 5}, token.Position{Filename: "main.ns", Offset: 16, Line: 2, Column: 6}},
+		{token.ASSIGN, token.Position{Filename: "main.ns", Offset: 17, Line: 2, Column: 7}, token.Position{Filename: "main.ns", Offset: 18, Line: 2, Column: 8}},
+		{token.STRING, token.Position{Filename: "main.ns", Offset: 19, Line: 2, Column: 9}, token.Position{Filename: "main.ns", Offset: 27, Line: 2, Column: 16}},
+		{token.SEMICOLON, token.Position{Filename: "main.ns", Offset: 27, Line: 2, Column: 16}, token.Position{Filename: "main.ns", Offset: 28, Line: 2, Column: 17}},
+		{token.IDENT, token.Position{Filename: "main.ns", Offset: 29, Line: 2, Column: 18}, token.Position{Filename: "main.ns", Offset: 30, Line: 2, Column: 19}},
+		{token.EOF, token.Position{Filename: "main.ns", Offset: 31, Line: 3, Column: 1}, token.Position{Filename: "main.ns", Offset: 31, Line: 3, Column: 1}},
+	}
+
+	l := NewFile("main.ns", input)
+
+	for i, cas := range cases {
+		tok := l.NextToken()
+
+		if tok.Type != cas.tokenType {
+			t.Fatalf("test #%d failed, expected type [%s] but got [%s]", i, cas.tokenType, tok.Type)
+		}
+
+		if tok.Pos != cas.pos {
+			t.Fatalf("test #%d failed, expected position [%+v] but got [%+v]", i, cas.pos, tok.Pos)
+		}
+
+		if tok.End != cas.end {
+			t.Fatalf("test #%d failed, expected end position [%+v] but got [%+v]", i, cas.end, tok.End)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// the answer
+	var x = 42; // trailing
+	/* block /* nested */ still comment */ x / 2
+	/* unterminated`
+
+	cases := []struct {
+		tokenType    token.TokenType
+		tokenLiteral string
+	}{
+		{token.COMMENT, "// the answer"},
+		{token.VAR, "var"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "42"},
+		{token.SEMICOLON, ";"},
+		{token.COMMENT, "// trailing"},
+		{token.COMMENT, "/* block /* nested */ still comment */"},
+		{token.IDENT, "x"},
+		{token.SLASH, "/"},
+		{token.INT, "2"},
+		{token.ILLIGAL, "unterminated comment"},
+		{token.EOF, ""},
+	}
+
+	scanning := New(input)
+	scanning.SetMode(ScanComments)
+	skipping := New(input)
+
+	for i, cas := range cases {
+		tok := scanning.NextToken()
+
+		if tok.Type != cas.tokenType {
+			t.Fatalf("test #%d failed, expected type [%s] but got [%s]", i, cas.tokenType, tok.Type)
+		}
+
+		if tok.Literal != cas.tokenLiteral {
+			t.Fatalf("test #%d failed, expected literal [%s] but got [%s]", i, cas.tokenLiteral, tok.Literal)
+		}
+
+		if cas.tokenType == token.COMMENT {
+			continue
+		}
+
+		tok = skipping.NextToken()
+
+		if tok.Type != cas.tokenType || tok.Literal != cas.tokenLiteral {
+			t.Fatalf("test #%d failed, expected [%s %s] when skipping comments but got [%s %s]", i, cas.tokenType, cas.tokenLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	input := `10 3.14 .5 1e-9 2E+3 7e 1.x 0.25 user.name`
+
+	cases := []struct {
+		tokenType    token.TokenType
+		tokenLiteral string
+	}{
+		{token.INT, "10"},
+		{token.FLOAT, "3.14"},
+		{token.FLOAT, ".5"},
+		{token.FLOAT, "1e-9"},
+		{token.FLOAT, "2E+3"},
+		{token.INT, "7"},
+		{token.IDENT, "e"},
+		{token.INT, "1"},
+		{token.DOT, "."},
+		{token.IDENT, "x"},
+		{token.FLOAT, "0.25"},
+		{token.IDENT, "user"},
+		{token.DOT, "."},
+		{token.IDENT, "name"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, cas := range cases {
+		tok := l.NextToken()
+
+		if tok.Type != cas.tokenType {
+			t.Fatalf("test #%d failed, expected type [%s] but got [%s]", i, cas.tokenType, tok.Type)
+		}
+
+		if tok.Literal != cas.tokenLiteral {
+			t.Fatalf("test #%d failed, expected literal [%s] but got [%s]", i, cas.tokenLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestAssignmentOperators(t *testing.T) {
+	input := `const x = 10 % 3; x += 1; x -= 1; x *= 2; x /= 2; x %= 2;`
+
+	cases := []struct {
+		tokenType    token.TokenType
+		tokenLiteral string
+	}{
+		{token.CONST, "const"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "10"},
+		{token.PERCENT, "%"},
+		{token.INT, "3"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.PLUS_ASSIGN, "+="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.MINUS_ASSIGN, "-="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.ASTERISK_ASSIGN, "*="},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.SLASH_ASSIGN, "/="},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.PERCENT_ASSIGN, "%="},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, cas := range cases {
+		tok := l.NextToken()
+
+		if tok.Type != cas.tokenType {
+			t.Fatalf("test #%d failed, expected type [%s] but got [%s]", i, cas.tokenType, tok.Type)
+		}
+
+		if tok.Literal != cas.tokenLiteral {
+			t.Fatalf("test #%d failed, expected literal [%s] but got [%s]", i, cas.tokenLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestControlFlowKeywords(t *testing.T) {
+	input := `while for in break continue forever match case default`
+
+	expected := []token.TokenType{
+		token.WHILE,
+		token.FOR,
+		token.IN,
+		token.BREAK,
+		token.CONTINUE,
+		token.IDENT,
+		token.MATCH,
+		token.CASE,
+		token.DEFAULT,
+		token.EOF,
+	}
+
+	l := New(input)
+
+	for i, typ := range expected {
+		tok := l.NextToken()
+
+		if tok.Type != typ {
+			t.Fatalf("test #%d failed, expected type [%s] but got [%s]", i, typ, tok.Type)
+		}
+	}
+}
@@ -11,8 +11,9 @@ func NewEnclosedEnvironment(outer *Environment) *Environment {
 }
 
 type Environment struct {
-	Store map[string]Object
-	outer *Environment
+	Store  map[string]Object
+	consts map[string]bool // the names bound using const
+	outer  *Environment
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
@@ -35,3 +36,31 @@ func (e *Environment) Set(name string, value Object) Object {
 	e.Store[name] = value
 	return value
 }
+
+// SetConst binds the name to a value that can't be reassigned
+func (e *Environment) SetConst(name string, value Object) Object {
+	if e.consts == nil {
+		e.consts = make(map[string]bool)
+	}
+
+	e.consts[name] = true
+
+	return e.Set(name, value)
+}
+
+// IsConst reports whether the name is bound in this environment using SetConst
+func (e *Environment) IsConst(name string) bool {
+	return e.consts[name]
+}
+
+// Resolve returns the environment that defines the name, walking the enclosing
+// environments from the innermost one, it returns nil if the name is not defined.
+func (e *Environment) Resolve(name string) *Environment {
+	for env := e; env != nil; env = env.outer {
+		if env.Has(name) {
+			return env
+		}
+	}
+
+	return nil
+}
@@ -2,21 +2,31 @@ package object
 
 import (
 	"bytes"
+	"cmp"
 	"fmt"
 	"hash/fnv"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/yassinebenaid/nishimia/ast"
+	"github.com/yassinebenaid/nishimia/token"
 )
 
 type ObjectType string
 
 const (
 	INTEGER_OBJ          ObjectType = "INTEGER"
+	BIGINT_OBJ           ObjectType = "BIGINT"
+	FLOAT_OBJ            ObjectType = "FLOAT"
 	STRING_OBJ           ObjectType = "STRING"
 	BOOLEAN_OBJ          ObjectType = "BOOLEAN"
 	NULL_OBJ             ObjectType = "NULL"
 	RETURN_VALUE_OBJ     ObjectType = "RETURN_VALUE"
+	BREAK_OBJ            ObjectType = "BREAK"
+	CONTINUE_OBJ         ObjectType = "CONTINUE"
 	ERROR_OBJ            ObjectType = "ERROR"
 	FUNCTION_OBJ         ObjectType = "FUNCTION"
 	BUILTIN_FUNCTION_OBJ ObjectType = "BUILTIN_FUNCTION"
@@ -29,6 +39,12 @@ type Object interface {
 	Inspect() string
 }
 
+// Hashable is implemented by the objects that can be used as hash keys
+type Hashable interface {
+	Object
+	HashKey() HashKey
+}
+
 type Integer struct {
 	Value int64
 }
@@ -45,6 +61,46 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
+// BigInt is an integer that doesn't fit in 64 bits, integers that fit
+// in 64 bits are always represented by Integer.
+type BigInt struct {
+	Value *big.Int
+}
+
+func (b *BigInt) Inspect() string {
+	return b.Value.String()
+}
+
+func (b *BigInt) Type() ObjectType {
+	return BIGINT_OBJ
+}
+
+func (b *BigInt) HashKey() HashKey {
+	h := fnv.New64()
+	h.Write([]byte(b.Value.String()))
+	return HashKey{Type: b.Type(), Value: h.Sum64()}
+}
+
+type Float struct {
+	Value float64
+}
+
+// Inspect formats the float in the shortest form that reads back to the same value,
+// it always has a decimal point or an exponent so it can't be confused with an integer.
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+
+	if math.IsInf(f.Value, 0) || math.IsNaN(f.Value) || strings.ContainsAny(s, ".e") {
+		return s
+	}
+
+	return s + ".0"
+}
+
+func (f *Float) Type() ObjectType {
+	return FLOAT_OBJ
+}
+
 type String struct {
 	Value string
 }
@@ -106,15 +162,105 @@ func (r *ReturnValue) Inspect() string {
 	return r.Value.Inspect()
 }
 
+// Break is the signal produced by the break statement, it unwinds the
+// evaluation up to the loop it targets.
+type Break struct {
+	Label string // the label of the targeted loop, empty for the innermost loop
+}
+
+func (*Break) Type() ObjectType  { return BREAK_OBJ }
+func (b *Break) Inspect() string { return "break" }
+
+// Continue is the signal produced by the continue statement, it unwinds the
+// evaluation up to the loop it targets.
+type Continue struct {
+	Label string // the label of the targeted loop, empty for the innermost loop
+}
+
+func (*Continue) Type() ObjectType  { return CONTINUE_OBJ }
+func (c *Continue) Inspect() string { return "continue" }
+
 type Error struct {
 	Message string
+	Pos     token.Position // the start of the node that caused the error
+	End     token.Position // the end of the node that caused the error
+	GoStack string         // the Go stack trace when the error comes from an internal panic
+	Stack   []Frame        // the function calls the error propagated out of, the innermost first
+	Cause   error          // the Go error that stopped the execution, like the error of a cancelled context
 }
 
 func (*Error) Type() ObjectType { return ERROR_OBJ }
 func (e *Error) Inspect() string {
+	if e.Pos.IsValid() {
+		return "ERROR: " + e.Pos.String() + ": " + e.Message
+	}
+
 	return "ERROR: " + e.Message
 }
 
+// Error implements the error interface so the errors can be returned to Go code, the message
+// is prefixed by the position of the error when it is known.
+func (e *Error) Error() string {
+	if e.Pos.IsValid() {
+		return e.Pos.String() + ": " + e.Message
+	}
+
+	return e.Message
+}
+
+// Unwrap returns the Go error that caused the error, if any
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// StackTrace formats the call stack of the error, one call per line, only the innermost and outermost
+// calls of deep stacks are shown. It is empty if the error didn't happen in a function.
+func (e *Error) StackTrace() string {
+	const shown = 10
+
+	var lines []string
+
+	for i, frame := range e.Stack {
+		if len(e.Stack) > 2*shown && i >= shown && i < len(e.Stack)-shown {
+			if i == shown {
+				lines = append(lines, fmt.Sprintf("... %d more calls ...", len(e.Stack)-2*shown))
+			}
+
+			continue
+		}
+
+		lines = append(lines, "at "+frame.String())
+
+		if frame.Tail {
+			lines = append(lines, "(...tail calls...)")
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Frame is a function call on the call stack
+type Frame struct {
+	Function string         // the name the function is bound to, empty for anonymous functions
+	Pos      token.Position // the position of the call
+	Args     string         // a summary of the arguments
+	Tail     bool           // whether it is a tail call, the calls it replaced are not on the stack
+}
+
+func (f Frame) String() string {
+	name := f.Function
+	if name == "" {
+		name = "<anonymous>"
+	}
+
+	// the calls made from Go have no position
+	if !f.Pos.IsValid() {
+		return name + "(" + f.Args + ")"
+	}
+
+	return name + "(" + f.Args + ") " + f.Pos.String()
+}
+
 type Function struct {
 	Name   string
 	Params []*ast.Identifier
@@ -142,28 +288,55 @@ func (f *Function) Inspect() string {
 
 type BuiltinFunction func(...Object) Object
 
+// Runtime is the interpreter running a builtin function, the builtins use it to call back the functions of
+// the program they receive, like map does, and to account for the values they create before creating them.
+type Runtime interface {
+	// Call calls a function of the program with the arguments in the same evaluation, under the same limits.
+	// It returns the result of the call or the error it failed with.
+	Call(fn Object, args ...Object) Object
+
+	// Allocate accounts for a value with the given number of items and size in bytes the builtin is about
+	// to create, it returns an error once the budget or the memory limit of the evaluation is exceeded.
+	Allocate(items int, bytes int64) *Error
+}
+
+// RuntimeFunction is a builtin function using the interpreter running it
+type RuntimeFunction func(rt Runtime, args ...Object) Object
+
 type Builtin struct {
 	Fn BuiltinFunction
+
+	// Runtime is set for the builtins using the interpreter running them, the interpreters call it instead
+	// of Fn. These builtins account for the values they create, their results are not measured again.
+	Runtime RuntimeFunction
 }
 
 func (*Builtin) Type() ObjectType  { return FUNCTION_OBJ }
 func (f *Builtin) Inspect() string { return "builtin function" }
 
-type Array struct {
-	Items []Object
+// HostObject is implemented by the Go values exposed to the programs as objects with attributes
+// and methods, like user.name or req.header("X"). The attributes can be computed when they are read.
+// The errors returned by the methods make the evaluation fail with their message.
+type HostObject interface {
+	Object
+	GetAttr(name string) (Object, error)
+	SetAttr(name string, value Object) error
+	CallMethod(name string, args ...Object) (Object, error)
 }
 
-func (*Array) Type() ObjectType { return ARRAY_OBJ }
-func (a *Array) Inspect() string {
-	var items []string
-
-	for _, i := range a.Items {
-		items = append(items, i.Inspect())
-	}
+// Indexer is implemented by the host objects supporting the index operator like headers["Accept"]
+type Indexer interface {
+	HostObject
+	Index(index Object) (Object, error)
+}
 
-	return "[" + strings.Join(items, ", ") + "]"
+type Array struct {
+	Items []Object
 }
 
+func (*Array) Type() ObjectType  { return ARRAY_OBJ }
+func (a *Array) Inspect() string { return inspect(a, nil) }
+
 type HashPair struct {
 	Key   Object
 	Value Object
@@ -173,18 +346,89 @@ type Hash struct {
 	Items map[HashKey]HashPair
 }
 
-func (*Hash) Type() ObjectType { return ARRAY_OBJ }
-func (h *Hash) Inspect() string {
+func (*Hash) Type() ObjectType  { return HASH_OBJ }
+func (h *Hash) Inspect() string { return inspect(h, nil) }
+
+// inspect returns the representation of the object. The assignments can make an array or a hash contain
+// itself, the ones being inspected are printed as [...] and {...} when they are reached again.
+func inspect(obj Object, visiting map[Object]bool) string {
 	var items []string
 
-	for _, item := range h.Items {
-		items = append(items, item.Key.Inspect()+": "+item.Value.Inspect())
+	switch obj := obj.(type) {
+	case *Array:
+		if visiting[obj] {
+			return "[...]"
+		}
+
+		visiting = visit(visiting, obj)
+		defer delete(visiting, obj)
+
+		for _, item := range obj.Items {
+			items = append(items, inspect(item, visiting))
+		}
+
+		return "[" + strings.Join(items, ", ") + "]"
+	case *Hash:
+		if visiting[obj] {
+			return "{...}"
+		}
+
+		visiting = visit(visiting, obj)
+		defer delete(visiting, obj)
+
+		for _, pair := range obj.Pairs() {
+			items = append(items, inspect(pair.Key, visiting)+": "+inspect(pair.Value, visiting))
+		}
+
+		return "{" + strings.Join(items, ", ") + "}"
+	default:
+		return obj.Inspect()
 	}
+}
 
-	return "{" + strings.Join(items, ", ") + "}"
+func visit(visiting map[Object]bool, obj Object) map[Object]bool {
+	if visiting == nil {
+		visiting = make(map[Object]bool)
+	}
+
+	visiting[obj] = true
+	return visiting
 }
 
 type HashKey struct {
 	Type  ObjectType
 	Value uint64
 }
+
+// Pairs returns the pairs of the hash sorted by key, numbers and strings are sorted
+// by value, keys of different types are grouped by type.
+func (h *Hash) Pairs() []HashPair {
+	pairs := make([]HashPair, 0, len(h.Items))
+
+	for _, pair := range h.Items {
+		pairs = append(pairs, pair)
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		return compareKeys(pairs[i].Key, pairs[j].Key) < 0
+	})
+
+	return pairs
+}
+
+func compareKeys(a, b Object) int {
+	if a.Type() != b.Type() {
+		return strings.Compare(string(a.Type()), string(b.Type()))
+	}
+
+	switch a := a.(type) {
+	case *Integer:
+		return cmp.Compare(a.Value, b.(*Integer).Value)
+	case *BigInt:
+		return a.Value.Cmp(b.(*BigInt).Value)
+	case *String:
+		return strings.Compare(a.Value, b.(*String).Value)
+	default:
+		return strings.Compare(a.Inspect(), b.Inspect())
+	}
+}
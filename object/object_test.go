@@ -1,6 +1,14 @@
 package object
 
-import "testing"
+import (
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/yassinebenaid/nishimia/token"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "hello world"}
@@ -70,3 +78,98 @@ func TestBooleanHashKey(t *testing.T) {
 		t.Errorf("booleans with different content have the same hash keys")
 	}
 }
+
+func TestCyclicInspect(t *testing.T) {
+	arr := &Array{Items: []Object{&Integer{Value: 1}}}
+	hash := &Hash{Items: make(map[HashKey]HashPair)}
+
+	arr.Items = append(arr.Items, arr, hash)
+
+	key := &String{Value: "arr"}
+	hash.Items[key.HashKey()] = HashPair{Key: key, Value: arr}
+
+	self := &String{Value: "self"}
+	hash.Items[self.HashKey()] = HashPair{Key: self, Value: hash}
+
+	if got := arr.Inspect(); got != "[1, [...], {arr: [...], self: {...}}]" {
+		t.Errorf("wrong representation of the array. got=%s", got)
+	}
+
+	if got := hash.Inspect(); got != "{arr: [1, [...], {...}], self: {...}}" {
+		t.Errorf("wrong representation of the hash. got=%s", got)
+	}
+
+	// a value reached twice without a cycle is printed in full
+	shared := &Array{Items: []Object{&Integer{Value: 2}}}
+	if got := (&Array{Items: []Object{shared, shared}}).Inspect(); got != "[[2], [2]]" {
+		t.Errorf("wrong representation of the shared array. got=%s", got)
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := map[float64]string{
+		3.14:  "3.14",
+		5:     "5.0",
+		-2:    "-2.0",
+		1e-9:  "1e-09",
+		1e21:  "1e+21",
+		0.125: "0.125",
+	}
+
+	for value, expected := range tests {
+		if got := (&Float{Value: value}).Inspect(); got != expected {
+			t.Errorf("expected %v to be formatted as %q, got=%q", value, expected, got)
+		}
+	}
+}
+
+func TestBigIntHashKey(t *testing.T) {
+	big1, _ := new(big.Int).SetString("99999999999999999999", 10)
+	big2, _ := new(big.Int).SetString("99999999999999999999", 10)
+	big3, _ := new(big.Int).SetString("99999999999999999998", 10)
+
+	if (&BigInt{Value: big1}).HashKey() != (&BigInt{Value: big2}).HashKey() {
+		t.Errorf("big integers with same content have different hash keys")
+	}
+
+	if (&BigInt{Value: big1}).HashKey() == (&BigInt{Value: big3}).HashKey() {
+		t.Errorf("big integers with different content have the same hash keys")
+	}
+}
+
+func TestErrorInterface(t *testing.T) {
+	cause := errors.New("cancelled")
+
+	var err error = &Error{Message: "failure", Pos: token.Position{Line: 1, Column: 5}, Cause: cause}
+	if err.Error() != "1:5: failure" || !errors.Is(err, cause) {
+		t.Errorf("wrong error. got=%q", err.Error())
+	}
+
+	if err := (&Error{Message: "failure"}); err.Error() != "failure" {
+		t.Errorf("wrong error without position. got=%q", err.Error())
+	}
+}
+
+func TestStackTrace(t *testing.T) {
+	err := &Error{Message: "failure"}
+
+	for i := 0; i < 25; i++ {
+		err.Stack = append(err.Stack, Frame{Function: "f", Args: strconv.Itoa(i)})
+	}
+
+	err.Stack[0].Tail = true
+	err.Stack[1].Pos = token.Position{Line: 2, Column: 3}
+	lines := strings.Split(err.StackTrace(), "\n")
+
+	// the calls made from Go have no position
+	expected := []string{"at f(0)", "(...tail calls...)", "at f(1) 2:3"}
+	for i, line := range expected {
+		if lines[i] != line {
+			t.Errorf("wrong line %d. expected=%q, got=%q", i, line, lines[i])
+		}
+	}
+
+	if lines[11] != "... 5 more calls ..." || lines[len(lines)-1] != "at f(24)" {
+		t.Errorf("expected the middle calls to be elided, got=\n%s", err.StackTrace())
+	}
+}
@@ -6,7 +6,7 @@ import (
 )
 
 func (p *Parser) parseExpressionStatement() ast.Statement {
-	stat := &ast.ExpressionStatement{Token: p.currentToken}
+	stat := &ast.ExpressionStatement{Token: p.currentToken, Doc: p.leadingComments()}
 
 	stat.Expression = p.parseExpression(LOWEST)
 
@@ -11,6 +11,7 @@ import (
 const (
 	_ = iota
 	LOWEST
+	ASSIGN      // = , += etc.
 	LOGIC       // && , ||
 	EQUALS      // ==
 	LESSGREATER // < OR >
@@ -22,21 +23,42 @@ const (
 
 )
 
+// the tokens that start a new statement, used to synchronize after an error
+var statementKeywords = map[token.TokenType]bool{
+	token.VAR:      true,
+	token.CONST:    true,
+	token.RETURN:   true,
+	token.IF:       true,
+	token.MATCH:    true,
+	token.WHILE:    true,
+	token.FOR:      true,
+	token.BREAK:    true,
+	token.CONTINUE: true,
+}
+
 var precedences = map[token.TokenType]int{
-	token.AND:      LOGIC,
-	token.OR:       LOGIC,
-	token.EQUAL:    EQUALS,
-	token.NOTEQU:   EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.GTEQUAL:  LESSGREATER,
-	token.LTEQUAL:  LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.LBRACKET: CALL,
-	token.LPARENT:  INDEX,
+	token.ASSIGN:          ASSIGN,
+	token.PLUS_ASSIGN:     ASSIGN,
+	token.MINUS_ASSIGN:    ASSIGN,
+	token.ASTERISK_ASSIGN: ASSIGN,
+	token.SLASH_ASSIGN:    ASSIGN,
+	token.PERCENT_ASSIGN:  ASSIGN,
+	token.AND:             LOGIC,
+	token.OR:              LOGIC,
+	token.EQUAL:           EQUALS,
+	token.NOTEQU:          EQUALS,
+	token.LT:              LESSGREATER,
+	token.GT:              LESSGREATER,
+	token.GTEQUAL:         LESSGREATER,
+	token.LTEQUAL:         LESSGREATER,
+	token.PLUS:            SUM,
+	token.MINUS:           SUM,
+	token.SLASH:           PRODUCT,
+	token.PERCENT:         PRODUCT,
+	token.ASTERISK:        PRODUCT,
+	token.LBRACKET:        CALL,
+	token.LPARENT:         INDEX,
+	token.DOT:             INDEX,
 }
 
 type Parser struct {
@@ -45,7 +67,13 @@ type Parser struct {
 	currentToken token.Token // refers to the current token under examination
 	peekToken    token.Token // refers to the next token after currentToken
 
-	errors []string // holds all parsing errors
+	comments []*ast.Comment // all the comments read so far
+	pending  []*ast.Comment // the comments not attached to a statement yet
+
+	errors    []*Error // holds all parsing errors
+	panicking bool     // set after an error, until the parser is synchronized to the next statement
+
+	loops []string // the labels of the loops enclosing the current token, empty for unlabeled loops
 
 	prefixPareseFns map[token.TokenType]prefixParseFn
 	infixPareseFns  map[token.TokenType]infixParseFn
@@ -56,12 +84,24 @@ type (
 	infixParseFn  func(ast.Expression) ast.Expression
 )
 
+// Error describes a syntax error found while parsing, it spans the offending token.
+type Error struct {
+	Pos token.Position
+	End token.Position
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return e.Pos.String() + ": " + e.Msg
+}
+
 func New(l *lexer.Lexer) *Parser {
 	p := &Parser{lex: l}
 
 	p.prefixPareseFns = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseInteger)
+	p.registerPrefix(token.FLOAT, p.parseFloat)
 	p.registerPrefix(token.STRING, p.parseString)
 	p.registerPrefix(token.BANG, p.parsePrefixExpressions)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpressions)
@@ -72,6 +112,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.LBRACKET, p.parseArrayExpression)
 	p.registerPrefix(token.LBRACE, p.parseHashExpression)
 	p.registerPrefix(token.IF, p.parseIfElseExpression)
+	p.registerPrefix(token.MATCH, p.parseMatchExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionExpression)
 
 	p.infixPareseFns = make(map[token.TokenType]infixParseFn)
@@ -79,6 +120,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.MINUS, p.parseInfixExpressions)
 	p.registerInfix(token.ASTERISK, p.parseInfixExpressions)
 	p.registerInfix(token.SLASH, p.parseInfixExpressions)
+	p.registerInfix(token.PERCENT, p.parseInfixExpressions)
 	p.registerInfix(token.EQUAL, p.parseInfixExpressions)
 	p.registerInfix(token.NOTEQU, p.parseInfixExpressions)
 	p.registerInfix(token.AND, p.parseInfixExpressions)
@@ -88,8 +130,16 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.GTEQUAL, p.parseInfixExpressions)
 	p.registerInfix(token.LTEQUAL, p.parseInfixExpressions)
 
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PLUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.MINUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.ASTERISK_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.SLASH_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PERCENT_ASSIGN, p.parseAssignExpression)
+
 	p.registerInfix(token.LPARENT, p.parseFunctionCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseArrayIndexExpression)
+	p.registerInfix(token.DOT, p.parseMemberExpression)
 
 	p.nextToken()
 	p.nextToken()
@@ -100,6 +150,46 @@ func New(l *lexer.Lexer) *Parser {
 func (p *Parser) nextToken() {
 	p.currentToken = p.peekToken
 	p.peekToken = p.lex.NextToken()
+
+	for p.peekTokenIs(token.COMMENT) {
+		comment := &ast.Comment{Token: p.peekToken}
+		p.comments = append(p.comments, comment)
+
+		// comments on the same line as the previous token are trailing comments,
+		// they never document the next statement.
+		if comment.Pos().Line > p.currentToken.End.Line {
+			p.pending = append(p.pending, comment)
+		}
+
+		p.peekToken = p.lex.NextToken()
+	}
+}
+
+// leadingComments returns the group of comments that ends right before the current token,
+// the pending comments before the current token are dropped whether they are part of the group or not.
+func (p *Parser) leadingComments() *ast.CommentGroup {
+	var before []*ast.Comment
+	var i int
+
+	for i = 0; i < len(p.pending) && p.pending[i].End().Offset <= p.currentToken.Pos.Offset; i++ {
+		before = append(before, p.pending[i])
+	}
+
+	p.pending = p.pending[i:]
+
+	line := p.currentToken.Pos.Line
+	start := len(before)
+
+	for start > 0 && before[start-1].End().Line >= line-1 {
+		start--
+		line = before[start].Pos().Line
+	}
+
+	if start == len(before) {
+		return nil
+	}
+
+	return &ast.CommentGroup{List: before[start:]}
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
@@ -112,23 +202,79 @@ func (p *Parser) ParseProgram() *ast.Program {
 		}
 	}
 
+	prog.Comments = p.comments
+
 	return prog
 }
 
 func (p *Parser) parseStatement() ast.Statement {
+	start := p.currentToken
+	recovering := p.panicking // an enclosing statement failed already, it will do the recovery
+	stat := p.parseStatementKind()
+
+	if p.panicking && !recovering {
+		p.synchronize()
+		p.panicking = false
+
+		return &ast.BadStatement{Token: start, Last: p.currentToken}
+	}
+
+	return stat
+}
+
+func (p *Parser) parseStatementKind() ast.Statement {
 	switch p.currentToken.Type {
-	case token.VAR:
+	case token.VAR, token.CONST:
 		return p.parseVarBindingStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.WHILE:
+		return p.parseWhileStatement(nil, p.leadingComments())
+	case token.FOR:
+		return p.parseForStatement(nil, p.leadingComments())
+	case token.BREAK, token.CONTINUE:
+		return p.parseBranchStatement()
+	case token.IDENT:
+		if p.peekTokenIs(token.COLON) {
+			return p.parseLabeledStatement()
+		}
+
+		return p.parseExpressionStatement()
 	case token.ILLIGAL:
-		p.errors = append(p.errors, "Illigal token : "+p.currentToken.Literal)
+		p.errorAt(p.currentToken, "Illigal token : %s", p.currentToken.Literal)
 		return nil
 	default:
 		return p.parseExpressionStatement()
 	}
 }
 
+// synchronize skips the tokens of a statement that failed to parse, it stops at
+// the semicolon ending the statement, or before a token that starts a new statement
+// or closes the enclosing block. Braces opened while skipping are skipped as a whole.
+func (p *Parser) synchronize() {
+	depth := 0
+
+	for !p.currentTokenIs(token.EOF) {
+		if p.currentTokenIs(token.LBRACE) {
+			depth++
+		} else if p.currentTokenIs(token.RBRACE) && depth > 0 {
+			depth--
+		}
+
+		if depth == 0 {
+			if p.currentTokenIs(token.SEMICOLON) {
+				return
+			}
+
+			if p.peekTokenIs(token.RBRACE) || p.peekTokenIs(token.EOF) || statementKeywords[p.peekToken.Type] {
+				return
+			}
+		}
+
+		p.nextToken()
+	}
+}
+
 func (p *Parser) expectPeek(t token.TokenType) bool {
 	if p.peekTokenIs(t) {
 		p.nextToken()
@@ -148,15 +294,36 @@ func (p *Parser) currentTokenIs(t token.TokenType) bool {
 	return t == p.currentToken.Type
 }
 
-func (p *Parser) Errors() []string {
+func (p *Parser) Errors() []*Error {
 	return p.errors
 }
 
+// errorAt records an error at the given token, errors reported while the parser
+// is recovering from a previous one are dropped since they are most likely caused by it.
+func (p *Parser) errorAt(tok token.Token, msg string, args ...any) {
+	p.errorSpan(tok.Pos, tok.End, msg, args...)
+}
+
+// errorAtNode is like errorAt, but the error spans the whole node.
+func (p *Parser) errorAtNode(node ast.Node, msg string, args ...any) {
+	p.errorSpan(node.Pos(), node.End(), msg, args...)
+}
+
+func (p *Parser) errorSpan(pos, end token.Position, msg string, args ...any) {
+	if p.panicking {
+		return
+	}
+
+	p.panicking = true
+	p.errors = append(p.errors, &Error{
+		Pos: pos,
+		End: end,
+		Msg: fmt.Sprintf(msg, args...),
+	})
+}
+
 func (p *Parser) peekError(t token.TokenType) {
-	p.errors = append(
-		p.errors,
-		fmt.Sprintf(`unexpected token  "%s" , expected "%s"`, p.peekToken.Literal, t),
-	)
+	p.errorAt(p.peekToken, `unexpected token  "%s" , expected "%s"`, p.peekToken.Literal, t)
 }
 
 func (p *Parser) registerPrefix(t token.TokenType, fn prefixParseFn) {
@@ -168,7 +335,7 @@ func (p *Parser) registerInfix(t token.TokenType, fn infixParseFn) {
 }
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	p.errors = append(p.errors, fmt.Sprintf("no prefix parse function for %s found", t))
+	p.errorAt(p.currentToken, "no prefix parse function for %s found", t)
 }
 
 func (p *Parser) peekPrecedence() int {
@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"slices"
 	"testing"
 
 	"github.com/yassinebenaid/nishimia/ast"
@@ -217,6 +218,61 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestBigIntegerLiteralExpression(t *testing.T) {
+	par := New(lexer.New("123456789012345678901234567890;"))
+	program := par.ParseProgram()
+	checkParserErrors(t, par)
+
+	stat, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("statement type is incorrect, expected ExpressionStatement, got=%T", program.Statements[0])
+	}
+
+	integer, ok := stat.Expression.(*ast.BigIntegerLiteral)
+	if !ok {
+		t.Fatalf("expression type is incorrect, expected BigIntegerLiteral, got=%T", stat.Expression)
+	}
+
+	if integer.Value.String() != "123456789012345678901234567890" {
+		t.Fatalf("expected value to be 123456789012345678901234567890, got=%s", integer.Value)
+	}
+}
+
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14;", 3.14},
+		{".5;", 0.5},
+		{"1e-9;", 1e-9},
+	}
+
+	for _, tt := range tests {
+		par := New(lexer.New(tt.input))
+		program := par.ParseProgram()
+		checkParserErrors(t, par)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("expected 1 statement, got=%d", len(program.Statements))
+		}
+
+		stat, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("statement type is incorrect, expected ExpressionStatement, got=%T", program.Statements[0])
+		}
+
+		float, ok := stat.Expression.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("expression type is incorrect, expected FloatLiteral, got=%T", stat.Expression)
+		}
+
+		if float.Value != tt.expected {
+			t.Fatalf("expected value to be %v, got=%v", tt.expected, float.Value)
+		}
+	}
+}
+
 func TestStringLiteralExpression(t *testing.T) {
 	input := `"hello world";`
 	lex := lexer.New(input)
@@ -357,6 +413,9 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		{"add(a + b + c * d / f + g)", "add((((a + b) + ((c * d) / f)) + g))"},
 
 		{"add(a[0] + b[2] + c[3] * d[4] / f[5] + g[6])", "add((((a[0] + b[2]) + ((c[3] * d[4]) / f[5])) + g[6]))"},
+
+		{"-a.b * c.d(1)[2]", "((-a.b) * c.d(1)[2])"},
+		{"a[0].b.c(x.y)", "a[0].b.c(x.y)"},
 	}
 
 	for _, tt := range tests {
@@ -850,6 +909,574 @@ func TestEmptyHashLiteralParsing(t *testing.T) {
 	}
 }
 
+func TestConstStatement(t *testing.T) {
+	par := New(lexer.New("const pi = 3.14;"))
+	program := par.ParseProgram()
+	checkParserErrors(t, par)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("expected 1 statement, got=%d", len(program.Statements))
+	}
+
+	stat, ok := program.Statements[0].(*ast.VarStatement)
+	if !ok {
+		t.Fatalf("statement type is incorrect, expected VarStatement, got=%T", program.Statements[0])
+	}
+
+	if stat.Token.Type != token.CONST || stat.Name.Value != "pi" {
+		t.Fatalf("expected a constant named pi, got=%s", stat.String())
+	}
+
+	if stat.String() != "const pi = 3.14;" {
+		t.Fatalf("expected const pi = 3.14;, got=%s", stat.String())
+	}
+}
+
+func TestAssignExpressionParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x = 5", "(x = 5)"},
+		{"x = y = 5", "(x = (y = 5))"},
+		{"x += 1 + 2 * 3", "(x += (1 + (2 * 3)))"},
+		{"x -= y || z", "(x -= (y || z))"},
+		{"arr[0] *= 2", "(arr[0] *= 2)"},
+		{`hash["key"] /= 2`, `(hash[key] /= 2)`},
+		{"x %= 10 % 3", "(x %= (10 % 3))"},
+		{"f(x = 1)", "f((x = 1))"},
+		{"user.name = 1", "(user.name = 1)"},
+		{"req.headers.count += 1", "(req.headers.count += 1)"},
+	}
+
+	for _, tt := range tests {
+		par := New(lexer.New(tt.input))
+		program := par.ParseProgram()
+		checkParserErrors(t, par)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	invalid := []struct {
+		input    string
+		expected string
+	}{
+		{"1 = 2", "1:3: cannot assign to this expression"},
+		{"a + b = 2", "1:7: cannot assign to this expression"},
+		{"f() += 2", "1:5: cannot assign to this expression"},
+	}
+
+	for _, tt := range invalid {
+		par := New(lexer.New(tt.input))
+		par.ParseProgram()
+
+		if len(par.Errors()) != 1 {
+			t.Fatalf("expected 1 error for %q, got=%d", tt.input, len(par.Errors()))
+		}
+
+		if par.Errors()[0].Error() != tt.expected {
+			t.Errorf("expected error=%q, got=%q", tt.expected, par.Errors()[0].Error())
+		}
+	}
+
+	// the targets of these assignments and the patterns are partly parsed, they used to crash the parser
+	for _, input := range []string{"f(-) = 1", "- . =", "! else =", "[ / ] => =>", "match 5 { case 1 + { 1 } }"} {
+		par := New(lexer.New(input))
+		par.ParseProgram()
+
+		if len(par.Errors()) == 0 {
+			t.Errorf("expected errors for %q", input)
+		}
+	}
+}
+
+func TestLoopStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"while x < 10 { x += 1; }", "while (x < 10) {(x += 1)}"},
+		{"for var i = 0; i < 10; i += 1 { f(i); }", "for var i = 0; (i < 10); (i += 1) {f(i)}"},
+		{"for i = 0; i < 10; i += 1 {}", "for (i = 0); (i < 10); (i += 1) {}"},
+		{"for ;; {}", "for ; ; {}"},
+		{"for ; x; {}", "for ; x; {}"},
+		{"for x in [1, 2] { f(x); }", "for x in [1, 2] {f(x)}"},
+		{"for k, v in h { f(k, v); }", "for k, v in h {f(k, v)}"},
+		{"while true { break; }", "while true {break;}"},
+		{"while true { continue; }", "while true {continue;}"},
+		{"outer: while true { for x in xs { continue outer; } }", "outer: while true {for x in xs {continue outer;}}"},
+		{"a: for ;; { b: for ;; { break a; } }", "a: for ; ; {b: for ; ; {break a;}}"},
+	}
+
+	for _, tt := range tests {
+		par := New(lexer.New(tt.input))
+		program := par.ParseProgram()
+		checkParserErrors(t, par)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("expected 1 statement for %q, got=%d", tt.input, len(program.Statements))
+		}
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestForInStatement(t *testing.T) {
+	par := New(lexer.New("for key, value in hash { }"))
+	program := par.ParseProgram()
+	checkParserErrors(t, par)
+
+	stat, ok := program.Statements[0].(*ast.ForInStatement)
+	if !ok {
+		t.Fatalf("statement type is incorrect, expected ForInStatement, got=%T", program.Statements[0])
+	}
+
+	if !testIdentifierLiteral(t, stat.Key, "key") || !testIdentifierLiteral(t, stat.Value, "value") {
+		return
+	}
+
+	testIdentifierLiteral(t, stat.Iterable, "hash")
+
+	par = New(lexer.New("for item in items { }"))
+	program = par.ParseProgram()
+	checkParserErrors(t, par)
+
+	stat = program.Statements[0].(*ast.ForInStatement)
+
+	if stat.Value != nil {
+		t.Errorf("expected no value variable, got=%s", stat.Value)
+	}
+}
+
+func TestLoopErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"break;", "1:1: break is not in a loop"},
+		{"if true { continue; }", "1:11: continue is not in a loop"},
+		{"while true { var f = func() { break; }; }", "1:31: break is not in a loop"},
+		{"while true { break outer; }", "1:20: undefined label outer"},
+		{"a: while true { a: while true {} }", "1:17: label a already defined"},
+		{"a: var x = 1;", "1:4: label a must be followed by a loop"},
+		{"while true { break }", `1:20: unexpected token  "}" , expected ";"`},
+		{"for x, in xs {}", `1:8: unexpected token  "in" , expected "IDENT"`},
+		{"for var i = 0; i < 1 {}", `1:22: unexpected token  "{" , expected ";"`},
+	}
+
+	for _, tt := range tests {
+		par := New(lexer.New(tt.input))
+		par.ParseProgram()
+
+		if len(par.Errors()) == 0 {
+			t.Errorf("expected an error for %q", tt.input)
+			continue
+		}
+
+		if par.Errors()[0].Error() != tt.expected {
+			t.Errorf("expected error=%q, got=%q", tt.expected, par.Errors()[0].Error())
+		}
+	}
+}
+
+func TestElseIfParsing(t *testing.T) {
+	input := `if x < 0 { -1 } else if x == 0 { 0 } else if x < 10 { 1 } else { 2 }`
+
+	par := New(lexer.New(input))
+	program := par.ParseProgram()
+	checkParserErrors(t, par)
+
+	expected := "if(x < 0) {(-1)} else {if(x == 0) {0} else {if(x < 10) {1} else {2}}}"
+	if program.String() != expected {
+		t.Fatalf("expected=%q, got=%q", expected, program.String())
+	}
+
+	exp := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IfElseExpression)
+
+	nested, ok := exp.Alternative.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IfElseExpression)
+	if !ok {
+		t.Fatalf("expected the alternative to hold an IfElseExpression, got=%T", exp.Alternative.Statements[0])
+	}
+
+	testInfixExpression(t, nested.Condition, "x", "==", 0)
+
+	if exp.End() != program.End() || exp.End().Offset != len(input) {
+		t.Errorf("expected the chain to end at the last brace, got=%s", exp.End())
+	}
+}
+
+func TestMatchExpressionParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"match x { case 1 { a } }", "match x {case 1 {a}}"},
+		{"match x { case -1.5 { a } default { b } }", "match x {case (-1.5) {a} default {b}}"},
+		{`match x { case "a" { 1 } case true { 2 } case _ { 3 } }`, "match x {case a {1} case true {2} case _ {3}}"},
+		{"match x { case [a, [b, _]] if a > b { a } }", "match x {case [a, [b, _]] if (a > b) {a}}"},
+		{`match f(x) { case {"k": v} { v } }`, "match f(x) {case {k: v} {v}}"},
+		{"match x { default { 1 } case 1 { 2 } }", "match x {default {1} case 1 {2}}"},
+		{"match x { }", "match x {}"},
+		{"var y = match x { case 1 { 2 } };", "var y = match x {case 1 {2}};"},
+	}
+
+	for _, tt := range tests {
+		par := New(lexer.New(tt.input))
+		program := par.ParseProgram()
+		checkParserErrors(t, par)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	par := New(lexer.New("match x { case n if n > 0 { n } default { 0 } }"))
+	program := par.ParseProgram()
+	checkParserErrors(t, par)
+
+	exp, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.MatchExpression)
+	if !ok {
+		t.Fatalf("expected MatchExpression, got=%T", program.Statements[0].(*ast.ExpressionStatement).Expression)
+	}
+
+	if len(exp.Arms) != 2 {
+		t.Fatalf("expected 2 arms, got=%d", len(exp.Arms))
+	}
+
+	testIdentifierLiteral(t, exp.Arms[0].Pattern, "n")
+	testInfixExpression(t, exp.Arms[0].Guard, "n", ">", 0)
+
+	if exp.Arms[1].Pattern != nil || exp.Arms[1].Guard != nil {
+		t.Errorf("expected the default arm to have no pattern nor guard")
+	}
+}
+
+func TestMatchErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"match x { case a + 1 { } }", "1:16: invalid pattern (a + 1)"},
+		{"match x { case f(a) { } }", "1:16: invalid pattern f(a)"},
+		{"match x { case [1, -a] { } }", "1:20: invalid pattern (-a)"},
+		{"match x { case {k: 1} { } }", "1:17: invalid hash pattern key k, keys must be literals"},
+		{"match x { default { } default { } }", "1:23: multiple defaults in match"},
+		{"match x { 1 { } }", `1:11: unexpected token  "1" , expected "case" or "default"`},
+		{"match x { case 1 }", `1:18: unexpected token  "}" , expected "{"`},
+		{"match x { case 1 { }", `1:21: unexpected token  "" , expected "case" or "default"`},
+		{"if x { } else if y x", `1:20: unexpected token  "x" , expected "{"`},
+		// the pattern is partly parsed, it used to crash the parser
+		{"match 5 { case 1 + { 1 } }", `1:24: unexpected token  "}" , expected ":"`},
+	}
+
+	for _, tt := range tests {
+		par := New(lexer.New(tt.input))
+		par.ParseProgram()
+
+		if len(par.Errors()) == 0 {
+			t.Errorf("expected an error for %q", tt.input)
+			continue
+		}
+
+		if par.Errors()[0].Error() != tt.expected {
+			t.Errorf("expected error=%q, got=%q", tt.expected, par.Errors()[0].Error())
+		}
+	}
+}
+
+func TestMatchErrorRecovery(t *testing.T) {
+	// the parsing resumes at the next arm, the closing brace of the match is not reported again
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"match 1 { default { 1 } default { 2 } }", []string{"1:25: multiple defaults in match"}},
+		{"match 1 { case 1 + 1 { 1 } }", []string{"1:16: invalid pattern (1 + 1)"}},
+		{"match 1 { case x { 1 } 5 case y { 2 } }", []string{`1:24: unexpected token  "5" , expected "case" or "default"`}},
+		{"match 1 { case f(x) { 1 } case [a, -b] { 2 } }", []string{"1:16: invalid pattern f(x)", "1:36: invalid pattern (-b)"}},
+		{"var f = func() { match 1 { case 1 + 1 { } default { } } }; var x = ;", []string{"1:33: invalid pattern (1 + 1)", "1:68: no prefix parse function for ; found"}},
+	}
+
+	for _, tt := range tests {
+		par := New(lexer.New(tt.input))
+		par.ParseProgram()
+
+		var errs []string
+		for _, err := range par.Errors() {
+			errs = append(errs, err.Error())
+		}
+
+		if !slices.Equal(errs, tt.expected) {
+			t.Errorf("wrong errors for %q. expected=%q, got=%q", tt.input, tt.expected, errs)
+		}
+	}
+
+	program := New(lexer.New("match 1 { case 1 + 1 { 1 } default { 2 } }; var x = 1;")).ParseProgram()
+	if len(program.Statements) != 2 {
+		t.Fatalf("expected the statements after the match to be parsed, got=%d statements", len(program.Statements))
+	}
+
+	if arms := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.MatchExpression).Arms; len(arms) != 1 {
+		t.Errorf("expected the valid arm to be kept, got=%d arms", len(arms))
+	}
+}
+
+func TestParserErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"var = 5;", `main.ns:1:5: unexpected token  "=" , expected "IDENT"`},
+		{"var x 5;", `main.ns:1:7: unexpected token  "5" , expected "="`},
+		{"\n\n  return ;", `main.ns:3:10: no prefix parse function for ; found`},
+		{"var x = 1;\n  &", `main.ns:2:3: Illigal token : &`},
+		{"user.if", `main.ns:1:6: unexpected token  "if" , expected "IDENT"`},
+	}
+
+	for _, tt := range tests {
+		par := New(lexer.NewFile("main.ns", tt.input))
+		par.ParseProgram()
+
+		if len(par.Errors()) == 0 {
+			t.Fatalf("expected parser errors for %q, got none", tt.input)
+		}
+
+		if err := par.Errors()[0].Error(); err != tt.expected {
+			t.Fatalf("expected error to be %q, got=%q", tt.expected, err)
+		}
+	}
+}
+
+func TestParserErrorRecovery(t *testing.T) {
+	input := `
+	var = 5;
+	var x = 10;
+	var y = (x + ;
+	var add = func(a, 1) { return a; };
+	var f = func() {
+		var = 1;
+		return x;
+	};
+	if (x { x }
+	return x;
+	`
+
+	par := New(lexer.New(input))
+	program := par.ParseProgram()
+
+	expectedErrors := []string{
+		`2:6: unexpected token  "=" , expected "IDENT"`,
+		`4:15: no prefix parse function for ; found`,
+		`5:20: unexpected token  "1" , expected "IDENT"`,
+		`7:7: unexpected token  "=" , expected "IDENT"`,
+		`10:8: unexpected token  "{" , expected ")"`,
+	}
+
+	if len(par.Errors()) != len(expectedErrors) {
+		t.Errorf("expected %d errors, got=%d", len(expectedErrors), len(par.Errors()))
+	}
+
+	for i, err := range par.Errors() {
+		if i < len(expectedErrors) && err.Error() != expectedErrors[i] {
+			t.Errorf("expected error #%d to be %q, got=%q", i, expectedErrors[i], err.Error())
+		}
+	}
+
+	expectedStatements := []string{
+		"<bad statement>",
+		"var x = 10;",
+		"<bad statement>",
+		"<bad statement>",
+		"var f = func(){<bad statement>return x;};",
+		"<bad statement>",
+		"return x;",
+	}
+
+	if len(program.Statements) != len(expectedStatements) {
+		t.Fatalf("expected %d statements, got=%d", len(expectedStatements), len(program.Statements))
+	}
+
+	for i, stat := range program.Statements {
+		if stat.String() != expectedStatements[i] {
+			t.Errorf("expected statement #%d to be %q, got=%q", i, expectedStatements[i], stat.String())
+		}
+	}
+
+	bad, ok := program.Statements[0].(*ast.BadStatement)
+	if !ok {
+		t.Fatalf("expected statement #0 to be BadStatement, got=%T", program.Statements[0])
+	}
+
+	if bad.Pos().String() != "2:2" || bad.End().String() != "2:10" {
+		t.Errorf("expected bad statement to span 2:2 - 2:10, got=%s - %s", bad.Pos(), bad.End())
+	}
+}
+
+func TestUnterminatedBlock(t *testing.T) {
+	par := New(lexer.New("if x { 1"))
+	par.ParseProgram()
+
+	if len(par.Errors()) != 1 {
+		t.Fatalf("expected 1 error, got=%d", len(par.Errors()))
+	}
+
+	if err := par.Errors()[0].Error(); err != `1:9: unexpected token  "" , expected "}"` {
+		t.Fatalf("unexpected error message, got=%q", err)
+	}
+}
+
+func TestCommentsAttachment(t *testing.T) {
+	input := `// header comment
+
+	// documents a
+	/* and continues here */
+	var a = 1; // trailing comment of a
+
+	var f = func() {
+		// documents the return
+		return a;
+	};
+
+	/* documents the call */ f();
+	`
+
+	lex := lexer.New(input)
+	lex.SetMode(lexer.ScanComments)
+	par := New(lex)
+	program := par.ParseProgram()
+	checkParserErrors(t, par)
+
+	if len(program.Statements) != 3 {
+		t.Fatalf("expected 3 statements, got=%d", len(program.Statements))
+	}
+
+	if len(program.Comments) != 6 {
+		t.Fatalf("expected 6 comments, got=%d", len(program.Comments))
+	}
+
+	a := program.Statements[0].(*ast.VarStatement)
+	if a.Doc == nil || a.Doc.Text() != "documents a\nand continues here" {
+		t.Errorf("wrong documentation of a, got=%+v", a.Doc)
+	}
+
+	f := program.Statements[1].(*ast.VarStatement)
+	if f.Doc != nil {
+		t.Errorf("expected f to have no documentation, got=%q", f.Doc.Text())
+	}
+
+	ret := f.Value.(*ast.FunctionLiteral).Body.Statements[0].(*ast.ReturnStatement)
+	if ret.Doc == nil || ret.Doc.Text() != "documents the return" {
+		t.Errorf("wrong documentation of the return statement, got=%+v", ret.Doc)
+	}
+
+	call := program.Statements[2].(*ast.ExpressionStatement)
+	if call.Doc == nil || call.Doc.String() != "/* documents the call */" {
+		t.Errorf("wrong documentation of the call, got=%+v", call.Doc)
+	}
+}
+
+func TestLoopCommentsAttachment(t *testing.T) {
+	input := `// documents the while
+	while true {
+		// documents the break
+		break;
+	}
+
+	// documents the labeled for
+	outer:
+	for var i = 0; i < 3; i += 1 {
+		/* documents the continue */ continue outer;
+	}
+
+	// documents the for in
+	for x in [1] { }
+
+	for x in [1] { }
+	`
+
+	lex := lexer.New(input)
+	lex.SetMode(lexer.ScanComments)
+	par := New(lex)
+	program := par.ParseProgram()
+	checkParserErrors(t, par)
+
+	if len(program.Statements) != 4 {
+		t.Fatalf("expected 4 statements, got=%d", len(program.Statements))
+	}
+
+	while := program.Statements[0].(*ast.WhileStatement)
+	if while.Doc == nil || while.Doc.Text() != "documents the while" {
+		t.Errorf("wrong documentation of the while loop, got=%+v", while.Doc)
+	}
+
+	brk := while.Body.Statements[0].(*ast.BranchStatement)
+	if brk.Doc == nil || brk.Doc.Text() != "documents the break" {
+		t.Errorf("wrong documentation of the break statement, got=%+v", brk.Doc)
+	}
+
+	loop := program.Statements[1].(*ast.ForStatement)
+	if loop.Doc == nil || loop.Doc.Text() != "documents the labeled for" {
+		t.Errorf("wrong documentation of the for loop, got=%+v", loop.Doc)
+	}
+
+	cont := loop.Body.Statements[0].(*ast.BranchStatement)
+	if cont.Doc == nil || cont.Doc.String() != "/* documents the continue */" {
+		t.Errorf("wrong documentation of the continue statement, got=%+v", cont.Doc)
+	}
+
+	forIn := program.Statements[2].(*ast.ForInStatement)
+	if forIn.Doc == nil || forIn.Doc.Text() != "documents the for in" {
+		t.Errorf("wrong documentation of the for in loop, got=%+v", forIn.Doc)
+	}
+
+	if undocumented := program.Statements[3].(*ast.ForInStatement); undocumented.Doc != nil {
+		t.Errorf("expected the last loop to have no documentation, got=%q", undocumented.Doc.Text())
+	}
+}
+
+func TestNodePositions(t *testing.T) {
+	tests := []struct {
+		input string
+		pos   string
+		end   string
+	}{
+		{"foobar;", "1:1", "1:7"},
+		{"  1 + 2 * 3", "1:3", "1:12"},
+		{"-a", "1:1", "1:3"},
+		{"add(1,\n 2)", "1:1", "2:4"},
+		{"[1, 2][0]", "1:1", "1:10"},
+		{`{"a": 1}`, "1:1", "1:9"},
+		{"if x {\n 1 \n} else {\n 2 }", "1:1", "4:5"},
+		{"func(x) { x }", "1:1", "1:14"},
+		{"var x = 10;", "1:1", "1:11"},
+		{"return x;", "1:1", "1:9"},
+	}
+
+	for _, tt := range tests {
+		par := New(lexer.New(tt.input))
+		program := par.ParseProgram()
+		checkParserErrors(t, par)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("expected 1 statement, got=%d", len(program.Statements))
+		}
+
+		stat := program.Statements[0]
+
+		if stat.Pos().String() != tt.pos {
+			t.Errorf("expected %q to start at %s, got=%s", tt.input, tt.pos, stat.Pos())
+		}
+
+		if stat.End().String() != tt.end {
+			t.Errorf("expected %q to end at %s, got=%s", tt.input, tt.end, stat.End())
+		}
+	}
+}
+
 func checkParserErrors(t *testing.T, p *Parser) {
 	if len(p.errors) == 0 {
 		return
@@ -1,7 +1,9 @@
 package parser
 
 import (
-	"fmt"
+	"errors"
+	"math/big"
+	"slices"
 	"strconv"
 
 	"github.com/yassinebenaid/nishimia/ast"
@@ -9,7 +11,7 @@ import (
 )
 
 func (p *Parser) parseVarBindingStatement() ast.Statement {
-	stat := &ast.VarStatement{Token: p.currentToken}
+	stat := &ast.VarStatement{Token: p.currentToken, Doc: p.leadingComments()}
 
 	if !p.expectPeek(token.IDENT) {
 		return nil
@@ -33,7 +35,7 @@ func (p *Parser) parseVarBindingStatement() ast.Statement {
 }
 
 func (p *Parser) parseReturnStatement() ast.Statement {
-	stat := &ast.ReturnStatement{Token: p.currentToken}
+	stat := &ast.ReturnStatement{Token: p.currentToken, Doc: p.leadingComments()}
 
 	p.nextToken()
 
@@ -63,8 +65,14 @@ func (p *Parser) parseInteger() ast.Expression {
 
 	i, err := strconv.ParseInt(p.currentToken.Literal, 0, 64)
 
+	if errors.Is(err, strconv.ErrRange) {
+		if b, ok := new(big.Int).SetString(p.currentToken.Literal, 0); ok {
+			return &ast.BigIntegerLiteral{Token: p.currentToken, Value: b}
+		}
+	}
+
 	if err != nil {
-		p.errors = append(p.errors, fmt.Sprintf("couldn't parse %v as integer, %v", p.currentToken.Literal, err))
+		p.errorAt(p.currentToken, "couldn't parse %v as integer, %v", p.currentToken.Literal, err)
 		return nil
 	}
 
@@ -73,6 +81,21 @@ func (p *Parser) parseInteger() ast.Expression {
 	return exp
 }
 
+func (p *Parser) parseFloat() ast.Expression {
+	exp := &ast.FloatLiteral{Token: p.currentToken}
+
+	f, err := strconv.ParseFloat(p.currentToken.Literal, 64)
+
+	if err != nil {
+		p.errorAt(p.currentToken, "couldn't parse %v as float, %v", p.currentToken.Literal, err)
+		return nil
+	}
+
+	exp.Value = f
+
+	return exp
+}
+
 func (p *Parser) parseString() ast.Expression {
 	return &ast.StringLiteral{Token: p.currentToken, Value: p.currentToken.Literal}
 }
@@ -105,6 +128,31 @@ func (p *Parser) parseInfixExpressions(left ast.Expression) ast.Expression {
 	return exp
 }
 
+// parseAssignExpression parses assignments, they are right associative so a = b = 1 assigns 1 to both.
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	exp := &ast.AssignExpression{
+		Token:    p.currentToken,
+		Operator: p.currentToken.Literal,
+		Target:   target,
+	}
+
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression, *ast.MemberExpression:
+	case nil:
+		return nil
+	default:
+		// the target can be partly parsed and have nil children, it must not be printed
+		p.errorAt(p.currentToken, "cannot assign to this expression")
+		return nil
+	}
+
+	p.nextToken()
+
+	exp.Value = p.parseExpression(LOWEST)
+
+	return exp
+}
+
 func (p *Parser) parseGroupedExpression() ast.Expression {
 	p.nextToken()
 
@@ -135,6 +183,16 @@ func (p *Parser) parseIfElseExpression() ast.Expression {
 	if p.peekTokenIs(token.ELSE) {
 		p.nextToken()
 
+		if p.peekTokenIs(token.IF) {
+			p.nextToken()
+
+			if exp.Alternative = p.parseElseIfBlock(); exp.Alternative == nil {
+				return nil
+			}
+
+			return exp
+		}
+
 		if !p.expectPeek(token.LBRACE) {
 			return nil
 		}
@@ -145,6 +203,363 @@ func (p *Parser) parseIfElseExpression() ast.Expression {
 	return exp
 }
 
+// parseElseIfBlock parses the if-else expression following an else keyword, it is wrapped
+// in a block so the else-if chains are evaluated as nested if-else expressions.
+func (p *Parser) parseElseIfBlock() *ast.BlockStatement {
+	tok := p.currentToken
+
+	nested, ok := p.parseIfElseExpression().(*ast.IfElseExpression)
+	if !ok {
+		return nil
+	}
+
+	last := nested.Consequence
+	if nested.Alternative != nil {
+		last = nested.Alternative
+	}
+
+	return &ast.BlockStatement{
+		Token:      tok,
+		Statements: []ast.Statement{&ast.ExpressionStatement{Token: tok, Expression: nested}},
+		Rbrace:     last.Rbrace,
+	}
+}
+
+func (p *Parser) parseMatchExpression() ast.Expression {
+	exp := &ast.MatchExpression{Token: p.currentToken}
+
+	p.nextToken()
+
+	exp.Subject = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	p.nextToken()
+
+	hasDefault := false
+
+	for !p.currentTokenIs(token.RBRACE) {
+		var arm *ast.MatchArm
+
+		switch p.currentToken.Type {
+		case token.CASE:
+			arm = p.parseMatchArm()
+		case token.DEFAULT:
+			if hasDefault {
+				p.errorAt(p.currentToken, "multiple defaults in match")
+				break
+			}
+
+			hasDefault = true
+			arm = p.parseMatchArm()
+		case token.EOF:
+			p.errorAt(p.currentToken, `unexpected token  "%s" , expected "case" or "default"`, p.currentToken.Literal)
+			return nil
+		default:
+			p.errorAt(p.currentToken, `unexpected token  "%s" , expected "case" or "default"`, p.currentToken.Literal)
+		}
+
+		if arm == nil {
+			// the parsing resumes at the next arm, the closing brace of the match must not be taken for a statement
+			if !p.skipMatchArm() {
+				return nil
+			}
+
+			p.panicking = false
+			continue
+		}
+
+		exp.Arms = append(exp.Arms, arm)
+
+		p.nextToken()
+	}
+
+	exp.Rbrace = p.currentToken
+
+	return exp
+}
+
+// skipMatchArm skips the tokens of a match arm that failed to parse, up to the next arm or the closing brace of
+// the match. The braces opened while skipping, like the ones of the body of the arm, are skipped as a whole.
+// It reports whether the next arm or the closing brace was found before the end of the input.
+func (p *Parser) skipMatchArm() bool {
+	depth := 0
+
+	for {
+		p.nextToken()
+
+		switch p.currentToken.Type {
+		case token.EOF:
+			return false
+		case token.LBRACE:
+			depth++
+		case token.RBRACE:
+			if depth == 0 {
+				return true
+			}
+
+			depth--
+		case token.CASE, token.DEFAULT:
+			if depth == 0 {
+				return true
+			}
+		}
+	}
+}
+
+// parseMatchArm parses a case or a default arm, the current token must be the case or default keyword.
+func (p *Parser) parseMatchArm() *ast.MatchArm {
+	arm := &ast.MatchArm{Token: p.currentToken}
+
+	if p.currentTokenIs(token.CASE) {
+		p.nextToken()
+
+		if arm.Pattern = p.parseExpression(LOWEST); !p.checkPattern(arm.Pattern) {
+			return nil
+		}
+
+		if p.peekTokenIs(token.IF) {
+			p.nextToken()
+			p.nextToken()
+
+			arm.Guard = p.parseExpression(LOWEST)
+		}
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	arm.Body = p.parseBlockStatement()
+
+	return arm
+}
+
+// checkPattern reports an error if the expression can't be used as a pattern,
+// the patterns are literals, identifiers, and arrays and hashes of patterns.
+// the keys of hash patterns must be literals.
+func (p *Parser) checkPattern(exp ast.Expression) bool {
+	// the pattern failed to parse, it may be partly built and must not be printed
+	if p.panicking {
+		return false
+	}
+
+	switch v := exp.(type) {
+	case nil:
+		return false
+	case *ast.Identifier:
+		return true
+	case *ast.ArrayLiteral:
+		for _, item := range v.Items {
+			if !p.checkPattern(item) {
+				return false
+			}
+		}
+
+		return true
+	case *ast.HashLiteral:
+		for key, value := range v.Items {
+			if !isLiteralPattern(key) {
+				p.errorAtNode(key, "invalid hash pattern key %s, keys must be literals", key.String())
+				return false
+			}
+
+			if !p.checkPattern(value) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	if !isLiteralPattern(exp) {
+		p.errorAtNode(exp, "invalid pattern %s", exp.String())
+		return false
+	}
+
+	return true
+}
+
+func isLiteralPattern(exp ast.Expression) bool {
+	switch v := exp.(type) {
+	case *ast.IntegerLiteral, *ast.BigIntegerLiteral, *ast.FloatLiteral, *ast.StringLiteral, *ast.BooleanLiteral:
+		return true
+	case *ast.PrefixExpression:
+		switch v.Right.(type) {
+		case *ast.IntegerLiteral, *ast.BigIntegerLiteral, *ast.FloatLiteral:
+			return v.Operator == "-" || v.Operator == "+"
+		}
+	}
+
+	return false
+}
+
+// parseLabeledStatement parses a loop preceded by a label, like outer: for x in xs { ... }
+// The comments documenting the loop are the ones before the label.
+func (p *Parser) parseLabeledStatement() ast.Statement {
+	label := &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+	doc := p.leadingComments()
+
+	if slices.Contains(p.loops, label.Value) {
+		p.errorAt(p.currentToken, "label %s already defined", label.Value)
+		return nil
+	}
+
+	p.nextToken()
+	p.nextToken()
+
+	switch p.currentToken.Type {
+	case token.WHILE:
+		return p.parseWhileStatement(label, doc)
+	case token.FOR:
+		return p.parseForStatement(label, doc)
+	default:
+		p.errorAt(p.currentToken, "label %s must be followed by a loop", label.Value)
+		return nil
+	}
+}
+
+func (p *Parser) parseWhileStatement(label *ast.Identifier, doc *ast.CommentGroup) ast.Statement {
+	stat := &ast.WhileStatement{Token: p.currentToken, Doc: doc, Label: label}
+
+	p.nextToken()
+
+	stat.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stat.Body = p.parseLoopBody(label)
+
+	return stat
+}
+
+// parseForStatement parses both the C-style for loop and the for-in loop,
+// they are told apart by the "in" keyword after the loop variables.
+func (p *Parser) parseForStatement(label *ast.Identifier, doc *ast.CommentGroup) ast.Statement {
+	tok := p.currentToken
+
+	p.nextToken()
+
+	if p.currentTokenIs(token.IDENT) && (p.peekTokenIs(token.COMMA) || p.peekTokenIs(token.IN)) {
+		return p.parseForInStatement(tok, label, doc)
+	}
+
+	stat := &ast.ForStatement{Token: tok, Doc: doc, Label: label}
+
+	switch p.currentToken.Type {
+	case token.SEMICOLON:
+	case token.VAR:
+		if stat.Init = p.parseVarBindingStatement(); stat.Init == nil {
+			return nil
+		}
+	default:
+		stat.Init = &ast.ExpressionStatement{Token: p.currentToken, Expression: p.parseExpression(LOWEST)}
+
+		if !p.expectPeek(token.SEMICOLON) {
+			return nil
+		}
+	}
+
+	p.nextToken()
+
+	if !p.currentTokenIs(token.SEMICOLON) {
+		stat.Condition = p.parseExpression(LOWEST)
+
+		if !p.expectPeek(token.SEMICOLON) {
+			return nil
+		}
+	}
+
+	if !p.peekTokenIs(token.LBRACE) {
+		p.nextToken()
+		stat.Post = p.parseExpression(LOWEST)
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stat.Body = p.parseLoopBody(label)
+
+	return stat
+}
+
+func (p *Parser) parseForInStatement(tok token.Token, label *ast.Identifier, doc *ast.CommentGroup) ast.Statement {
+	stat := &ast.ForInStatement{Token: tok, Doc: doc, Label: label}
+	stat.Key = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+
+	if p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+
+		stat.Value = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+	}
+
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+
+	p.nextToken()
+
+	stat.Iterable = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stat.Body = p.parseLoopBody(label)
+
+	return stat
+}
+
+// parseLoopBody parses the body of a loop, break and continue statements are only allowed inside it.
+func (p *Parser) parseLoopBody(label *ast.Identifier) *ast.BlockStatement {
+	var name string
+	if label != nil {
+		name = label.Value
+	}
+
+	p.loops = append(p.loops, name)
+	defer func() { p.loops = p.loops[:len(p.loops)-1] }()
+
+	return p.parseBlockStatement()
+}
+
+// parseBranchStatement parses the break and continue statements, the label
+// when present must be the label of one of the enclosing loops.
+func (p *Parser) parseBranchStatement() ast.Statement {
+	stat := &ast.BranchStatement{Token: p.currentToken, Doc: p.leadingComments()}
+
+	if len(p.loops) == 0 {
+		p.errorAt(p.currentToken, "%s is not in a loop", p.currentToken.Literal)
+		return nil
+	}
+
+	if p.peekTokenIs(token.IDENT) {
+		p.nextToken()
+
+		stat.Label = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+
+		if !slices.Contains(p.loops, stat.Label.Value) {
+			p.errorAt(p.currentToken, "undefined label %s", stat.Label.Value)
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.SEMICOLON) {
+		return nil
+	}
+
+	return stat
+}
+
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.currentToken}
 	block.Statements = make([]ast.Statement, 0)
@@ -161,6 +576,12 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 		p.nextToken()
 	}
 
+	if p.currentTokenIs(token.EOF) {
+		p.errorAt(p.currentToken, `unexpected token  "%s" , expected "%s"`, p.currentToken.Literal, token.RBRACE)
+	}
+
+	block.Rbrace = p.currentToken
+
 	return block
 }
 
@@ -177,7 +598,11 @@ func (p *Parser) parseFunctionExpression() ast.Expression {
 		return nil
 	}
 
+	// the loops around the function literal can't be targeted from its body
+	loops := p.loops
+	p.loops = nil
 	exp.Body = p.parseBlockStatement()
+	p.loops = loops
 
 	return exp
 }
@@ -190,7 +615,9 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 		return args
 	}
 
-	p.nextToken()
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
 
 	args = append(args, &ast.Identifier{
 		Token: p.currentToken,
@@ -199,7 +626,11 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
-		p.nextToken()
+
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+
 		args = append(args, &ast.Identifier{
 			Token: p.currentToken,
 			Value: p.currentToken.Literal,
@@ -216,6 +647,7 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 func (p *Parser) parseFunctionCallExpression(function ast.Expression) ast.Expression {
 	fnExp := &ast.CallExpression{Token: p.currentToken, Function: function}
 	fnExp.Arguments = p.parseCallArguments()
+	fnExp.Rparent = p.currentToken
 
 	return fnExp
 }
@@ -247,10 +679,11 @@ func (p *Parser) parseCallArguments() []ast.Expression {
 
 func (p *Parser) parseArrayExpression() ast.Expression {
 	var items []ast.Expression
+	var tok = p.currentToken
 
 	if p.peekTokenIs(token.RBRACKET) {
 		p.nextToken()
-		return &ast.ArrayLiteral{Items: items}
+		return &ast.ArrayLiteral{Token: tok, Items: items, Rbracket: p.currentToken}
 	}
 
 	p.nextToken()
@@ -267,15 +700,16 @@ func (p *Parser) parseArrayExpression() ast.Expression {
 		return nil
 	}
 
-	return &ast.ArrayLiteral{Items: items}
+	return &ast.ArrayLiteral{Token: tok, Items: items, Rbracket: p.currentToken}
 }
 
 func (p *Parser) parseHashExpression() ast.Expression {
 	items := map[ast.Expression]ast.Expression{}
+	tok := p.currentToken
 
 	if p.peekTokenIs(token.RBRACE) {
 		p.nextToken()
-		return &ast.HashLiteral{Items: items}
+		return &ast.HashLiteral{Token: tok, Items: items, Rbrace: p.currentToken}
 	}
 
 	p.nextToken()
@@ -311,11 +745,11 @@ func (p *Parser) parseHashExpression() ast.Expression {
 		return nil
 	}
 
-	return &ast.HashLiteral{Items: items}
+	return &ast.HashLiteral{Token: tok, Items: items, Rbrace: p.currentToken}
 }
 
 func (p *Parser) parseArrayIndexExpression(left ast.Expression) ast.Expression {
-	var exp ast.IndexExpression
+	var exp = ast.IndexExpression{Token: p.currentToken}
 
 	exp.Left = left
 	p.nextToken()
@@ -325,5 +759,21 @@ func (p *Parser) parseArrayIndexExpression(left ast.Expression) ast.Expression {
 		return nil
 	}
 
+	exp.Rbracket = p.currentToken
+
 	return &exp
 }
+
+// parseMemberExpression parses the access to an attribute like user.name, the calls of methods
+// like req.header("X") are calls of member expressions.
+func (p *Parser) parseMemberExpression(object ast.Expression) ast.Expression {
+	exp := &ast.MemberExpression{Token: p.currentToken, Object: object}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	exp.Property = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+
+	return exp
+}
@@ -5,19 +5,55 @@ import (
 	"fmt"
 	"io"
 
+	"github.com/yassinebenaid/nishimia/ast"
+	"github.com/yassinebenaid/nishimia/compiler"
+	"github.com/yassinebenaid/nishimia/diagnostics"
 	"github.com/yassinebenaid/nishimia/eval"
 	"github.com/yassinebenaid/nishimia/lexer"
 	"github.com/yassinebenaid/nishimia/object"
 	"github.com/yassinebenaid/nishimia/parser"
+	"github.com/yassinebenaid/nishimia/vm"
 )
 
 const PROMPT = ">>> "
 
+// historySize is the number of recent lines kept to show the errors against, the errors coming
+// from older lines are shown without their source
+const historySize = 100
+
+// Start runs the repl using the evaluator
 func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
 	env := object.NewEnvirement()
 
-	fmt.Print(PROMPT)
+	run(in, out, func(program *ast.Program) object.Object {
+		return eval.Eval(program, env)
+	})
+}
+
+// StartVM runs the repl using the compiler and the virtual machine, the lines share
+// their global variables just like they do with the evaluator.
+func StartVM(in io.Reader, out io.Writer) {
+	c := compiler.New()
+	globals := vm.NewGlobals()
+
+	run(in, out, func(program *ast.Program) object.Object {
+		if err := c.Compile(program); err != nil {
+			return &object.Error{Message: err.Error()}
+		}
+
+		return vm.Run(c.Bytecode(), globals)
+	})
+}
+
+// run reads and executes the lines one by one. Every line is named after its number, like <input 3>, so the
+// errors are shown against the line they come from, which is an earlier one when a function it defined fails.
+func run(in io.Reader, out io.Writer, execute func(program *ast.Program) object.Object) {
+	scanner := bufio.NewScanner(in)
+	mode := diagnostics.ModeFor(out)
+	history := map[string]string{}
+	count := 0
+
+	io.WriteString(out, PROMPT)
 
 	for scanner.Scan() {
 		line := scanner.Text()
@@ -25,30 +61,36 @@ func Start(in io.Reader, out io.Writer) {
 		if line == "exit" {
 			break
 		}
-		lex := lexer.New(line)
+
+		count++
+		name := fmt.Sprintf("<input %d>", count)
+		history[name] = line
+		delete(history, fmt.Sprintf("<input %d>", count-historySize))
+
+		lex := lexer.NewFile(name, line)
 		par := parser.New(lex)
 		program := par.ParseProgram()
 
 		if errs := par.Errors(); len(errs) > 0 {
-			io.WriteString(out, "Parsing failed : \n")
-
-			for i, err := range errs {
-				io.WriteString(out, fmt.Sprintf("\t#%d %s\n", i, err))
-			}
+			diagnostics.Render(out, mode, line, diagnostics.FromParserErrors(errs)...)
 
 			io.WriteString(out, PROMPT)
 			continue
 		}
 
-		evaluated := eval.Eval(program, env)
+		evaluated := execute(program)
+
+		if err, ok := evaluated.(*object.Error); ok {
+			diagnostics.Render(out, mode, history[err.Pos.Filename], diagnostics.FromRuntimeError(err))
+		} else {
+			if evaluated != nil {
+				io.WriteString(out, "\n")
+				io.WriteString(out, evaluated.Inspect())
+			}
 
-		if evaluated != nil {
 			io.WriteString(out, "\n")
-			io.WriteString(out, evaluated.Inspect())
 		}
 
-		io.WriteString(out, "\n")
-
-		fmt.Print(PROMPT)
+		io.WriteString(out, PROMPT)
 	}
 }
@@ -1,27 +1,69 @@
 package token
 
+import "fmt"
+
 type TokenType string
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position // position of the first character of the token
+	End     Position // position immediately after the last character of the token
+}
+
+// Position describes a location in the source code, the zero value is an invalid position.
+type Position struct {
+	Filename string // the file name, empty when the source doesn't come from a file
+	Offset   int    // byte offset, starting at 0
+	Line     int    // line number, starting at 1
+	Column   int    // column number in runes, starting at 1
+}
+
+// IsValid reports whether the position points to a real location in the source.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// String returns the position in the form of "file:line:column", or "line:column"
+// when there is no file name, or "-" if the position is invalid.
+func (p Position) String() string {
+	if !p.IsValid() {
+		return "-"
+	}
+
+	if p.Filename == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+
+	return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)
 }
 
 const (
 	ILLIGAL TokenType = "ILLIGAL"
 	EOF     TokenType = "EOF"
+	COMMENT TokenType = "COMMENT"
 
 	// literals + identifiers
 	IDENT  TokenType = "IDENT"
 	INT    TokenType = "INT"
+	FLOAT  TokenType = "FLOAT"
 	STRING TokenType = "STRING"
 
 	// keywords
 	FUNCTION TokenType = "FUNCTION"
 	VAR      TokenType = "VAR"
+	CONST    TokenType = "CONST"
 	RETURN   TokenType = "RETURN"
 	IF       TokenType = "IF"
 	ELSE     TokenType = "ELSE"
+	WHILE    TokenType = "WHILE"
+	FOR      TokenType = "FOR"
+	IN       TokenType = "IN"
+	BREAK    TokenType = "BREAK"
+	CONTINUE TokenType = "CONTINUE"
+	MATCH    TokenType = "MATCH"
+	CASE     TokenType = "CASE"
+	DEFAULT  TokenType = "DEFAULT"
 	TRUE     TokenType = "TRUE"
 	FALSE    TokenType = "FALSE"
 
@@ -31,6 +73,7 @@ const (
 	MINUS    TokenType = "-"
 	ASTERISK TokenType = "*"
 	SLASH    TokenType = "/"
+	PERCENT  TokenType = "%"
 	BANG     TokenType = "!"
 	GT       TokenType = ">"
 	LT       TokenType = "<"
@@ -41,10 +84,18 @@ const (
 	AND      TokenType = "&&"
 	OR       TokenType = "||"
 
+	// assignment operators
+	PLUS_ASSIGN     TokenType = "+="
+	MINUS_ASSIGN    TokenType = "-="
+	ASTERISK_ASSIGN TokenType = "*="
+	SLASH_ASSIGN    TokenType = "/="
+	PERCENT_ASSIGN  TokenType = "%="
+
 	// delimiters
 	COMMA     TokenType = ","
 	SEMICOLON TokenType = ";"
 	COLON     TokenType = ":"
+	DOT       TokenType = "."
 	LPARENT   TokenType = "("
 	RPARENT   TokenType = ")"
 	LBRACE    TokenType = "{"
@@ -54,13 +105,22 @@ const (
 )
 
 var keywords = map[string]TokenType{
-	"func":   FUNCTION,
-	"var":    VAR,
-	"return": RETURN,
-	"if":     IF,
-	"else":   ELSE,
-	"true":   TRUE,
-	"false":  FALSE,
+	"func":     FUNCTION,
+	"var":      VAR,
+	"const":    CONST,
+	"return":   RETURN,
+	"if":       IF,
+	"else":     ELSE,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"match":    MATCH,
+	"case":     CASE,
+	"default":  DEFAULT,
+	"true":     TRUE,
+	"false":    FALSE,
 }
 
 func LookupIdent(ident string) TokenType {
@@ -0,0 +1,184 @@
+package vm
+
+import (
+	goast "go/ast"
+	goparser "go/parser"
+	gotoken "go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/yassinebenaid/nishimia/ast"
+	"github.com/yassinebenaid/nishimia/compiler"
+	"github.com/yassinebenaid/nishimia/eval"
+	"github.com/yassinebenaid/nishimia/lexer"
+	"github.com/yassinebenaid/nishimia/object"
+	"github.com/yassinebenaid/nishimia/parser"
+)
+
+// engines lists every way of running a program, they must all agree with the evaluator
+var engines = []struct {
+	name string
+	run  func(program *ast.Program, options eval.Options) object.Object
+}{
+	{"eval", func(program *ast.Program, options eval.Options) object.Object {
+		return eval.New(options).Eval(program, object.NewEnvirement())
+	}},
+	{"vm", func(program *ast.Program, options eval.Options) object.Object {
+		bytecode, err := compiler.Compile(program)
+		if err != nil {
+			return &object.Error{Message: "compile error: " + err.Error()}
+		}
+
+		return New(options).Run(bytecode, nil)
+	}},
+}
+
+// testEngines runs the program through all the engines with every set of options,
+// it fails if any of them returns a different result or a different error.
+func testEngines(t *testing.T, name string, program *ast.Program) {
+	t.Helper()
+
+	for _, options := range []eval.Options{{}, {TruthyOr: true}} {
+		expected := inspect(engines[0].run(program, options))
+
+		for _, engine := range engines[1:] {
+			if got := inspect(engine.run(program, options)); got != expected {
+				t.Errorf("%s: engines %s and %s disagree (options %+v).\nprogram:\n%s\n%s: %s\n%s: %s",
+					name, engines[0].name, engine.name, options, program.String(),
+					engines[0].name, expected,
+					engine.name, got,
+				)
+			}
+		}
+	}
+}
+
+// TestFixtures runs every .ns file of the module
+func TestFixtures(t *testing.T) {
+	root := ".."
+	var found bool
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".ns" {
+			return err
+		}
+
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		found = true
+		name, _ := filepath.Rel(root, path)
+
+		p := parser.New(lexer.NewFile(name, string(src)))
+		program := p.ParseProgram()
+
+		if len(p.Errors()) > 0 {
+			t.Errorf("failed to parse %s: %v", name, p.Errors())
+			return nil
+		}
+
+		testEngines(t, name, program)
+
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !found {
+		t.Fatal("no fixture found")
+	}
+}
+
+// TestEvalTableCases runs the programs of the table driven tests of the evaluator, they are
+// extracted from the source of its tests so the new cases are covered automatically.
+func TestEvalTableCases(t *testing.T) {
+	inputs := evalTestInputs(t, "../eval/eval_test.go")
+
+	if len(inputs) == 0 {
+		t.Fatal("no test case found")
+	}
+
+	for _, input := range inputs {
+		p := parser.New(lexer.New(input))
+		program := p.ParseProgram()
+
+		// some cases test the errors of the parser, they don't run
+		if len(p.Errors()) > 0 {
+			continue
+		}
+
+		testEngines(t, strconv.Quote(input), program)
+	}
+}
+
+// evalTestInputs returns the programs of the test file, the programs are the first string of each row
+// of the tables, or the field named input, and the literal arguments of the testEval calls.
+func evalTestInputs(t *testing.T, path string) []string {
+	file, err := goparser.ParseFile(gotoken.NewFileSet(), path, nil, 0)
+	if err != nil {
+		t.Fatalf("failed to parse %s: %s", path, err)
+	}
+
+	var inputs []string
+
+	add := func(exp goast.Expr) {
+		if lit, ok := exp.(*goast.BasicLit); ok && lit.Kind == gotoken.STRING {
+			if input, err := strconv.Unquote(lit.Value); err == nil {
+				inputs = append(inputs, input)
+			}
+		}
+	}
+
+	goast.Inspect(file, func(node goast.Node) bool {
+		switch node := node.(type) {
+		case *goast.CompositeLit:
+			// the rows of the tables have their type elided
+			if node.Type != nil || len(node.Elts) == 0 {
+				return true
+			}
+
+			for _, elt := range node.Elts {
+				if kv, ok := elt.(*goast.KeyValueExpr); ok {
+					if key, ok := kv.Key.(*goast.Ident); ok && key.Name == "input" {
+						add(kv.Value)
+					}
+				}
+			}
+
+			add(node.Elts[0])
+		case *goast.CallExpr:
+			if fn, ok := node.Fun.(*goast.Ident); ok && fn.Name == "testEval" && len(node.Args) == 1 {
+				add(node.Args[0])
+			}
+		}
+
+		return true
+	})
+
+	return inputs
+}
+
+func FuzzEngines(f *testing.F) {
+	for _, seed := range []string{
+		"",
+		"\x01\x02\x03\x04\x05\x06\x07\x08",
+		"\x05\x05\x05\x05\x05\x05\x05\x05\x05\x05",
+		"\x08\x02\x01\x00\x03\x07\x01\x09\x04\x02\x06",
+		strings.Repeat("\x07\x03\x0a\x01", 8),
+		strings.Repeat("\x0b\x04\x02\x09\x06", 10),
+	} {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		testEngines(t, "generated program", newGenerator(data).program())
+	})
+}
@@ -0,0 +1,608 @@
+package vm
+
+import (
+	"fmt"
+	"math/big"
+	"slices"
+	"strconv"
+
+	"github.com/yassinebenaid/nishimia/ast"
+	"github.com/yassinebenaid/nishimia/token"
+)
+
+// generator builds random programs for the fuzzer, its choices are read from the fuzzing input
+// so the mutations of the input lead to similar programs, it picks the first option once the
+// input is exhausted. The programs are well-formed and always terminate: the loops run a bounded
+// number of times and the functions can only call the functions defined before them. The assigned
+// values never read a variable, so the values can't grow exponentially in loops or contain themselves.
+type generator struct {
+	data   []byte
+	offset int // the offset of the next token, every token gets its own position
+	budget int // the number of nodes left, the generator only produces leaves once it is exhausted
+	depth  int
+	names  int  // the number of fresh names generated so far
+	pure   bool // whether the expressions can't read the variables
+
+	vars      []string   // the variables in scope, they can be read and assigned
+	functions []function // the functions that can be called
+	loops     []string   // the labels of the loops around the current statement, empty for unlabeled loops
+}
+
+type function struct {
+	name  string
+	arity int
+}
+
+func newGenerator(data []byte) *generator {
+	return &generator{data: data, budget: 300}
+}
+
+func (g *generator) choose(n int) int {
+	if len(g.data) == 0 {
+		return 0
+	}
+
+	b := g.data[0]
+	g.data = g.data[1:]
+
+	return int(b) % n
+}
+
+func (g *generator) token(typ token.TokenType, literal string) token.Token {
+	pos := token.Position{Offset: g.offset, Line: 1, Column: g.offset + 1}
+	end := token.Position{Offset: g.offset + len(literal), Line: 1, Column: g.offset + len(literal) + 1}
+	g.offset += len(literal) + 1
+
+	return token.Token{Type: typ, Literal: literal, Pos: pos, End: end}
+}
+
+func (g *generator) fresh(prefix string) string {
+	g.names++
+	return fmt.Sprintf("%s%d", prefix, g.names)
+}
+
+// scope runs fn in a nested scope, the variables declared by fn are forgotten after it
+func (g *generator) scope(fn func()) {
+	vars := len(g.vars)
+	functions := len(g.functions)
+
+	g.depth++
+	fn()
+	g.depth--
+
+	g.vars = g.vars[:vars]
+	g.functions = g.functions[:functions]
+}
+
+func (g *generator) exhausted() bool {
+	g.budget--
+	return g.budget <= 0 || g.depth > 4
+}
+
+func (g *generator) program() *ast.Program {
+	program := &ast.Program{}
+
+	for n := 1 + g.choose(8); n > 0; n-- {
+		program.Statements = append(program.Statements, g.statements()...)
+	}
+
+	return program
+}
+
+func (g *generator) block() *ast.BlockStatement {
+	block := &ast.BlockStatement{Token: g.token(token.LBRACE, "{")}
+
+	for n := g.choose(4); n > 0; n-- {
+		block.Statements = append(block.Statements, g.statements()...)
+	}
+
+	block.Rbrace = g.token(token.RBRACE, "}")
+
+	return block
+}
+
+// statements returns a statement, preceded by the statements it needs
+func (g *generator) statements() []ast.Statement {
+	if g.exhausted() {
+		return []ast.Statement{g.expressionStatement(g.leaf())}
+	}
+
+	switch g.choose(12) {
+	case 0, 1:
+		return []ast.Statement{g.expressionStatement(g.expression())}
+	case 2:
+		return []ast.Statement{g.varStatement(token.VAR)}
+	case 3:
+		return []ast.Statement{g.varStatement(token.CONST)}
+	case 4:
+		return []ast.Statement{g.expressionStatement(g.assignment())}
+	case 5:
+		return []ast.Statement{g.expressionStatement(g.ifElse())}
+	case 6:
+		return []ast.Statement{g.forIn()}
+	case 7:
+		return []ast.Statement{g.forLoop()}
+	case 8:
+		return g.whileLoop()
+	case 9:
+		return []ast.Statement{g.functionDefinition()}
+	case 10:
+		return []ast.Statement{&ast.ReturnStatement{Token: g.token(token.RETURN, "return"), Return: g.expression()}}
+	default:
+		if len(g.loops) == 0 {
+			return []ast.Statement{g.expressionStatement(g.expression())}
+		}
+
+		return []ast.Statement{g.branch()}
+	}
+}
+
+func (g *generator) expressionStatement(exp ast.Expression) ast.Statement {
+	return &ast.ExpressionStatement{Token: token.Token{Pos: exp.Pos(), End: exp.End()}, Expression: exp}
+}
+
+// varStatement declares a new variable, or sometimes an existing one
+func (g *generator) varStatement(typ token.TokenType) ast.Statement {
+	stat := &ast.VarStatement{Token: g.token(typ, map[token.TokenType]string{token.VAR: "var", token.CONST: "const"}[typ])}
+
+	name := g.fresh("v")
+	if len(g.vars) > 0 && g.choose(4) == 0 {
+		name = g.vars[g.choose(len(g.vars))]
+	}
+
+	stat.Name = &ast.Identifier{Token: g.token(token.IDENT, name), Value: name}
+	stat.Value = g.expression()
+	g.vars = append(g.vars, name)
+
+	return stat
+}
+
+func (g *generator) functionDefinition() ast.Statement {
+	name := g.fresh("f")
+
+	stat := &ast.VarStatement{
+		Token: g.token(token.CONST, "const"),
+		Name:  &ast.Identifier{Token: g.token(token.IDENT, name), Value: name},
+		Value: g.functionLiteral(),
+	}
+
+	g.functions = append(g.functions, function{name: name, arity: len(stat.Value.(*ast.FunctionLiteral).Params)})
+
+	return stat
+}
+
+func (g *generator) functionLiteral() *ast.FunctionLiteral {
+	fn := &ast.FunctionLiteral{Token: g.token(token.FUNCTION, "func")}
+
+	loops := g.loops
+	g.loops = nil
+
+	g.scope(func() {
+		for n := g.choose(3); n > 0; n-- {
+			name := g.fresh("p")
+			fn.Params = append(fn.Params, &ast.Identifier{Token: g.token(token.IDENT, name), Value: name})
+			g.vars = append(g.vars, name)
+		}
+
+		fn.Body = g.block()
+	})
+
+	g.loops = loops
+
+	return fn
+}
+
+// loopBody generates the body of a loop, the loop may be labeled
+func (g *generator) loopBody(label *ast.Identifier, prologue ...ast.Statement) *ast.BlockStatement {
+	name := ""
+	if label != nil {
+		name = label.Value
+	}
+
+	g.loops = append(g.loops, name)
+	defer func() { g.loops = g.loops[:len(g.loops)-1] }()
+
+	var body *ast.BlockStatement
+	g.scope(func() {
+		body = g.block()
+	})
+
+	body.Statements = append(prologue, body.Statements...)
+
+	return body
+}
+
+func (g *generator) label() *ast.Identifier {
+	if g.choose(3) != 0 {
+		return nil
+	}
+
+	name := g.fresh("l")
+	return &ast.Identifier{Token: g.token(token.IDENT, name), Value: name}
+}
+
+func (g *generator) forIn() ast.Statement {
+	stat := &ast.ForInStatement{Label: g.label(), Token: g.token(token.FOR, "for")}
+
+	g.scope(func() {
+		key := g.fresh("k")
+		stat.Key = &ast.Identifier{Token: g.token(token.IDENT, key), Value: key}
+		g.vars = append(g.vars, key)
+
+		if g.choose(2) == 0 {
+			value := g.fresh("v")
+			stat.Value = &ast.Identifier{Token: g.token(token.IDENT, value), Value: value}
+			g.vars = append(g.vars, value)
+		}
+
+		// the loops iterate at most three times
+		switch g.choose(3) {
+		case 0:
+			stat.Iterable = g.array(3)
+		case 1:
+			stat.Iterable = g.hash(3)
+		default:
+			stat.Iterable = g.stringLiteral()
+		}
+
+		stat.Body = g.loopBody(stat.Label)
+	})
+
+	return stat
+}
+
+// forLoop generates a C-style loop with a counter that the body can't assign
+func (g *generator) forLoop() ast.Statement {
+	stat := &ast.ForStatement{Label: g.label(), Token: g.token(token.FOR, "for")}
+	counter := g.fresh("i")
+
+	stat.Init = &ast.VarStatement{
+		Token: g.token(token.VAR, "var"),
+		Name:  g.identifier(counter),
+		Value: g.integer(0),
+	}
+
+	stat.Condition = g.infix(g.identifier(counter), "<", g.integer(int64(g.choose(4))))
+	stat.Post = &ast.AssignExpression{Target: g.identifier(counter), Token: g.token(token.PLUS_ASSIGN, "+="), Operator: "+=", Value: g.integer(1)}
+	stat.Body = g.loopBody(stat.Label)
+
+	return stat
+}
+
+// whileLoop generates a loop with a counter incremented at the start of each iteration
+func (g *generator) whileLoop() []ast.Statement {
+	counter := g.fresh("w")
+
+	init := &ast.VarStatement{
+		Token: g.token(token.VAR, "var"),
+		Name:  g.identifier(counter),
+		Value: g.integer(0),
+	}
+
+	stat := &ast.WhileStatement{Label: g.label(), Token: g.token(token.WHILE, "while")}
+	stat.Condition = g.infix(g.identifier(counter), "<", g.integer(int64(g.choose(4))))
+
+	increment := &ast.AssignExpression{Target: g.identifier(counter), Token: g.token(token.PLUS_ASSIGN, "+="), Operator: "+=", Value: g.integer(1)}
+	stat.Body = g.loopBody(stat.Label, g.expressionStatement(increment))
+
+	return []ast.Statement{init, stat}
+}
+
+func (g *generator) branch() ast.Statement {
+	stat := &ast.BranchStatement{Token: g.token(token.BREAK, "break")}
+
+	if g.choose(2) == 0 {
+		stat.Token = g.token(token.CONTINUE, "continue")
+	}
+
+	if label := g.loops[g.choose(len(g.loops))]; label != "" && g.choose(2) == 0 {
+		stat.Label = g.identifier(label)
+	}
+
+	return stat
+}
+
+func (g *generator) expression() ast.Expression {
+	if g.exhausted() {
+		return g.leaf()
+	}
+
+	g.depth++
+	defer func() { g.depth-- }()
+
+	switch g.choose(14) {
+	case 0, 1:
+		return g.leaf()
+	case 2:
+		operators := []string{"-", "+", "!"}
+		operator := operators[g.choose(len(operators))]
+
+		return &ast.PrefixExpression{Token: g.token(token.TokenType(operator), operator), Operator: operator, Right: g.expression()}
+	case 3, 4:
+		operators := []string{"+", "-", "*", "/", "%", "==", "!=", "<", "<=", ">", ">=", "&&", "||"}
+		return g.infix(g.expression(), operators[g.choose(len(operators))], g.expression())
+	case 5:
+		return g.array(4)
+	case 6:
+		return g.hash(4)
+	case 7:
+		return &ast.IndexExpression{Left: g.expression(), Token: g.token(token.LBRACKET, "["), Index: g.expression(), Rbracket: g.token(token.RBRACKET, "]")}
+	case 8:
+		return g.ifElse()
+	case 9:
+		return g.match()
+	case 10:
+		return g.call()
+	case 11:
+		return g.assignment()
+	case 12:
+		return g.functionLiteral()
+	default:
+		return g.condition()
+	}
+}
+
+// leaf returns a literal or a variable
+func (g *generator) leaf() ast.Expression {
+	switch g.choose(8) {
+	case 0:
+		return g.integer(int64(g.choose(5)))
+	case 1:
+		return &ast.PrefixExpression{Token: g.token(token.MINUS, "-"), Operator: "-", Right: g.integer(int64(g.choose(256)))}
+	case 2:
+		return &ast.FloatLiteral{Token: g.token(token.FLOAT, "1.5"), Value: 1.5}
+	case 3:
+		return g.stringLiteral()
+	case 4:
+		return g.boolean(g.choose(2) == 0)
+	case 5:
+		if g.choose(4) == 0 {
+			value, _ := new(big.Int).SetString("99999999999999999999", 10)
+			return &ast.BigIntegerLiteral{Token: g.token(token.INT, value.String()), Value: value}
+		}
+
+		return g.integer(9223372036854775807)
+	default:
+		if g.pure {
+			return g.stringLiteral()
+		}
+
+		if len(g.vars) == 0 || g.choose(8) == 0 {
+			// undefined variables, builtins and functions are valid identifiers too
+			names := []string{"undefined", "len", "int", "float"}
+			for _, fn := range g.functions {
+				names = append(names, fn.name)
+			}
+
+			return g.identifier(names[g.choose(len(names))])
+		}
+
+		return g.identifier(g.vars[g.choose(len(g.vars))])
+	}
+}
+
+// condition returns an expression that is usually a boolean
+func (g *generator) condition() ast.Expression {
+	switch g.choose(4) {
+	case 0:
+		return g.boolean(g.choose(2) == 0)
+	case 1:
+		return g.expression()
+	default:
+		operators := []string{"==", "!=", "<", ">"}
+		return g.infix(g.leaf(), operators[g.choose(len(operators))], g.leaf())
+	}
+}
+
+func (g *generator) ifElse() ast.Expression {
+	exp := &ast.IfElseExpression{Token: g.token(token.IF, "if"), Condition: g.condition()}
+
+	g.scope(func() { exp.Consequence = g.block() })
+
+	switch g.choose(3) {
+	case 0:
+		g.scope(func() { exp.Alternative = g.block() })
+	case 1:
+		// an else-if chain
+		tok := g.token(token.LBRACE, "{")
+		nested := g.ifElse()
+		exp.Alternative = &ast.BlockStatement{Token: tok, Statements: []ast.Statement{g.expressionStatement(nested)}, Rbrace: g.token(token.RBRACE, "}")}
+	}
+
+	return exp
+}
+
+func (g *generator) match() ast.Expression {
+	exp := &ast.MatchExpression{Token: g.token(token.MATCH, "match"), Subject: g.expression()}
+
+	for n := 1 + g.choose(3); n > 0; n-- {
+		arm := &ast.MatchArm{Token: g.token(token.CASE, "case")}
+
+		g.scope(func() {
+			arm.Pattern = g.pattern(0)
+
+			if g.choose(3) == 0 {
+				arm.Guard = g.condition()
+			}
+
+			arm.Body = g.block()
+		})
+
+		exp.Arms = append(exp.Arms, arm)
+	}
+
+	if g.choose(2) == 0 {
+		arm := &ast.MatchArm{Token: g.token(token.DEFAULT, "default")}
+		g.scope(func() { arm.Body = g.block() })
+
+		// the default arm can be anywhere
+		exp.Arms = slices.Insert(exp.Arms, g.choose(len(exp.Arms)+1), arm)
+	}
+
+	exp.Rbrace = g.token(token.RBRACE, "}")
+
+	return exp
+}
+
+// pattern returns a pattern of a match arm, the variables it binds are added to the scope of the arm
+func (g *generator) pattern(depth int) ast.Expression {
+	switch g.choose(6) {
+	case 0:
+		name := g.fresh("v")
+		g.vars = append(g.vars, name)
+
+		return g.identifier(name)
+	case 1:
+		return g.identifier("_")
+	case 2:
+		if depth < 2 {
+			arr := &ast.ArrayLiteral{Token: g.token(token.LBRACKET, "[")}
+			for n := g.choose(3); n > 0; n-- {
+				arr.Items = append(arr.Items, g.pattern(depth+1))
+			}
+			arr.Rbracket = g.token(token.RBRACKET, "]")
+
+			return arr
+		}
+	case 3:
+		if depth < 2 {
+			hash := &ast.HashLiteral{Token: g.token(token.LBRACE, "{"), Items: make(map[ast.Expression]ast.Expression)}
+			for n := g.choose(3); n > 0; n-- {
+				hash.Items[g.literal()] = g.pattern(depth + 1)
+			}
+			hash.Rbrace = g.token(token.RBRACE, "}")
+
+			return hash
+		}
+	case 4:
+		return &ast.PrefixExpression{Token: g.token(token.MINUS, "-"), Operator: "-", Right: g.integer(int64(g.choose(5)))}
+	}
+
+	return g.literal()
+}
+
+func (g *generator) literal() ast.Expression {
+	switch g.choose(4) {
+	case 0:
+		return g.stringLiteral()
+	case 1:
+		return g.boolean(g.choose(2) == 0)
+	default:
+		return g.integer(int64(g.choose(5)))
+	}
+}
+
+// call calls a function defined before, a builtin or sometimes a value that is not a function
+func (g *generator) call() ast.Expression {
+	exp := &ast.CallExpression{Token: g.token(token.LPARENT, "(")}
+	arity := 1
+
+	switch choice := g.choose(4); {
+	case choice < 2 && len(g.functions) > 0 && !g.pure:
+		fn := g.functions[g.choose(len(g.functions))]
+		exp.Function = g.identifier(fn.name)
+		arity = fn.arity
+	case choice == 2 && !g.pure:
+		exp.Function = g.leaf()
+	default:
+		builtins := []string{"len", "int", "float"}
+		exp.Function = g.identifier(builtins[g.choose(len(builtins))])
+	}
+
+	// wrong argument counts are errors
+	if g.choose(8) == 0 {
+		arity = g.choose(3)
+	}
+
+	for ; arity > 0; arity-- {
+		exp.Arguments = append(exp.Arguments, g.expression())
+	}
+
+	exp.Rparent = g.token(token.RPARENT, ")")
+
+	return exp
+}
+
+// assignment assigns a variable or an index, the loop counters are never assigned
+func (g *generator) assignment() ast.Expression {
+	operators := []string{"=", "+=", "-=", "*=", "/=", "%="}
+	operator := operators[g.choose(len(operators))]
+
+	var target ast.Expression
+	if len(g.vars) > 0 && g.choose(4) != 0 {
+		target = g.identifier(g.vars[g.choose(len(g.vars))])
+	} else {
+		target = g.identifier("undefined")
+	}
+
+	if g.choose(3) == 0 {
+		target = &ast.IndexExpression{Left: target, Token: g.token(token.LBRACKET, "["), Index: g.leaf(), Rbracket: g.token(token.RBRACKET, "]")}
+	}
+
+	exp := &ast.AssignExpression{Target: target, Token: g.token(token.TokenType(operator), operator), Operator: operator}
+
+	pure := g.pure
+	g.pure = true
+	exp.Value = g.expression()
+	g.pure = pure
+
+	return exp
+}
+
+func (g *generator) array(max int) *ast.ArrayLiteral {
+	arr := &ast.ArrayLiteral{Token: g.token(token.LBRACKET, "[")}
+
+	for n := g.choose(max + 1); n > 0; n-- {
+		arr.Items = append(arr.Items, g.expression())
+	}
+
+	arr.Rbracket = g.token(token.RBRACKET, "]")
+
+	return arr
+}
+
+func (g *generator) hash(max int) *ast.HashLiteral {
+	hash := &ast.HashLiteral{Token: g.token(token.LBRACE, "{"), Items: make(map[ast.Expression]ast.Expression)}
+
+	for n := g.choose(max + 1); n > 0; n-- {
+		var key ast.Expression
+		if g.choose(4) == 0 {
+			key = g.expression()
+		} else {
+			key = g.literal()
+		}
+
+		hash.Items[key] = g.expression()
+	}
+
+	hash.Rbrace = g.token(token.RBRACE, "}")
+
+	return hash
+}
+
+func (g *generator) infix(left ast.Expression, operator string, right ast.Expression) ast.Expression {
+	return &ast.InfixExpression{Left: left, Token: g.token(token.TokenType(operator), operator), Operator: operator, Right: right}
+}
+
+func (g *generator) identifier(name string) *ast.Identifier {
+	return &ast.Identifier{Token: g.token(token.IDENT, name), Value: name}
+}
+
+func (g *generator) integer(value int64) ast.Expression {
+	return &ast.IntegerLiteral{Token: g.token(token.INT, strconv.FormatInt(value, 10)), Value: value}
+}
+
+func (g *generator) stringLiteral() ast.Expression {
+	values := []string{"", "a", "héllo", "ab"}
+	value := values[g.choose(len(values))]
+
+	return &ast.StringLiteral{Token: g.token(token.STRING, value), Value: value}
+}
+
+func (g *generator) boolean(value bool) ast.Expression {
+	if value {
+		return &ast.BooleanLiteral{Token: g.token(token.TRUE, "true"), Value: true}
+	}
+
+	return &ast.BooleanLiteral{Token: g.token(token.FALSE, "false"), Value: false}
+}
@@ -0,0 +1,802 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/yassinebenaid/nishimia/compiler"
+	"github.com/yassinebenaid/nishimia/eval"
+	"github.com/yassinebenaid/nishimia/object"
+)
+
+// Scope holds the variables of a scope at runtime, the slots of the variables are
+// assigned by the compiler, a nil slot is a variable that is not defined yet.
+type Scope struct {
+	slots  []object.Object
+	consts []bool // the slots defined using const, allocated on the first one
+	parent *Scope
+}
+
+func newScope(size int, parent *Scope) *Scope {
+	return &Scope{slots: make([]object.Object, size), parent: parent}
+}
+
+func (s *Scope) isConst(index int) bool {
+	return index < len(s.consts) && s.consts[index]
+}
+
+func (s *Scope) setConst(index int) {
+	if len(s.consts) < len(s.slots) {
+		s.consts = append(s.consts, make([]bool, len(s.slots)-len(s.consts))...)
+	}
+
+	s.consts[index] = true
+}
+
+// Globals holds the global variables, running several programs compiled by the same
+// compiler with the same globals lets them share their variables, like the repl does.
+type Globals struct {
+	scope *Scope
+}
+
+func NewGlobals() *Globals {
+	return &Globals{scope: &Scope{}}
+}
+
+// Closure is a compiled function along with the scope it was created in,
+// its free variables are resolved through that scope when it is called.
+type Closure struct {
+	Fn    *compiler.CompiledFunction
+	Scope *Scope
+}
+
+func (*Closure) Type() object.ObjectType { return object.FUNCTION_OBJ }
+func (c *Closure) Inspect() string       { return c.Fn.Inspect() }
+
+// iterator is the state of a for-in loop, it lives on the stack during the loop
+type iterator struct {
+	keys   []object.Object
+	values []object.Object
+	next   int
+}
+
+func (*iterator) Type() object.ObjectType { return "ITERATOR" }
+func (*iterator) Inspect() string         { return "iterator" }
+
+// VM executes the bytecode produced by the compiler package, the results and the
+// errors are the same as the ones of the evaluator using the same options.
+type VM struct {
+	options eval.Options
+}
+
+func New(options eval.Options) *VM {
+	return &VM{options: options}
+}
+
+var defaultVM = New(eval.Options{})
+
+// Run executes the bytecode using the default options
+func Run(bytecode *compiler.Bytecode, globals *Globals) object.Object {
+	return defaultVM.Run(bytecode, globals)
+}
+
+// RunContext executes the bytecode using the default options, it stops once the context is done
+func RunContext(ctx context.Context, bytecode *compiler.Bytecode, globals *Globals) object.Object {
+	return defaultVM.RunContext(ctx, bytecode, globals)
+}
+
+// Run executes the bytecode with the given globals, a nil globals runs it with new ones.
+// It returns the value of the program, or an error located at the node that caused it.
+// any unexpected panic is turned into an error instead of crashing the host process.
+func (vm *VM) Run(bytecode *compiler.Bytecode, globals *Globals) object.Object {
+	return vm.RunContext(context.Background(), bytecode, globals)
+}
+
+// RunContext executes the bytecode like Run, the execution stops with a cancellation error once
+// the context is done. The context is checked at every loop iteration and function call.
+func (vm *VM) RunContext(ctx context.Context, bytecode *compiler.Bytecode, globals *Globals) (result object.Object) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = &object.Error{
+				Message: fmt.Sprintf("internal error: %v", r),
+				GoStack: string(debug.Stack()),
+			}
+		}
+	}()
+
+	if globals == nil {
+		globals = NewGlobals()
+	}
+
+	if n := bytecode.NumGlobals - len(globals.scope.slots); n > 0 {
+		globals.scope.slots = append(globals.scope.slots, make([]object.Object, n)...)
+	}
+
+	m := &machine{
+		VM:       vm,
+		ctx:      ctx,
+		bytecode: bytecode,
+		globals:  globals.scope,
+		stack:    make([]object.Object, 256),
+	}
+
+	main := &compiler.CompiledFunction{Instructions: bytecode.Instructions, SourceMap: bytecode.SourceMap}
+	m.frames = []*frame{{fn: main, scope: globals.scope}}
+
+	return m.run(0)
+}
+
+// frame is a function call being executed
+type frame struct {
+	fn    *compiler.CompiledFunction
+	ip    int
+	base  int // the stack height before the call, the function itself is stored there
+	scope *Scope
+	loops []loopRecord
+}
+
+// loopRecord is where the break and continue statements of a loop unwind to
+type loopRecord struct {
+	sp    int
+	scope *Scope
+}
+
+// machine holds everything that is specific to a single run
+type machine struct {
+	*VM
+	ctx      context.Context
+	bytecode *compiler.Bytecode
+	globals  *Scope
+	stack    []object.Object
+	sp       int
+	frames   []*frame
+}
+
+var prefixOperators = map[compiler.Opcode]string{
+	compiler.OpMinus: "-",
+	compiler.OpPlus:  "+",
+	compiler.OpBang:  "!",
+}
+
+// small integers are shared instead of being allocated for every result
+var smallIntegers [1024]*object.Integer
+
+func init() {
+	for i := range smallIntegers {
+		smallIntegers[i] = &object.Integer{Value: int64(i)}
+	}
+}
+
+func integer(value int64) *object.Integer {
+	if value >= 0 && value < int64(len(smallIntegers)) {
+		return smallIntegers[value]
+	}
+
+	return &object.Integer{Value: value}
+}
+
+func (m *machine) push(obj object.Object) {
+	if m.sp == len(m.stack) {
+		m.stack = append(m.stack, make([]object.Object, len(m.stack))...)
+	}
+
+	m.stack[m.sp] = obj
+	m.sp++
+}
+
+func (m *machine) pop() object.Object {
+	m.sp--
+	return m.stack[m.sp]
+}
+
+// run executes the frames on top of the given one, it returns the value returned by the
+// frame at that height. The nested runs execute the functions called back by the builtins.
+func (m *machine) run(bottom int) object.Object {
+	f := m.frames[len(m.frames)-1]
+	ins := f.fn.Instructions
+
+	for f.ip < len(ins) {
+		start := f.ip
+		op := compiler.Opcode(ins[start])
+		f.ip++
+
+		var err *object.Error
+
+		switch op {
+		case compiler.OpConstant:
+			m.push(m.bytecode.Constants[compiler.ReadUint16(ins[f.ip:])])
+			f.ip += 2
+		case compiler.OpNull:
+			m.push(eval.NULL)
+		case compiler.OpTrue:
+			m.push(eval.TRUE)
+		case compiler.OpFalse:
+			m.push(eval.FALSE)
+		case compiler.OpPop:
+			m.sp--
+
+		case compiler.OpAdd, compiler.OpSub, compiler.OpMul, compiler.OpDiv, compiler.OpMod,
+			compiler.OpEqual, compiler.OpNotEqual, compiler.OpGreater, compiler.OpGreaterEqual,
+			compiler.OpLess, compiler.OpLessEqual, compiler.OpAnd, compiler.OpOr:
+			right := m.pop()
+			left := m.pop()
+
+			result := m.binaryOperation(op, left, right)
+			if err = asError(result); err == nil {
+				m.push(result)
+			}
+
+		case compiler.OpMinus, compiler.OpPlus, compiler.OpBang:
+			result := eval.PrefixOperation(prefixOperators[op], m.pop())
+			if err = asError(result); err == nil {
+				m.push(result)
+			}
+
+		case compiler.OpJump:
+			target := int(compiler.ReadUint32(ins[f.ip:]))
+
+			// the backward jumps start the next iteration of a loop
+			if target < f.ip {
+				err = m.interrupted()
+			}
+
+			f.ip = target
+		case compiler.OpJumpNotTrue:
+			value := m.pop()
+
+			if cond, ok := value.(*object.Boolean); !ok {
+				err = conditionError(ins[f.ip], value)
+			} else if !cond.Value {
+				f.ip = int(compiler.ReadUint32(ins[f.ip+1:]))
+			} else {
+				f.ip += 5
+			}
+		case compiler.OpShortCircuit:
+			left := m.stack[m.sp-1]
+			op := compiler.Opcode(ins[f.ip])
+
+			if left.Type() != object.BOOLEAN_OBJ && (op != compiler.OpOr || !m.options.TruthyOr) {
+				err = eval.LogicalOperandError(compiler.Operators[op], left)
+				break
+			}
+
+			var decided bool
+			switch op {
+			case compiler.OpAnd:
+				decided = left == eval.FALSE
+			case compiler.OpOr:
+				decided = left == eval.TRUE || (m.options.TruthyOr && eval.IsTruthy(left))
+			}
+
+			if decided {
+				f.ip = int(compiler.ReadUint32(ins[f.ip+1:]))
+			} else {
+				f.ip += 5
+			}
+
+		case compiler.OpGetVar:
+			binding := m.bytecode.Bindings[compiler.ReadUint16(ins[f.ip:])]
+			f.ip += 2
+
+			if scope, index := m.resolve(f, binding); scope != nil {
+				m.push(scope.slots[index])
+			} else if builtin, ok := m.builtin(binding.Name); ok {
+				m.push(builtin)
+			} else {
+				err = eval.UndefinedIdentifierError(binding.Name)
+			}
+		case compiler.OpDefine:
+			binding := m.bytecode.Bindings[compiler.ReadUint16(ins[f.ip:])]
+			isConst := ins[f.ip+2] == 1
+			f.ip += 3
+
+			scope, index := m.slot(f, binding.Refs[0])
+			value := m.pop()
+
+			if scope.slots[index] != nil {
+				err = eval.RedefinitionError(binding.Name)
+				break
+			}
+
+			scope.slots[index] = value
+			if isConst {
+				scope.setConst(index)
+			}
+		case compiler.OpAssign:
+			binding := m.bytecode.Bindings[compiler.ReadUint16(ins[f.ip:])]
+			operator := compiler.Opcode(ins[f.ip+2])
+			f.ip += 3
+
+			value := m.pop()
+			scope, index := m.resolve(f, binding)
+
+			if scope == nil {
+				err = eval.UndefinedAssignmentError(binding.Name)
+				break
+			}
+
+			if scope.isConst(index) {
+				err = eval.ConstantAssignmentError(binding.Name)
+				break
+			}
+
+			if operator != 0 {
+				value = m.binaryOperation(operator, scope.slots[index], value)
+				if err = asError(value); err != nil {
+					break
+				}
+			}
+
+			scope.slots[index] = value
+			m.push(value)
+		case compiler.OpStore:
+			binding := m.bytecode.Bindings[compiler.ReadUint16(ins[f.ip:])]
+			f.ip += 2
+
+			scope, index := m.slot(f, binding.Refs[0])
+			scope.slots[index] = m.pop()
+
+		case compiler.OpArray:
+			n := int(compiler.ReadUint16(ins[f.ip:]))
+			f.ip += 2
+
+			items := make([]object.Object, n)
+			copy(items, m.stack[m.sp-n:m.sp])
+			m.sp -= n
+
+			m.push(&object.Array{Items: items})
+		case compiler.OpHash:
+			m.push(&object.Hash{Items: make(map[object.HashKey]object.HashPair)})
+		case compiler.OpHashPut:
+			value := m.pop()
+			key := m.pop()
+
+			err = eval.HashPut(m.stack[m.sp-1].(*object.Hash), key, value)
+		case compiler.OpCheckIndex:
+			err = eval.CheckIndexable(m.stack[m.sp-1])
+		case compiler.OpIndex:
+			index := m.pop()
+			left := m.pop()
+
+			result := eval.IndexOperation(left, index)
+			if err = asError(result); err == nil {
+				m.push(result)
+			}
+		case compiler.OpAssignIndex:
+			operator := compiler.Opcode(ins[f.ip])
+			f.ip++
+
+			index := m.pop()
+			left := m.pop()
+			value := m.pop()
+
+			if operator != 0 {
+				current := eval.IndexOperation(left, index)
+				if err = asError(current); err != nil {
+					break
+				}
+
+				value = m.binaryOperation(operator, current, value)
+				if err = asError(value); err != nil {
+					break
+				}
+			}
+
+			result := eval.SetIndexOperation(left, index, value)
+			if err = asError(result); err == nil {
+				m.push(result)
+			}
+		case compiler.OpGetAttr, compiler.OpGetMethod:
+			name := m.bytecode.Constants[compiler.ReadUint16(ins[f.ip:])].(*object.String).Value
+			f.ip += 2
+
+			var result object.Object
+			if op == compiler.OpGetAttr {
+				result = eval.GetAttrOperation(m.pop(), name)
+			} else {
+				result = eval.MethodOperation(m.pop(), name)
+			}
+
+			if err = asError(result); err == nil {
+				m.push(result)
+			}
+		case compiler.OpAssignAttr:
+			name := m.bytecode.Constants[compiler.ReadUint16(ins[f.ip:])].(*object.String).Value
+			operator := compiler.Opcode(ins[f.ip+2])
+			f.ip += 3
+
+			obj := m.pop()
+			value := m.pop()
+
+			if operator != 0 {
+				current := eval.GetAttrOperation(obj, name)
+				if err = asError(current); err != nil {
+					break
+				}
+
+				value = m.binaryOperation(operator, current, value)
+				if err = asError(value); err != nil {
+					break
+				}
+			}
+
+			result := eval.SetAttrOperation(obj, name, value)
+			if err = asError(result); err == nil {
+				m.push(result)
+			}
+
+		case compiler.OpClosure:
+			fn := m.bytecode.Constants[compiler.ReadUint16(ins[f.ip:])].(*compiler.CompiledFunction)
+			f.ip += 2
+
+			m.push(&Closure{Fn: fn, Scope: f.scope})
+		case compiler.OpCall:
+			n := int(compiler.ReadUint16(ins[f.ip:]))
+			f.ip += 2
+
+			var called *frame
+			if called, err = m.call(n, len(m.frames)-1); called != nil {
+				m.frames = append(m.frames, called)
+				f = called
+				ins = f.fn.Instructions
+			}
+		case compiler.OpTailCall:
+			n := int(compiler.ReadUint16(ins[f.ip:]))
+			f.ip += 2
+
+			// the called function replaces the current one, the results of
+			// the builtin functions are returned by the next instruction.
+			var called *frame
+			if called, err = m.call(n, len(m.frames)-2); called != nil {
+				copy(m.stack[f.base:], m.stack[called.base:m.sp])
+				m.sp -= called.base - f.base
+				called.base = f.base
+
+				m.frames[len(m.frames)-1] = called
+				f = called
+				ins = f.fn.Instructions
+			}
+		case compiler.OpReturnValue, compiler.OpReturn:
+			var value object.Object = eval.NULL
+			if op == compiler.OpReturnValue {
+				value = m.pop()
+			}
+
+			m.frames = m.frames[:len(m.frames)-1]
+			m.sp = f.base
+
+			if len(m.frames) == bottom {
+				return value
+			}
+
+			m.push(value)
+
+			f = m.frames[len(m.frames)-1]
+			ins = f.fn.Instructions
+
+		case compiler.OpEnterScope:
+			f.scope = newScope(int(compiler.ReadUint16(ins[f.ip:])), f.scope)
+			f.ip += 2
+		case compiler.OpLeaveScope:
+			f.scope = f.scope.parent
+
+		case compiler.OpLoop:
+			f.loops = append(f.loops, loopRecord{sp: m.sp, scope: f.scope})
+		case compiler.OpLoopEnd:
+			f.loops = f.loops[:len(f.loops)-1]
+		case compiler.OpBreak, compiler.OpContinue:
+			i := len(f.loops) - 1 - int(compiler.ReadUint16(ins[f.ip:]))
+			record := f.loops[i]
+
+			m.sp = record.sp
+			f.scope = record.scope
+
+			// the loop is over after a break, it goes on after a continue
+			if op == compiler.OpBreak {
+				f.loops = f.loops[:i]
+			} else {
+				f.loops = f.loops[:i+1]
+			}
+
+			f.ip = int(compiler.ReadUint32(ins[f.ip+2:]))
+
+			if op == compiler.OpContinue {
+				err = m.interrupted()
+			}
+
+		case compiler.OpIter:
+			oneVariable := ins[f.ip] == 1
+			f.ip++
+
+			keys, values, iterErr := eval.Iterate(m.pop(), oneVariable)
+			if err = iterErr; err == nil {
+				m.push(&iterator{keys: keys, values: values})
+			}
+		case compiler.OpIterNext:
+			it := m.stack[m.sp-1].(*iterator)
+
+			if it.next >= len(it.keys) {
+				f.ip = int(compiler.ReadUint32(ins[f.ip:]))
+				break
+			}
+
+			m.push(it.values[it.next])
+			m.push(it.keys[it.next])
+			it.next++
+			f.ip += 4
+
+		case compiler.OpMatch:
+			pattern := m.bytecode.Patterns[compiler.ReadUint16(ins[f.ip:])]
+
+			if m.match(pattern, m.stack[m.sp-1], f.scope) {
+				f.ip += 6
+			} else {
+				f.ip = int(compiler.ReadUint32(ins[f.ip+2:]))
+			}
+		case compiler.OpNoMatch:
+			err = eval.NoMatchError(m.stack[m.sp-1])
+		case compiler.OpMatchEnd:
+			result := m.pop()
+			m.stack[m.sp-1] = result
+
+		default:
+			return &object.Error{Message: fmt.Sprintf("internal error: unknown opcode %d", op)}
+		}
+
+		if err != nil {
+			// errors are located at the node of the failing instruction, unless they already are
+			if span, ok := f.fn.SourceMap.Lookup(start); ok && !err.Pos.IsValid() {
+				err.Pos = span.Pos
+				err.End = span.End
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// binaryOperation applies the operator of the opcode, integers are handled directly
+// when the result fits in 64 bits, everything else is left to the evaluator.
+func (m *machine) binaryOperation(op compiler.Opcode, left, right object.Object) object.Object {
+	if l, ok := left.(*object.Integer); ok {
+		if r, ok := right.(*object.Integer); ok {
+			a, b := l.Value, r.Value
+
+			switch op {
+			case compiler.OpAdd:
+				if sum := a + b; (sum > a) == (b > 0) {
+					return integer(sum)
+				}
+			case compiler.OpSub:
+				if diff := a - b; (diff < a) == (b > 0) {
+					return integer(diff)
+				}
+			case compiler.OpEqual:
+				return boolean(a == b)
+			case compiler.OpNotEqual:
+				return boolean(a != b)
+			case compiler.OpGreater:
+				return boolean(a > b)
+			case compiler.OpGreaterEqual:
+				return boolean(a >= b)
+			case compiler.OpLess:
+				return boolean(a < b)
+			case compiler.OpLessEqual:
+				return boolean(a <= b)
+			}
+		}
+	}
+
+	// the right operand is only evaluated when the left one is falsy, it is the result
+	if op == compiler.OpOr && m.options.TruthyOr {
+		return right
+	}
+
+	return eval.InfixOperation(compiler.Operators[op], left, right)
+}
+
+// resolve returns the scope and the slot of the variable of the binding, the scope is nil if it is not defined.
+func (m *machine) resolve(f *frame, binding *compiler.Binding) (*Scope, int) {
+	for _, ref := range binding.Refs {
+		if scope, index := m.slot(f, ref); scope.slots[index] != nil {
+			return scope, index
+		}
+	}
+
+	return nil, 0
+}
+
+func (m *machine) slot(f *frame, ref compiler.Ref) (*Scope, int) {
+	if ref.Global {
+		return m.globals, ref.Index
+	}
+
+	scope := f.scope
+	for i := 0; i < ref.Depth; i++ {
+		scope = scope.parent
+	}
+
+	return scope, ref.Index
+}
+
+// interrupted returns a cancellation error if the context of the run is done
+func (m *machine) interrupted() *object.Error {
+	select {
+	case <-m.ctx.Done():
+		return eval.NewCancellationError(m.ctx.Err())
+	default:
+		return nil
+	}
+}
+
+// builtin returns the builtin function with the given name from the registry of the options
+func (m *machine) builtin(name string) (*object.Builtin, bool) {
+	if m.options.Builtins != nil {
+		return m.options.Builtins.Lookup(name)
+	}
+
+	return eval.LookupBuiltin(name)
+}
+
+// call calls the function below the n arguments on top of the stack from the given call depth, it returns
+// the frame of the call for the closures, the results of the builtin functions are pushed right away.
+func (m *machine) call(n int, depth int) (*frame, *object.Error) {
+	base := m.sp - 1 - n
+
+	switch fn := m.stack[base].(type) {
+	case *Closure:
+		if len(fn.Fn.Params) != n {
+			return nil, eval.ArgumentsCountError(len(fn.Fn.Params), n)
+		}
+
+		if err := m.interrupted(); err != nil {
+			return nil, err
+		}
+
+		if depth >= m.options.CallDepthLimit() {
+			return nil, eval.RecursionDepthError()
+		}
+
+		scope := fn.Scope
+		if fn.Fn.NumSlots > 0 {
+			scope = newScope(fn.Fn.NumSlots, fn.Scope)
+
+			for i, slot := range fn.Fn.Params {
+				scope.slots[slot] = m.stack[base+1+i]
+			}
+		}
+
+		m.sp = base + 1 + n
+
+		return &frame{fn: fn.Fn, base: base, scope: scope}, nil
+	case *object.Builtin:
+		args := make([]object.Object, n)
+		copy(args, m.stack[base+1:m.sp])
+
+		var result object.Object
+		if fn.Runtime != nil {
+			result = fn.Runtime(m, args...)
+		} else {
+			result = fn.Fn(args...)
+		}
+
+		if err := asError(result); err != nil {
+			return nil, err
+		}
+
+		if result == nil {
+			result = eval.NULL
+		}
+
+		m.sp = base
+		m.push(result)
+
+		return nil, nil
+	default:
+		return nil, eval.NotCallableError(m.stack[base])
+	}
+}
+
+// Call calls back a function of the program from a builtin, the closures are run to completion
+// on top of the frames of the builtin's caller, so they count towards the same call depth.
+func (m *machine) Call(fn object.Object, args ...object.Object) object.Object {
+	base := m.sp
+
+	m.push(fn)
+	for _, arg := range args {
+		m.push(arg)
+	}
+
+	called, err := m.call(len(args), len(m.frames)-1)
+	if err != nil {
+		m.sp = base
+		return err
+	}
+
+	if called == nil {
+		return m.pop()
+	}
+
+	m.frames = append(m.frames, called)
+	return m.run(len(m.frames) - 1)
+}
+
+// Allocate accounts for a value created by a builtin, the vm doesn't limit the memory
+func (m *machine) Allocate(items int, bytes int64) *object.Error {
+	return nil
+}
+
+// match reports whether the value matches the pattern, binding the variables of the pattern in the scope.
+func (m *machine) match(pattern *compiler.Pattern, value object.Object, scope *Scope) bool {
+	switch pattern.Kind {
+	case compiler.LiteralPattern:
+		return eval.LiteralMatches(pattern.Value, value)
+	case compiler.BindPattern:
+		scope.slots[pattern.Slot] = value
+		return true
+	case compiler.WildcardPattern:
+		return true
+	case compiler.ArrayPattern:
+		arr, ok := value.(*object.Array)
+		if !ok || len(arr.Items) != len(pattern.Items) {
+			return false
+		}
+
+		for i, item := range pattern.Items {
+			if !m.match(item, arr.Items[i], scope) {
+				return false
+			}
+		}
+
+		return true
+	case compiler.HashPattern:
+		hash, ok := value.(*object.Hash)
+		if !ok {
+			return false
+		}
+
+		for i, key := range pattern.Keys {
+			hashable, ok := key.(object.Hashable)
+			if !ok {
+				return false
+			}
+
+			pair, ok := hash.Items[hashable.HashKey()]
+			if !ok || !m.match(pattern.Items[i], pair.Value, scope) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// conditionError reports a non-boolean condition of the given kind
+func conditionError(kind byte, cond object.Object) *object.Error {
+	switch kind {
+	case compiler.LoopCondition:
+		return eval.ConditionError(eval.LoopCondition, cond)
+	case compiler.MatchGuard:
+		return eval.ConditionError(eval.MatchGuard, cond)
+	default:
+		return eval.ConditionError(eval.IfCondition, cond)
+	}
+}
+
+func boolean(value bool) *object.Boolean {
+	if value {
+		return eval.TRUE
+	}
+
+	return eval.FALSE
+}
+
+func asError(obj object.Object) *object.Error {
+	err, _ := obj.(*object.Error)
+	return err
+}
@@ -0,0 +1,487 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/yassinebenaid/nishimia/compiler"
+	"github.com/yassinebenaid/nishimia/eval"
+	"github.com/yassinebenaid/nishimia/lexer"
+	"github.com/yassinebenaid/nishimia/object"
+	"github.com/yassinebenaid/nishimia/parser"
+)
+
+// the programs below are run by both engines, their results and errors must be identical
+var programs = []string{
+	"",
+	"5",
+	"-526; 11",
+	"(5 + 10 * 2 + 15 / 3) * 2 + -10",
+	"9223372036854775807 + 1",
+	"-9223372036854775807 - 2",
+	"(-9223372036854775807 - 1) - 1",
+	"99999999999999999999 * 99999999999999999999",
+	"10 % 3 + 2.5 * 2",
+	"1 / 0",
+	"1 % 0",
+	`"foo" + "bar"`,
+	`"foo" - "bar"`,
+	`"a" == "a"`,
+	"1 < 2 == true",
+	"1 == 1.0",
+	"!5",
+	"-true",
+	"+\"a\"",
+	"true && false || true",
+	"false && undefined",
+	"true || undefined",
+	"1 && true",
+	"true && 1",
+	"if (1 < 2) { 10 } else { 20 }",
+	"if (1 > 2) { 10 }",
+	"if 1 { 10 }",
+	"if false { 1 } else if true { 2 } else { 3 }",
+	"if true { var x = 1; } x",
+	"return 5; 10",
+	"if true { if true { return 10; } return 1; }",
+	"var a = 5; var b = a * 2; a + b",
+	"var a = 5; var a = 6;",
+	"const a = 5; a = 6;",
+	"const a = 5; a += 1;",
+	"a = 1",
+	"var a = 5; a += 2; a *= 3; a",
+	"var a = 5; a = a + 1",
+	"var a = 1; a += \"x\"",
+	"undefined",
+	"len(\"hello\")",
+	"len(1)",
+	"len(\"a\", \"b\")",
+	"int(\"42\") + float(1)",
+	"var f = len; f(\"abc\")",
+	"5()",
+	"var f = func(x) { x }; f(1)",
+	"var f = func(x, y) { return x + y; }; f(1, 2)",
+	"var f = func(x, y) { return x + y; }; f(1)",
+	"var f = func() { return 1; }; f",
+	"func(x) { return x * 2; }(21)",
+	"var f = func() { return y; }; var y = 2; f()",
+	"var f = func() { return y; }; f()",
+	"var x = 1; var f = func() { var x = 2; return x; }; [f(), x]",
+	"var x = 1; var f = func() { x = 2; }; f(); x",
+	"var f = func() { var x = x + 1; return x; }; var x = 10; f()",
+	"var f = func(x) { var x = 1; }; f(1)",
+	"var newAdder = func(x) { return func(y) { return x + y; }; }; var addTwo = newAdder(2); addTwo(3)",
+	`var counter = func() {
+		var count = 0;
+		return func() { count += 1; return count; };
+	};
+	var c = counter();
+	c(); c();
+	var d = counter();
+	[c(), d()]`,
+	"var fib = func(n) { if n < 2 { return n; } return fib(n - 1) + fib(n - 2); }; fib(15)",
+	"var f = func(n) { if n == 0 { return 0; } return n + f(n - 1); }; f(500)",
+	"[1, 2 * 2, 3 + 3]",
+	"[]",
+	"[1, 2, 3][1]",
+	"[1, 2, 3][3]",
+	"[1, 2, 3][-1]",
+	"[1, 2, 3][\"a\"]",
+	"5[0]",
+	"5[undefined]",
+	"var a = [1, 2]; a[0] = 5; a[1] += 1; a",
+	"var a = [1, 2]; a[2] = 5",
+	"var a = 1; a[0] = 1",
+	"var a = 1; a[0] += 1",
+	`{"a": 1, "b": 2, 3: true}`,
+	`{"a": 1}["a"]`,
+	`{"a": 1}["b"]`,
+	`{"a": 1}[[]]`,
+	`{[]: 1}`,
+	`{}`,
+	`var h = {"a": 1}; h["b"] = 2; h["a"] += 10; h`,
+	`var h = {"a": {"b": [1, 2]}}; h["a"]["b"][1]`,
+	"var i = 0; while i < 10 { i += 1; } i",
+	"var i = 0; while i < 10 { i += 1; if i == 5 { break; } } i",
+	"while 1 { }",
+	"var s = 0; for var i = 0; i < 10; i += 1 { if i % 2 == 0 { continue; } s += i; } s",
+	"for var i = 0; i < 3; i += 1 { } i",
+	"var s = 0; for ; s < 5; { s += 1; } s",
+	"var n = 0; for ; ; { n += 1; if n == 7 { break; } } n",
+	"var s = \"\"; for x in [\"a\", \"b\", \"c\"] { s += x; } s",
+	"var s = 0; for i, x in [10, 20, 30] { s += i * x; } s",
+	`var s = ""; for c in "héllo" { s = c + s; } s`,
+	`var s = ""; for k, v in {"b": 2, "a": 1} { s += k; } s`,
+	`var s = 0; for k in {1: "a", 2: "b"} { s += k; } s`,
+	"for x in 5 { }",
+	"var a = [1, 2]; for x in a { a[0] = 10; } a",
+	"var fs = []; var r = []; for i in [1, 2, 3] { var j = i * 10; fs = [func() { return j; }, fs]; } fs[0]()",
+	`var out = 0;
+	outer: for i in [1, 2, 3] {
+		for j in [1, 2, 3] {
+			if j == 2 { continue outer; }
+			if i == 3 { break outer; }
+			out += i * j;
+		}
+	}
+	out`,
+	`var n = 0;
+	loop: while true {
+		var k = 0;
+		while true {
+			k += 1;
+			if k == 3 { n += 1; continue loop; }
+			if n == 2 { break loop; }
+		}
+	}
+	n`,
+	"var f = func() { for x in [1, 2, 3] { if x == 2 { return x * 100; } } }; f()",
+	"var s = 0; for x in [1, 2, 3] { s += match x { case 2 { continue; } default { x } }; } s",
+	"var s = 0; for x in [1, 2, 3] { s += [1, if x == 2 { break; } else { x }][1]; } s",
+	"var x = 1; while x < 5 { var y = x; x += y; } x",
+	"while true { var y = 1; y = y + 1; if y == 2 { break; } }",
+	"match 1 { case 1 { \"one\" } case 2 { \"two\" } }",
+	"match 3 { case 1 { \"one\" } default { \"other\" } }",
+	"match 3 { case 1 { \"one\" } }",
+	"match 2.0 { case 2 { \"two\" } }",
+	"match -1 { case -1 { \"minus one\" } }",
+	`match "a" { case "a" { 1 } }`,
+	"match true { case false { 0 } case true { 1 } }",
+	"match [1, 2] { case [a, b] { a + b } }",
+	"match [1, [2, 3]] { case [_, [x, y]] { x * y } }",
+	"match [1, 2, 3] { case [a, b] { 0 } default { 1 } }",
+	`match {"name": "bob", "age": 3} { case {"name": n} { n } }`,
+	`match {"name": "bob"} { case {"age": a} { a } default { "no age" } }`,
+	"match 5 { case n if n > 3 { n * 2 } default { 0 } }",
+	"match 2 { case n if n > 3 { n * 2 } default { n } }",
+	"match 5 { case n if n { 1 } }",
+	"match 5 { case x { var y = x + 1; y } }",
+	"var x = 100; match 5 { case [x] { x } case _ { x } }",
+	"var f = func(v) { return match v { case [a, b] { return a + b; } default { 0 } }; }; f([1, 2])",
+	"var x = match 1 { case 1 { var y = 2; y } }; x",
+	"var x = match 1 { case 1 { return 5; } }; 10",
+	"var f = func() { var x = [1, if true { return 2; } else { 3 }]; return 0; }; f()",
+	"var s = 0; outer: for x in [1, 2] { while if x == 2 { break outer; } else { false } { } s += x; } s",
+	"var s = 0; outer: for x in [1] { for var i = 0; i < 5; i += match i { case 3 { break outer; } default { 1 } } { s += i; } } s",
+}
+
+func TestEngineParity(t *testing.T) {
+	for _, input := range programs {
+		expected := testEval(input, eval.Options{})
+		got := testRun(t, input, eval.Options{})
+
+		if inspect(expected) != inspect(got) {
+			t.Errorf("wrong result for %q.\nexpected=%s\ngot=%s", input, inspect(expected), inspect(got))
+		}
+	}
+}
+
+func TestTruthyOr(t *testing.T) {
+	tests := []string{
+		`"" || "default"`,
+		`"name" || "default"`,
+		`0 || null || [] || 5`,
+		`false || 0`,
+		`true || undefined`,
+		`1 || undefined`,
+		`1 && 2`,
+	}
+
+	for _, input := range tests {
+		options := eval.Options{TruthyOr: true}
+
+		expected := testEval(input, options)
+		got := testRun(t, input, options)
+
+		if inspect(expected) != inspect(got) {
+			t.Errorf("wrong result for %q.\nexpected=%s\ngot=%s", input, inspect(expected), inspect(got))
+		}
+	}
+}
+
+func TestBuiltinRegistry(t *testing.T) {
+	builtins := eval.DefaultBuiltins().Restrict("len")
+	builtins.Register("twice", 1, "twice(s) repeats the string s twice", func(args ...object.Object) object.Object {
+		return &object.String{Value: args[0].Inspect() + args[0].Inspect()}
+	})
+
+	tests := []string{
+		`twice("ab")`,
+		`len(twice("ab"))`,
+		`twice("a", "b")`,
+		`int("1")`,
+		`var twice = 1; twice`,
+	}
+
+	for _, input := range tests {
+		options := eval.Options{Builtins: builtins}
+
+		expected := testEval(input, options)
+		got := testRun(t, input, options)
+
+		if inspect(expected) != inspect(got) {
+			t.Errorf("wrong result for %q.\nexpected=%s\ngot=%s", input, inspect(expected), inspect(got))
+		}
+	}
+}
+
+// counter is a host object counting the calls of its method inc
+type counter struct {
+	count int64
+}
+
+func (c *counter) Type() object.ObjectType { return "COUNTER" }
+func (c *counter) Inspect() string         { return "counter" }
+
+func (c *counter) GetAttr(name string) (object.Object, error) {
+	if name != "count" {
+		return nil, fmt.Errorf("counter has no attribute %s", name)
+	}
+
+	return &object.Integer{Value: c.count}, nil
+}
+
+func (c *counter) SetAttr(name string, value object.Object) error {
+	i, ok := value.(*object.Integer)
+	if name != "count" || !ok {
+		return fmt.Errorf("cannot assign %s to %s", value.Inspect(), name)
+	}
+
+	c.count = i.Value
+	return nil
+}
+
+func (c *counter) CallMethod(name string, args ...object.Object) (object.Object, error) {
+	if name != "inc" {
+		return nil, fmt.Errorf("counter has no method %s", name)
+	}
+
+	c.count++
+	return c, nil
+}
+
+func TestHostObjects(t *testing.T) {
+	tests := []string{
+		"var c = counter(); c.inc().inc(); c.count",
+		"var c = counter(); c.count = 5; c.count *= 2; c.count",
+		"var c = counter(); var f = func() { return c.inc(); }; f().count + f().count",
+		"var c = counter(); for i in [1, 2, 3] { c.inc(); } c.count",
+		"var c = counter(); c.dec()",
+		"var c = counter(); c.total",
+		`var c = counter(); c.count = "a"`,
+		`var c = counter(); c["count"]`,
+		"var x = [1]; x.count",
+		"var x = [1]; x.count = 1",
+		"var x = [1]; x.count()",
+	}
+
+	for _, input := range tests {
+		var results [2]object.Object
+
+		// each engine gets its own counter
+		for i := range results {
+			builtins := eval.DefaultBuiltins()
+			builtins.Register("counter", 0, "", func(args ...object.Object) object.Object {
+				return &counter{}
+			})
+
+			if i == 0 {
+				results[i] = testEval(input, eval.Options{Builtins: builtins})
+			} else {
+				results[i] = testRun(t, input, eval.Options{Builtins: builtins})
+			}
+		}
+
+		if inspect(results[0]) != inspect(results[1]) {
+			t.Errorf("wrong result for %q.\nexpected=%s\ngot=%s", input, inspect(results[0]), inspect(results[1]))
+		}
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input string
+		pos   string
+		end   string
+	}{
+		{"1 + true", "1:1", "1:9"},
+		{"var f = func() {\n  return 1 / 0;\n};\nf()", "2:10", "2:15"},
+		{"var a = [1];\na[5]", "2:1", "2:5"},
+		{"match 1 { case 2 { 0 } }", "1:1", "1:25"},
+		{"while 1 { }", "1:1", "1:12"},
+		{"len(1)", "1:1", "1:7"},
+		{"x", "1:1", "1:2"},
+	}
+
+	for _, tt := range tests {
+		err, ok := testRun(t, tt.input, eval.Options{}).(*object.Error)
+		if !ok {
+			t.Errorf("expected an error for %q", tt.input)
+			continue
+		}
+
+		if err.Pos.String() != tt.pos || err.End.String() != tt.end {
+			t.Errorf("wrong position for %q. expected=%s-%s, got=%s-%s", tt.input, tt.pos, tt.end, err.Pos, err.End)
+		}
+	}
+}
+
+func TestSharedGlobals(t *testing.T) {
+	c := compiler.New()
+	globals := NewGlobals()
+
+	inputs := []struct {
+		input    string
+		expected string
+	}{
+		{"var f = func() { return x; };", "null"},
+		{"f()", "ERROR: 1:25: undefined identifier : x"},
+		{"var x = 5;", "null"},
+		{"f()", "5"},
+		{"x += 1; f()", "6"},
+		{"var x = 1;", "ERROR: 1:1: variable x already defined"},
+	}
+
+	for _, tt := range inputs {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+
+		if err := c.Compile(program); err != nil {
+			t.Fatalf("failed to compile %q: %s", tt.input, err)
+		}
+
+		result := Run(c.Bytecode(), globals)
+
+		if inspect(result) != tt.expected {
+			t.Errorf("wrong result for %q. expected=%s, got=%s", tt.input, tt.expected, inspect(result))
+		}
+	}
+}
+
+func TestDeepRecursion(t *testing.T) {
+	input := "var f = func(n) { if n == 0 { return 0; } return 1 + f(n - 1); }; f(100000)"
+
+	// the frames are not on the Go stack, the depth is only limited by the options
+	if result := inspect(testRun(t, input, eval.Options{MaxCallDepth: 200000})); result != "100000" {
+		t.Errorf("wrong result. expected=100000, got=%s", result)
+	}
+
+	if result := inspect(testRun(t, input, eval.Options{})); result != "ERROR: 1:54: maximum recursion depth exceeded" {
+		t.Errorf("expected the default maximum depth to be exceeded, got=%s", result)
+	}
+}
+
+func TestRunContext(t *testing.T) {
+	inputs := []string{
+		"while true { }",
+		"for ; ; { }",
+		"var i = 0; for x in [1, 2] { while true { i += x; } }",
+		"for x in [1] { while true { continue; } }",
+		"var f = func() { return f(); }; f()",
+		"var f = func(n) { if n > 0 { return 1 + f(n - 1); } return 0; }; var g = func() { while true { f(100); } }; g()",
+	}
+
+	for _, input := range inputs {
+		bytecode, err := compiler.Compile(parser.New(lexer.New(input)).ParseProgram())
+		if err != nil {
+			t.Fatalf("failed to compile %q: %s", input, err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		result := RunContext(ctx, bytecode, nil)
+		cancel()
+
+		if !eval.IsCancellation(result) {
+			t.Errorf("expected a cancellation error for %q, got=%s", input, inspect(result))
+		}
+	}
+}
+
+func testEval(input string, options eval.Options) object.Object {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+
+	return eval.New(options).Eval(program, object.NewEnvirement())
+}
+
+func testRun(t testing.TB, input string, options eval.Options) object.Object {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+
+	if len(p.Errors()) > 0 {
+		t.Fatalf("failed to parse %q: %v", input, p.Errors())
+	}
+
+	bytecode, err := compiler.Compile(program)
+	if err != nil {
+		t.Fatalf("failed to compile %q: %s", input, err)
+	}
+
+	return New(options).Run(bytecode, nil)
+}
+
+func inspect(obj object.Object) string {
+	if obj == nil {
+		return "<nil>"
+	}
+
+	return obj.Inspect()
+}
+
+var benchmarks = []struct {
+	name  string
+	input string
+}{
+	{"fib", "var fib = func(n) { if n < 2 { return n; } return fib(n - 1) + fib(n - 2); }; fib(20)"},
+	{"loop", "var sum = 0; for var i = 0; i < 100000; i += 1 { if i % 3 == 0 { continue; } sum += i; } sum"},
+	{"hash", `
+	var counts = {};
+	for var i = 0; i < 100; i += 1 { counts[i] = 0; }
+	for var i = 0; i < 10000; i += 1 { counts[i % 100] += 1; }
+	var total = 0;
+	for k, v in counts { total += v; }
+	total`},
+}
+
+func BenchmarkEngines(b *testing.B) {
+	for _, bench := range benchmarks {
+		program := parser.New(lexer.New(bench.input)).ParseProgram()
+
+		b.Run(bench.name+"/eval", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				eval.Eval(program, object.NewEnvirement())
+			}
+		})
+
+		b.Run(bench.name+"/vm", func(b *testing.B) {
+			bytecode, err := compiler.Compile(program)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				Run(bytecode, nil)
+			}
+		})
+	}
+}
+
+func TestBenchmarkPrograms(t *testing.T) {
+	for _, bench := range benchmarks {
+		expected := testEval(bench.input, eval.Options{})
+		got := testRun(t, bench.input, eval.Options{})
+
+		if isErr(expected) || inspect(expected) != inspect(got) {
+			t.Errorf("wrong result for %s. expected=%s, got=%s", bench.name, inspect(expected), inspect(got))
+		}
+	}
+}
+
+func isErr(obj object.Object) bool {
+	_, ok := obj.(*object.Error)
+	return ok
+}